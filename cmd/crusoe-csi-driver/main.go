@@ -55,10 +55,42 @@ func setFlags() {
 			true),
 		internal.ServicesFlag,
 		"Crusoe CSI Driver services")
+	rootCmd.Flags().Var(
+		enumflag.New(&internal.SelectedLogFormat,
+			internal.LogFormatFlag,
+			internal.LogFormatNames,
+			true),
+		internal.LogFormatFlag,
+		"Log output format (text or json)")
 	rootCmd.Flags().String(internal.NodeNameFlag, "", "Kubernetes Node Name")
 	rootCmd.Flags().String(internal.SocketAddressFlag, internal.SocketAddressDefault, "CSI Socket Address")
 	rootCmd.Flags().String(internal.NFSRemotePortsFlag, internal.NFSRemotePortsDefault, "NFS Remote Ports")
 	rootCmd.Flags().String(internal.NFSHostFlag, internal.NFSHostDefault, "NFS Host")
+	rootCmd.Flags().String(internal.NFSTargetConfigFlag, "",
+		"Path of a YAML file, e.g. a mounted ConfigMap, configuring the resolvers choosing the NFS host of each volume")
+	rootCmd.Flags().String(internal.NFSLocationTargetsFlag, "",
+		"Comma separated location=VIP range pairs used instead of the NFS host and remote ports flags, "+
+			"e.g. us-east1-a=100.64.0.2-100.64.0.17")
+	rootCmd.Flags().Bool(internal.EmitEventsFlag, false, "Emit Kubernetes Events for volume lifecycle failures")
+	rootCmd.Flags().Bool(internal.TracingEnabledFlag, false, "Export OpenTelemetry traces over OTLP")
+	rootCmd.Flags().String(internal.TracingEndpointFlag, internal.TracingEndpointDefault, "OTLP gRPC trace endpoint")
+	rootCmd.Flags().Bool(internal.TracingInsecureFlag, false, "Disable TLS for the OTLP trace endpoint")
+	rootCmd.Flags().Bool(internal.MetricsEnabledFlag, false, "Export OpenTelemetry metrics over OTLP")
+	rootCmd.Flags().String(internal.MetricsEndpointFlag, internal.MetricsEndpointDefault, "OTLP gRPC metric endpoint")
+	rootCmd.Flags().Bool(internal.MetricsInsecureFlag, false, "Disable TLS for the OTLP metric endpoint")
+	rootCmd.Flags().Duration(internal.MetricsIntervalFlag, internal.MetricsIntervalDefault,
+		"How often metrics are exported to the OTLP metric endpoint")
+	rootCmd.Flags().Bool(internal.OnlineExpansionFlag, false,
+		"Expand SSD volumes while they are attached and grow their filesystems on the node")
+	rootCmd.Flags().Duration(internal.OperationTimeoutFlag, internal.OperationTimeoutDefault,
+		"Timeout for each mount, format, resize and unmount on the node, 0 to rely on the RPC deadline only")
+	rootCmd.Flags().String(internal.LUKSKeyFileDirFlag, internal.LUKSKeyFileDirDefault,
+		"tmpfs directory holding the new passphrase while the passphrase of an encrypted volume is rotated, "+
+			"empty to refuse rotations")
+	rootCmd.Flags().Duration(internal.FlagCacheTTLFlag, internal.FlagCacheTTLDefault,
+		"How long project feature flags are cached before they are refreshed in the background")
+	rootCmd.Flags().String(internal.FlagOverridesFlag, "",
+		"Comma separated project feature flags pinned to a value, e.g. nfs=true,vast-use-secondary-cluster=false")
 
 	err = viper.BindPFlags(rootCmd.Flags())
 	if err != nil {
@@ -3,13 +3,17 @@ package internal
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"math"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"k8s.io/klog/v2"
 
 	"github.com/crusoecloud/crusoe-csi-driver/internal/common"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/crusoe"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/thediveo/enumflag/v2"
@@ -45,17 +49,47 @@ var CSIDriverTypeNames = map[CSIDriverType][]string{ //nolint:gochecknoglobals
 
 var SelectedCSIDriverType = CSIDriverTypeSSD //nolint:gochecknoglobals // flag variable
 
+type LogFormat enumflag.Flag
+
+const (
+	LogFormatText LogFormat = iota
+	LogFormatJSON
+)
+
+var LogFormatNames = map[LogFormat][]string{ //nolint:gochecknoglobals  // can't construct const map
+	LogFormatText: {"text"},
+	LogFormatJSON: {"json"},
+}
+
+var SelectedLogFormat = LogFormatText //nolint:gochecknoglobals // flag variable
+
 const (
-	CrusoeAPIEndpointFlag = "crusoe-api-endpoint"
-	CrusoeAccessKeyFlag   = "crusoe-access-key"
-	CrusoeSecretKeyFlag   = "crusoe-secret-key"
-	CrusoeProjectIDFlag   = "crusoe-project-id"
-	CSIDriverTypeFlag     = "crusoe-csi-driver-type"
-	ServicesFlag          = "services"
-	NodeNameFlag          = "node-name"
-	SocketAddressFlag     = "socket-address"
-	NFSRemotePortsFlag    = "nfs-remote-ports"
-	NFSHostFlag           = "nfs-host"
+	CrusoeAPIEndpointFlag  = "crusoe-api-endpoint"
+	CrusoeAccessKeyFlag    = "crusoe-access-key"
+	CrusoeSecretKeyFlag    = "crusoe-secret-key"
+	CrusoeProjectIDFlag    = "crusoe-project-id"
+	CSIDriverTypeFlag      = "crusoe-csi-driver-type"
+	ServicesFlag           = "services"
+	NodeNameFlag           = "node-name"
+	SocketAddressFlag      = "socket-address"
+	NFSRemotePortsFlag     = "nfs-remote-ports"
+	NFSHostFlag            = "nfs-host"
+	NFSTargetConfigFlag    = "nfs-target-config"
+	NFSLocationTargetsFlag = "nfs-location-targets"
+	LogFormatFlag          = "log-format"
+	EmitEventsFlag         = "emit-kubernetes-events"
+	TracingEnabledFlag     = "tracing-enabled"
+	TracingEndpointFlag    = "tracing-otlp-endpoint"
+	TracingInsecureFlag    = "tracing-otlp-insecure"
+	MetricsEnabledFlag     = "metrics-enabled"
+	MetricsEndpointFlag    = "metrics-otlp-endpoint"
+	MetricsInsecureFlag    = "metrics-otlp-insecure"
+	MetricsIntervalFlag    = "metrics-export-interval"
+	OnlineExpansionFlag    = "ssd-online-expansion"
+	OperationTimeoutFlag   = "node-operation-timeout"
+	LUKSKeyFileDirFlag     = "luks-key-file-dir"
+	FlagCacheTTLFlag       = "project-flag-cache-ttl"
+	FlagOverridesFlag      = "project-flag-overrides"
 )
 
 const (
@@ -63,6 +97,12 @@ const (
 	SocketAddressDefault     = "unix:/tmp/csi.sock"
 	NFSRemotePortsDefault    = "100.64.0.2-100.64.0.17"
 	NFSHostDefault           = "100.64.0.2"
+	TracingEndpointDefault   = "localhost:4317"
+	MetricsEndpointDefault   = "localhost:4317"
+	MetricsIntervalDefault   = time.Minute
+	OperationTimeoutDefault  = 2 * time.Minute
+	LUKSKeyFileDirDefault    = "/dev/shm"
+	FlagCacheTTLDefault      = crusoe.DefaultFlagCacheTTL
 )
 
 func SetPluginVariables() {
@@ -81,7 +121,29 @@ func SetPluginVariables() {
 	}
 }
 
+// SetLogFormat configures klog to emit logs in the selected format.
+// klog's own verbosity checks still apply before records reach the structured logger.
+func SetLogFormat() {
+	switch SelectedLogFormat {
+	case LogFormatText:
+		// klog's default text output
+	case LogFormatJSON:
+		klog.SetSlogLogger(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+			// klog maps V(n) to slog level -n, so let every verbosity through
+			// and leave filtering to klog
+			Level: slog.Level(math.MinInt),
+		})))
+	default:
+		// Switch is intended to be exhaustive, reaching this case is a bug
+		panic(fmt.Sprintf(
+			"Switch is intended to be exhaustive, %s is not a valid switch case",
+			viper.GetString(LogFormatFlag)))
+	}
+}
+
 func RunMain(_ *cobra.Command, _ []string) error {
+	SetLogFormat()
+
 	// Set plugin variables based on driver type flag
 	SetPluginVariables()
 
@@ -24,6 +24,26 @@ const (
 
 	VolumeContextDiskSerialNumberKey = "csi.crusoe.ai/serial-number"
 	VolumeContextDiskNameKey         = "csi.crusoe.ai/disk-name"
+	VolumeContextDiskSizeBytesKey    = "csi.crusoe.ai/size-bytes"
+	// Set by CreateVolume on volumes the node formats, i.e. filesystem and encrypted volumes.
+	// The node only formats their device if it was never written to, see FormatUnwritten.
+	VolumeContextProvisionedKey = "csi.crusoe.ai/provisioned"
+
+	// Set by the external-provisioner when it is run with --extra-create-metadata
+	ParameterPVCNameKey      = "csi.storage.k8s.io/pvc/name"
+	ParameterPVCNamespaceKey = "csi.storage.k8s.io/pvc/namespace"
+)
+
+// Structured log keys.
+// These keys are indexed by log pipelines and must remain stable.
+const (
+	LogKeyVolumeID    = "volumeID"
+	LogKeyNodeID      = "nodeID"
+	LogKeyDiskName    = "diskName"
+	LogKeyOperationID = "operationID"
+	LogKeyLocation    = "location"
+	LogKeyTargetPath  = "targetPath"
+	LogKeyDevicePath  = "devicePath"
 )
 
 // Enums.
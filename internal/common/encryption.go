@@ -0,0 +1,36 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ParameterEncryptedKey enables LUKS encryption at rest of a new SSD volume when set to "true".
+// It is validated by the controller and passed to the node in the volume context.
+const ParameterEncryptedKey = "encrypted"
+
+// Keys of the node stage secret of an encrypted volume.
+// Setting SecretPreviousPassphraseKey rotates the volume's key from it to SecretPassphraseKey when the volume is staged.
+const (
+	SecretPassphraseKey         = "passphrase"
+	SecretPreviousPassphraseKey = "previousPassphrase"
+)
+
+var ErrInvalidEncryptionParameter = errors.New("invalid encryption parameter")
+
+// IsEncrypted reports whether params request an encrypted volume.
+func IsEncrypted(params map[string]string) (bool, error) {
+	value, ok := params[ParameterEncryptedKey]
+	if !ok {
+		return false, nil
+	}
+
+	encrypted, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%w: %s must be a boolean, got %q",
+			ErrInvalidEncryptionParameter, ParameterEncryptedKey, value)
+	}
+
+	return encrypted, nil
+}
@@ -0,0 +1,44 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ParameterFsckPolicyKey selects how the node checks an existing filesystem of an SSD volume before mounting it.
+// It is validated by the controller and passed to the node in the volume context.
+const ParameterFsckPolicyKey = "fsckPolicy"
+
+// FsckPolicy is the value of ParameterFsckPolicyKey.
+type FsckPolicy string
+
+const (
+	// FsckPolicyDefault leaves checks to the mounter, which runs fsck -a on ext filesystems.
+	FsckPolicyDefault FsckPolicy = ""
+	// FsckPolicyNone mounts the filesystem without checking it.
+	FsckPolicyNone FsckPolicy = "none"
+	// FsckPolicyCheck reports errors in the filesystem without modifying it, the volume is mounted regardless.
+	FsckPolicyCheck FsckPolicy = "check"
+	// FsckPolicyRepair repairs errors in the filesystem, the volume is not mounted if they cannot be repaired.
+	FsckPolicyRepair FsckPolicy = "repair"
+)
+
+var ErrInvalidFsckPolicy = errors.New("invalid fsck policy")
+
+// ParseFsckPolicy returns the fsck policy in params, or FsckPolicyDefault if it is not set.
+func ParseFsckPolicy(params map[string]string) (FsckPolicy, error) {
+	value, ok := params[ParameterFsckPolicyKey]
+	if !ok {
+		return FsckPolicyDefault, nil
+	}
+
+	switch policy := FsckPolicy(value); policy {
+	case FsckPolicyNone, FsckPolicyCheck, FsckPolicyRepair:
+		return policy, nil
+	case FsckPolicyDefault:
+		fallthrough
+	default:
+		return FsckPolicyDefault, fmt.Errorf("%w: %s must be one of %s, %s or %s, got %q", ErrInvalidFsckPolicy,
+			ParameterFsckPolicyKey, FsckPolicyNone, FsckPolicyCheck, FsckPolicyRepair, value)
+	}
+}
@@ -0,0 +1,190 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// StorageClass parameters tuning the filesystem created on a new SSD volume.
+// They are validated by the controller and passed to the node in the volume context.
+// Filesystems which already exist on the disk are never reformatted, so the parameters have no effect on them.
+const (
+	ParameterBytesPerInodeKey = "csi.crusoe.ai/bytes-per-inode"
+	ParameterExt4LazyInitKey  = "csi.crusoe.ai/ext4-lazy-init"
+	ParameterXFSReflinkKey    = "csi.crusoe.ai/xfs-reflink"
+	ParameterFSLabelKey       = "csi.crusoe.ai/fs-label"
+
+	// ParameterForceFormatKey allows the node to format a blank device of a volume which was not provisioned
+	// by the driver, e.g. a statically provisioned PersistentVolume, or a blank device which was written to before.
+	// It may also be set in the PV's volumeAttributes.
+	ParameterForceFormatKey = "forceFormat"
+)
+
+const (
+	FSTypeExt4 = "ext4"
+	FSTypeXFS  = "xfs"
+
+	// DefaultFSType is used by kubelet when a volume does not specify a filesystem.
+	DefaultFSType = FSTypeExt4
+
+	maxExt4LabelLength = 16
+	maxXFSLabelLength  = 12
+	minBytesPerInode   = 1024
+	maxBytesPerInode   = 64 * 1024 * 1024
+)
+
+var (
+	ErrUnsupportedMkfsParameter = errors.New("parameter is not supported for filesystem")
+	ErrInvalidMkfsParameter     = errors.New("invalid filesystem parameter")
+)
+
+//nolint:gochecknoglobals // can't construct const slice
+var mkfsParameterKeys = []string{
+	ParameterBytesPerInodeKey,
+	ParameterExt4LazyInitKey,
+	ParameterXFSReflinkKey,
+	ParameterFSLabelKey,
+}
+
+// MkfsParameters returns the filesystem creation parameters in params.
+func MkfsParameters(params map[string]string) map[string]string {
+	mkfsParams := make(map[string]string)
+
+	for _, key := range mkfsParameterKeys {
+		if value, ok := params[key]; ok {
+			mkfsParams[key] = value
+		}
+	}
+
+	return mkfsParams
+}
+
+// IsForceFormat reports whether params allow formatting a volume which was not provisioned by the driver.
+func IsForceFormat(params map[string]string) (bool, error) {
+	value, ok := params[ParameterForceFormatKey]
+	if !ok {
+		return false, nil
+	}
+
+	forceFormat, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%w: %s must be true or false, got %q",
+			ErrInvalidMkfsParameter, ParameterForceFormatKey, value)
+	}
+
+	return forceFormat, nil
+}
+
+// FormatPolicy decides whether the node may format a device without any signature.
+type FormatPolicy int
+
+const (
+	// FormatNever refuses to format blank devices, e.g. of volumes the driver did not provision.
+	FormatNever FormatPolicy = iota
+	// FormatUnwritten formats blank devices which were never written to, as a newly created disk.
+	// Whether a disk was formatted before is kept on the disk itself, so it holds wherever the volume is staged.
+	FormatUnwritten
+	// FormatForce formats any blank device, it is requested with ParameterForceFormatKey.
+	FormatForce
+)
+
+// VolumeFormatPolicy returns the format policy of the volume with volumeContext.
+func VolumeFormatPolicy(volumeContext map[string]string) (FormatPolicy, error) {
+	forceFormat, err := IsForceFormat(volumeContext)
+	if err != nil {
+		return FormatNever, err
+	}
+
+	switch {
+	case forceFormat:
+		return FormatForce, nil
+	case volumeContext[VolumeContextProvisionedKey] == "true":
+		return FormatUnwritten, nil
+	default:
+		return FormatNever, nil
+	}
+}
+
+// MkfsOptions translates the filesystem creation parameters in params to mkfs arguments for fsType.
+// Parameters which fsType does not support are rejected rather than ignored.
+//
+//nolint:cyclop // one case per parameter
+func MkfsOptions(fsType string, params map[string]string) ([]string, error) {
+	if fsType == "" {
+		fsType = DefaultFSType
+	}
+
+	var options []string
+
+	for _, key := range mkfsParameterKeys {
+		value, ok := params[key]
+		if !ok {
+			continue
+		}
+
+		switch {
+		case key == ParameterBytesPerInodeKey && fsType == FSTypeExt4:
+			bytesPerInode, err := strconv.Atoi(value)
+			if err != nil || bytesPerInode < minBytesPerInode || bytesPerInode > maxBytesPerInode {
+				return nil, fmt.Errorf("%w: %s must be between %d and %d, got %q",
+					ErrInvalidMkfsParameter, key, minBytesPerInode, maxBytesPerInode, value)
+			}
+
+			options = append(options, "-i", strconv.Itoa(bytesPerInode))
+		case key == ParameterExt4LazyInitKey && fsType == FSTypeExt4:
+			lazyInit, err := parseMkfsBool(key, value)
+			if err != nil {
+				return nil, err
+			}
+
+			options = append(options, "-E",
+				fmt.Sprintf("lazy_itable_init=%[1]d,lazy_journal_init=%[1]d", lazyInit))
+		case key == ParameterXFSReflinkKey && fsType == FSTypeXFS:
+			reflink, err := parseMkfsBool(key, value)
+			if err != nil {
+				return nil, err
+			}
+
+			options = append(options, "-m", fmt.Sprintf("reflink=%d", reflink))
+		case key == ParameterFSLabelKey && (fsType == FSTypeExt4 || fsType == FSTypeXFS):
+			if err := validateFSLabel(fsType, value); err != nil {
+				return nil, err
+			}
+
+			options = append(options, "-L", value)
+		default:
+			return nil, fmt.Errorf("%w: %s is not supported for %s", ErrUnsupportedMkfsParameter, key, fsType)
+		}
+	}
+
+	return options, nil
+}
+
+// parseMkfsBool returns 1 or 0 for a boolean parameter, as the mkfs tools expect.
+func parseMkfsBool(key, value string) (int, error) {
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s must be true or false, got %q", ErrInvalidMkfsParameter, key, value)
+	}
+
+	if enabled {
+		return 1, nil
+	}
+
+	return 0, nil
+}
+
+func validateFSLabel(fsType, label string) error {
+	maxLength := maxExt4LabelLength
+	if fsType == FSTypeXFS {
+		maxLength = maxXFSLabelLength
+	}
+
+	if label == "" || len(label) > maxLength {
+		return fmt.Errorf("%w: %s for %s must be 1 to %d characters, got %q",
+			ErrInvalidMkfsParameter, ParameterFSLabelKey, fsType, maxLength, label)
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package common_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/crusoecloud/crusoe-csi-driver/internal/common"
+)
+
+func TestMkfsOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		fsType  string
+		params  map[string]string
+		want    []string
+		wantErr error
+	}{
+		{
+			name:   "no parameters",
+			fsType: "ext4",
+			params: map[string]string{common.VolumeContextDiskSerialNumberKey: "serial"},
+		},
+		{
+			name:   "ext4 inode ratio, lazy init and label",
+			fsType: "ext4",
+			params: map[string]string{
+				common.ParameterBytesPerInodeKey: "4096",
+				common.ParameterExt4LazyInitKey:  "false",
+				common.ParameterFSLabelKey:       "datasets",
+			},
+			want: []string{"-i", "4096", "-E", "lazy_itable_init=0,lazy_journal_init=0", "-L", "datasets"},
+		},
+		{
+			name:   "default filesystem is ext4",
+			fsType: "",
+			params: map[string]string{common.ParameterBytesPerInodeKey: "8192"},
+			want:   []string{"-i", "8192"},
+		},
+		{
+			name:   "xfs reflink",
+			fsType: "xfs",
+			params: map[string]string{common.ParameterXFSReflinkKey: "true"},
+			want:   []string{"-m", "reflink=1"},
+		},
+		{
+			name:    "reflink on ext4",
+			fsType:  "ext4",
+			params:  map[string]string{common.ParameterXFSReflinkKey: "true"},
+			wantErr: common.ErrUnsupportedMkfsParameter,
+		},
+		{
+			name:    "inode ratio on xfs",
+			fsType:  "xfs",
+			params:  map[string]string{common.ParameterBytesPerInodeKey: "4096"},
+			wantErr: common.ErrUnsupportedMkfsParameter,
+		},
+		{
+			name:    "label too long for xfs",
+			fsType:  "xfs",
+			params:  map[string]string{common.ParameterFSLabelKey: "thirteen-char"},
+			wantErr: common.ErrInvalidMkfsParameter,
+		},
+		{
+			name:    "invalid inode ratio",
+			fsType:  "ext4",
+			params:  map[string]string{common.ParameterBytesPerInodeKey: "lots"},
+			wantErr: common.ErrInvalidMkfsParameter,
+		},
+		{
+			name:    "invalid boolean",
+			fsType:  "ext4",
+			params:  map[string]string{common.ParameterExt4LazyInitKey: "sometimes"},
+			wantErr: common.ErrInvalidMkfsParameter,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := common.MkfsOptions(tt.fsType, tt.params)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected options %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestVolumeFormatPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		volumeContext map[string]string
+		want          common.FormatPolicy
+		wantErr       error
+	}{
+		{
+			name:          "provisioned by the driver",
+			volumeContext: map[string]string{common.VolumeContextProvisionedKey: "true"},
+			want:          common.FormatUnwritten,
+		},
+		{name: "not provisioned by the driver", volumeContext: map[string]string{}, want: common.FormatNever},
+		{
+			name: "force format",
+			volumeContext: map[string]string{
+				common.VolumeContextProvisionedKey: "true",
+				common.ParameterForceFormatKey:     "true",
+			},
+			want: common.FormatForce,
+		},
+		{
+			name:          "invalid force format",
+			volumeContext: map[string]string{common.ParameterForceFormatKey: "yes please"},
+			wantErr:       common.ErrInvalidMkfsParameter,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := common.VolumeFormatPolicy(tt.volumeContext)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if got != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// StorageClass parameters tuning how shared volumes are mounted over NFS.
+// They are validated by the controller and passed to the node in the volume context.
+// Options which the driver sets by default are overridden by these, and these by the PV's mountOptions.
+const (
+	ParameterNFSVersionKey  = "csi.crusoe.ai/nfs-version"
+	ParameterNFSNconnectKey = "csi.crusoe.ai/nfs-nconnect"
+	ParameterNFSRsizeKey    = "csi.crusoe.ai/nfs-rsize"
+	ParameterNFSWsizeKey    = "csi.crusoe.ai/nfs-wsize"
+	ParameterNFSProtoKey    = "csi.crusoe.ai/nfs-proto"
+	// ParameterNFSMountOptionsKey holds further comma separated options from nfsMountOptionAllowlist.
+	ParameterNFSMountOptionsKey = "csi.crusoe.ai/nfs-mount-options"
+)
+
+const (
+	NFSVersion3  = "3"
+	NFSVersion41 = "4.1"
+
+	// DefaultNFSVersion is used when neither the volume nor its mount flags set one.
+	DefaultNFSVersion = NFSVersion3
+
+	minNconnect   = 1
+	maxNconnect   = 16
+	nfsIOSizeUnit = 4096
+	maxNFSIOSize  = 1024 * 1024
+)
+
+var (
+	ErrInvalidNFSMountParameter  = errors.New("invalid NFS mount parameter")
+	ErrUnsupportedNFSMountOption = errors.New("NFS mount option is not allowed")
+)
+
+//nolint:gochecknoglobals // can't construct const slice
+var nfsMountParameterKeys = []string{
+	ParameterNFSVersionKey,
+	ParameterNFSNconnectKey,
+	ParameterNFSRsizeKey,
+	ParameterNFSWsizeKey,
+	ParameterNFSProtoKey,
+	ParameterNFSMountOptionsKey,
+}
+
+//nolint:gochecknoglobals // can't construct const slice
+var (
+	nfsVersions = []string{NFSVersion3, NFSVersion41}
+	nfsProtos   = []string{"tcp", "rdma"}
+)
+
+// nfsMountOptionAllowlist are the options, without their values, which ParameterNFSMountOptionsKey may set.
+// Options which change the security of the mount, such as sec, are deliberately missing, as are options
+// with a validated parameter of their own, such as vers, and options choosing the server, such as port
+// and remoteports, which the NFS target resolvers set.
+//
+//nolint:gochecknoglobals // can't construct const map
+var nfsMountOptionAllowlist = map[string]struct{}{
+	"hard": {}, "soft": {}, "timeo": {}, "retrans": {},
+	"ac": {}, "noac": {}, "actimeo": {}, "acregmin": {}, "acregmax": {}, "acdirmin": {}, "acdirmax": {},
+	"lookupcache": {}, "nocto": {}, "noatime": {}, "nodiratime": {}, "relatime": {},
+	// VAST NFS client options
+	"localports": {}, "spread_reads": {}, "spread_writes": {}, "nosharecache": {},
+	"noidlexprt": {}, "forcerdirplus": {},
+}
+
+// NFSMountParameters returns the NFS mount parameters in params.
+func NFSMountParameters(params map[string]string) map[string]string {
+	nfsParams := make(map[string]string)
+
+	for _, key := range nfsMountParameterKeys {
+		if value, ok := params[key]; ok {
+			nfsParams[key] = value
+		}
+	}
+
+	return nfsParams
+}
+
+// NFSMountOptions translates the NFS mount parameters in params to mount options.
+// Options are returned in the order of the parameters, followed by ParameterNFSMountOptionsKey.
+//
+//nolint:cyclop // one case per parameter
+func NFSMountOptions(params map[string]string) ([]string, error) {
+	var options []string
+
+	for _, key := range nfsMountParameterKeys {
+		value, ok := params[key]
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case ParameterNFSVersionKey:
+			if !slices.Contains(nfsVersions, value) {
+				return nil, fmt.Errorf("%w: %s must be one of %s, got %q",
+					ErrInvalidNFSMountParameter, key, strings.Join(nfsVersions, ", "), value)
+			}
+
+			options = append(options, "vers="+value)
+		case ParameterNFSNconnectKey:
+			if err := validateIntParameter(key, value, minNconnect, maxNconnect, 1); err != nil {
+				return nil, err
+			}
+
+			options = append(options, "nconnect="+value)
+		case ParameterNFSRsizeKey, ParameterNFSWsizeKey:
+			if err := validateIntParameter(key, value, nfsIOSizeUnit, maxNFSIOSize, nfsIOSizeUnit); err != nil {
+				return nil, err
+			}
+
+			name := "rsize"
+			if key == ParameterNFSWsizeKey {
+				name = "wsize"
+			}
+
+			options = append(options, name+"="+value)
+		case ParameterNFSProtoKey:
+			if !slices.Contains(nfsProtos, value) {
+				return nil, fmt.Errorf("%w: %s must be one of %s, got %q",
+					ErrInvalidNFSMountParameter, key, strings.Join(nfsProtos, ", "), value)
+			}
+
+			options = append(options, "proto="+value)
+		case ParameterNFSMountOptionsKey:
+			extraOptions, err := parseNFSMountOptions(value)
+			if err != nil {
+				return nil, err
+			}
+
+			options = append(options, extraOptions...)
+		default:
+			// nfsMountParameterKeys and this switch are intended to match, reaching this case is a bug
+			panic(fmt.Sprintf("Switch is intended to be exhaustive, %s is not a valid switch case", key))
+		}
+	}
+
+	return options, nil
+}
+
+// parseNFSMountOptions splits a comma separated list of options and checks each against the allowlist.
+func parseNFSMountOptions(value string) ([]string, error) {
+	var options []string
+
+	for _, option := range strings.Split(value, ",") {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(option, "=")
+		if _, ok := nfsMountOptionAllowlist[name]; !ok {
+			return nil, fmt.Errorf("%w: %s in %s", ErrUnsupportedNFSMountOption, name, ParameterNFSMountOptionsKey)
+		}
+
+		options = append(options, option)
+	}
+
+	return options, nil
+}
+
+func validateIntParameter(key, value string, minValue, maxValue, multipleOf int) error {
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < minValue || parsed > maxValue || parsed%multipleOf != 0 {
+		return fmt.Errorf("%w: %s must be a multiple of %d between %d and %d, got %q",
+			ErrInvalidNFSMountParameter, key, multipleOf, minValue, maxValue, value)
+	}
+
+	return nil
+}
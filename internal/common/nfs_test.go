@@ -0,0 +1,109 @@
+package common_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/crusoecloud/crusoe-csi-driver/internal/common"
+)
+
+func TestNFSMountOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		params  map[string]string
+		want    []string
+		wantErr error
+	}{
+		{
+			name:   "no parameters",
+			params: map[string]string{common.VolumeContextDiskNameKey: "shared"},
+		},
+		{
+			name: "every parameter",
+			params: map[string]string{
+				common.ParameterNFSMountOptionsKey: "hard,timeo=600",
+				common.ParameterNFSProtoKey:        "rdma",
+				common.ParameterNFSWsizeKey:        "524288",
+				common.ParameterNFSRsizeKey:        "1048576",
+				common.ParameterNFSNconnectKey:     "4",
+				common.ParameterNFSVersionKey:      "4.1",
+			},
+			want: []string{
+				"vers=4.1", "nconnect=4", "rsize=1048576", "wsize=524288", "proto=rdma", "hard", "timeo=600",
+			},
+		},
+		{
+			name:    "unsupported version",
+			params:  map[string]string{common.ParameterNFSVersionKey: "2"},
+			wantErr: common.ErrInvalidNFSMountParameter,
+		},
+		{
+			name:    "nconnect above the client limit",
+			params:  map[string]string{common.ParameterNFSNconnectKey: "32"},
+			wantErr: common.ErrInvalidNFSMountParameter,
+		},
+		{
+			name:    "rsize not a multiple of the page size",
+			params:  map[string]string{common.ParameterNFSRsizeKey: "1000"},
+			wantErr: common.ErrInvalidNFSMountParameter,
+		},
+		{
+			name:    "unknown protocol",
+			params:  map[string]string{common.ParameterNFSProtoKey: "udp"},
+			wantErr: common.ErrInvalidNFSMountParameter,
+		},
+		{
+			name:    "option outside the allowlist",
+			params:  map[string]string{common.ParameterNFSMountOptionsKey: "noatime,sec=none"},
+			wantErr: common.ErrUnsupportedNFSMountOption,
+		},
+		{
+			name:    "version bypassing its parameter",
+			params:  map[string]string{common.ParameterNFSMountOptionsKey: "noatime,nfsvers=2"},
+			wantErr: common.ErrUnsupportedNFSMountOption,
+		},
+		{
+			name:    "nconnect bypassing its parameter",
+			params:  map[string]string{common.ParameterNFSMountOptionsKey: "nconnect=64"},
+			wantErr: common.ErrUnsupportedNFSMountOption,
+		},
+		{
+			name:    "rsize bypassing its parameter",
+			params:  map[string]string{common.ParameterNFSMountOptionsKey: "rsize=1000"},
+			wantErr: common.ErrUnsupportedNFSMountOption,
+		},
+		{
+			name:    "protocol bypassing its parameter",
+			params:  map[string]string{common.ParameterNFSMountOptionsKey: "proto=udp"},
+			wantErr: common.ErrUnsupportedNFSMountOption,
+		},
+		{
+			name:    "port",
+			params:  map[string]string{common.ParameterNFSMountOptionsKey: "port=2050"},
+			wantErr: common.ErrUnsupportedNFSMountOption,
+		},
+		{
+			name:    "remote ports overriding the NFS target",
+			params:  map[string]string{common.ParameterNFSMountOptionsKey: "remoteports=10.0.0.1-10.0.0.2"},
+			wantErr: common.ErrUnsupportedNFSMountOption,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := common.NFSMountOptions(tt.params)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected options %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	crusoeapi "github.com/crusoecloud/client-go/swagger/v1alpha5"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/tracing"
 )
 
 type OpStatus string
@@ -64,6 +65,18 @@ func AwaitOperation(ctx context.Context, op *crusoeapi.Operation, projectID stri
 	getOp func(ctx context.Context, projectID string, operationID string) (crusoeapi.Operation, *http.Response, error),
 ) (
 	*crusoeapi.Operation, error,
+) {
+	ctx, span := tracing.Start(ctx, "AwaitOperation", tracing.OperationIDKey.String(op.OperationId))
+	completedOp, err := awaitOperation(ctx, op, projectID, getOp)
+	tracing.End(span, err)
+
+	return completedOp, err
+}
+
+func awaitOperation(ctx context.Context, op *crusoeapi.Operation, projectID string,
+	getOp func(ctx context.Context, projectID string, operationID string) (crusoeapi.Operation, *http.Response, error),
+) (
+	*crusoeapi.Operation, error,
 ) {
 	timeoutCtx, cancel := context.WithTimeout(ctx, OperationTimeout)
 	defer cancel()
@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"math"
 
 	"github.com/crusoecloud/crusoe-csi-driver/internal/crusoe"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/events"
 
 	"github.com/crusoecloud/crusoe-csi-driver/internal/common"
 
@@ -14,6 +16,7 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/wrapperspb"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/klog/v2"
 
 	crusoeapi "github.com/crusoecloud/client-go/swagger/v1alpha5"
@@ -23,10 +26,14 @@ type DefaultController struct {
 	csi.UnimplementedControllerServer
 	CrusoeClient  *crusoeapi.APIClient
 	HostInstance  *crusoeapi.InstanceV1Alpha5
+	Events        events.Recorder
 	DiskType      common.DiskType
 	PluginName    string
 	PluginVersion string
 	Capabilities  []*csi.ControllerServiceCapability
+	// OnlineExpansion allows common.DiskTypeSSD volumes to be expanded while attached.
+	// The filesystem is then grown by NodeExpandVolume.
+	OnlineExpansion bool
 }
 
 //nolint:funlen,cyclop // function is already fairly clean
@@ -34,8 +41,6 @@ func (d *DefaultController) CreateVolume(ctx context.Context, request *csi.Creat
 	*csi.CreateVolumeResponse,
 	error,
 ) {
-	klog.Infof("Received request to create volume: %+v", request)
-
 	err := validateDiskRequest(request, d.DiskType)
 	if err != nil {
 		return nil, err // validateDiskRequest returns only status.Errors so we can return the error directly
@@ -52,7 +57,7 @@ func (d *DefaultController) CreateVolume(ctx context.Context, request *csi.Creat
 	existingDisk, err := crusoe.FindDiskByNameFallible(ctx, d.CrusoeClient, d.HostInstance.ProjectId, request.GetName())
 	if err != nil {
 		if !errors.Is(err, crusoe.ErrDiskNotFound) {
-			klog.Errorf("failed to check if disk exists: %s", err)
+			klog.ErrorS(err, "Failed to check if disk exists", common.LogKeyDiskName, request.GetName())
 
 			return nil, status.Errorf(codes.Internal, "failed to check if disk exists: %s", err)
 		}
@@ -60,7 +65,14 @@ func (d *DefaultController) CreateVolume(ctx context.Context, request *csi.Creat
 
 	diskLocation, requireSupportsFS := parseRequiredTopology(request, d.DiskType, d.PluginName, d.HostInstance)
 	if d.DiskType == common.DiskTypeFS && !requireSupportsFS {
-		klog.Errorf("shared disk requested but could not find topology constraint with %s and %s segments",
+		klog.ErrorS(nil, "Shared disk requested but could not find topology constraint",
+			common.LogKeyDiskName, request.GetName(),
+			"requiredSegments", []string{
+				common.GetTopologyKey(d.PluginName, common.TopologyLocationKey),
+				common.GetTopologyKey(d.PluginName, common.TopologySupportsSharedDisksKey),
+			})
+		d.provisioningFailedEventf(request,
+			"shared disk requested but could not find topology constraint with %s and %s segments",
 			common.GetTopologyKey(d.PluginName, common.TopologyLocationKey),
 			common.GetTopologyKey(d.PluginName, common.TopologySupportsSharedDisksKey))
 
@@ -72,7 +84,9 @@ func (d *DefaultController) CreateVolume(ctx context.Context, request *csi.Creat
 
 	diskRequest, err := crusoe.GetCreateDiskRequest(request, diskLocation, d.DiskType)
 	if err != nil {
-		klog.Errorf("failed to get create disk request: %s", err)
+		klog.ErrorS(err, "Failed to get create disk request",
+			common.LogKeyDiskName, request.GetName(),
+			common.LogKeyLocation, diskLocation)
 
 		return nil, status.Errorf(codes.InvalidArgument, "failed to get create disk request: %s", err)
 	}
@@ -88,9 +102,11 @@ func (d *DefaultController) CreateVolume(ctx context.Context, request *csi.Creat
 		); diskMatchErr != nil {
 			// Disk does not match
 			// To be safe, do not modify or delete existing disk and return error
-			klog.Errorf("disk %s already exists but does not match request: %s",
-				request.GetName(),
-				diskMatchErr)
+			klog.ErrorS(diskMatchErr, "Disk already exists but does not match request",
+				common.LogKeyDiskName, request.GetName(),
+				common.LogKeyVolumeID, existingDisk.Id)
+			d.provisioningFailedEventf(request,
+				"disk %s already exists but does not match request: %s", request.GetName(), diskMatchErr)
 
 			return nil, status.Errorf(codes.AlreadyExists,
 				"disk %s already exists but does not match request: %s",
@@ -98,14 +114,19 @@ func (d *DefaultController) CreateVolume(ctx context.Context, request *csi.Creat
 				diskMatchErr)
 		}
 
-		klog.Infof("Disk %s already exists, skipping creation", request.GetName())
+		klog.InfoS("Disk already exists, skipping creation",
+			common.LogKeyDiskName, request.GetName(),
+			common.LogKeyVolumeID, existingDisk.Id)
 
 		disk = existingDisk
 	} else {
 		// Create the disk
 		op, _, createErr := d.CrusoeClient.DisksApi.CreateDisk(ctx, *diskRequest, d.HostInstance.ProjectId)
 		if createErr != nil {
-			klog.Errorf("failed to create disk: %s", common.UnpackSwaggerErr(createErr))
+			klog.ErrorS(common.UnpackSwaggerErr(createErr), "Failed to create disk",
+				common.LogKeyDiskName, request.GetName(),
+				common.LogKeyLocation, diskLocation)
+			d.provisioningFailedEventf(request, "failed to create disk: %s", common.UnpackSwaggerErr(createErr))
 
 			return nil, status.Errorf(codes.Internal, "failed to create disk: %s", common.UnpackSwaggerErr(createErr))
 		}
@@ -116,8 +137,11 @@ func (d *DefaultController) CreateVolume(ctx context.Context, request *csi.Creat
 			d.HostInstance.ProjectId,
 			d.CrusoeClient.DiskOperationsApi.GetStorageDisksOperation)
 		if getResultErr != nil {
-			klog.Errorf("failed to get result of disk creation: %s",
-				common.UnpackSwaggerErr(getResultErr))
+			klog.ErrorS(common.UnpackSwaggerErr(getResultErr), "Failed to get result of disk creation",
+				common.LogKeyDiskName, request.GetName(),
+				common.LogKeyOperationID, op.Operation.OperationId)
+			d.provisioningFailedEventf(request,
+				"failed to get result of disk creation: %s", common.UnpackSwaggerErr(getResultErr))
 
 			return nil, status.Errorf(codes.Internal,
 				"failed to get result of disk creation: %s",
@@ -129,43 +153,79 @@ func (d *DefaultController) CreateVolume(ctx context.Context, request *csi.Creat
 
 	volume, convertDiskErr := crusoe.GetVolumeFromDisk(disk, d.PluginName, diskLocation, d.DiskType)
 	if convertDiskErr != nil {
-		klog.Errorf("failed to convert crusoe disk to kubernetes volume: %s", convertDiskErr)
+		klog.ErrorS(convertDiskErr, "Failed to convert crusoe disk to kubernetes volume",
+			common.LogKeyDiskName, disk.Name,
+			common.LogKeyVolumeID, disk.Id)
 
 		return nil, status.Errorf(codes.Internal, "failed to convert crusoe disk to kubernetes volume: %s", convertDiskErr)
 	}
 
-	klog.Infof("Created volume: %+v", volume)
+	// Filesystem creation and encryption parameters are applied by the node when it first formats the volume,
+	// NFS mount parameters whenever it mounts the volume
+	maps.Copy(volume.VolumeContext, common.MkfsParameters(request.GetParameters()))
+	maps.Copy(volume.VolumeContext, common.NFSMountParameters(request.GetParameters()))
+	for _, key := range []string{
+		common.ParameterEncryptedKey,
+		common.ParameterForceFormatKey,
+		common.ParameterFsckPolicyKey,
+	} {
+		if value, ok := request.GetParameters()[key]; ok {
+			volume.VolumeContext[key] = value
+		}
+	}
+	// Unencrypted raw block volumes are never formatted by the node, encryption was validated with the request
+	encrypted, _ := common.IsEncrypted(request.GetParameters())
+	if encrypted || isFilesystemVolume(request.GetVolumeCapabilities()) {
+		volume.VolumeContext[common.VolumeContextProvisionedKey] = "true"
+	}
+
+	klog.InfoS("Created volume",
+		common.LogKeyVolumeID, volume.GetVolumeId(),
+		common.LogKeyDiskName, disk.Name,
+		common.LogKeyLocation, diskLocation)
 
 	return &csi.CreateVolumeResponse{
 		Volume: volume,
 	}, nil
 }
 
+// provisioningFailedEventf records a provisioning failure on the PVC which requested the volume.
+// The PVC is only known when the external-provisioner passes it in the request parameters.
+func (d *DefaultController) provisioningFailedEventf(request *csi.CreateVolumeRequest,
+	messageFmt string,
+	args ...any,
+) {
+	d.Events.ClaimEventf(request.GetParameters()[common.ParameterPVCNamespaceKey],
+		request.GetParameters()[common.ParameterPVCNameKey],
+		corev1.EventTypeWarning,
+		events.ReasonProvisioningFailed,
+		messageFmt,
+		args...)
+}
+
 func (d *DefaultController) DeleteVolume(ctx context.Context,
 	request *csi.DeleteVolumeRequest) (
 	*csi.DeleteVolumeResponse,
 	error,
 ) {
-	klog.Infof("Received request to delete volume: %+v", request)
-
 	// Check if the disk exists
 	existingDisk, err := crusoe.FindDiskByIDFallible(ctx, d.CrusoeClient, d.HostInstance.ProjectId, request.GetVolumeId())
 	if errors.Is(err, crusoe.ErrDiskNotFound) {
 		// Disk does not exist
-		klog.Infof("Disk %s is already deleted, skipping deletion", request.GetVolumeId())
+		klog.InfoS("Disk is already deleted, skipping deletion", common.LogKeyVolumeID, request.GetVolumeId())
 
 		return &csi.DeleteVolumeResponse{}, nil
 	} else if err != nil {
-		klog.Errorf("failed to check if disk %s exists: %s", request.GetVolumeId(), err)
+		klog.ErrorS(err, "Failed to check if disk exists", common.LogKeyVolumeID, request.GetVolumeId())
 
 		return nil, status.Errorf(codes.FailedPrecondition, "failed to check if disk %s exists: %s",
 			request.GetVolumeId(), err)
 	}
 
 	if len(existingDisk.AttachedTo) > 0 {
-		klog.Errorf("disk %s is still attached to instance(s): %v",
-			request.GetVolumeId(),
-			existingDisk.AttachedTo)
+		klog.ErrorS(nil, "Disk is still attached to instance(s)",
+			common.LogKeyVolumeID, request.GetVolumeId(),
+			"attachedTo", existingDisk.AttachedTo)
 
 		return nil, status.Errorf(codes.FailedPrecondition,
 			"disk %s is still attached to instance(s): %v",
@@ -175,7 +235,7 @@ func (d *DefaultController) DeleteVolume(ctx context.Context,
 
 	op, _, err := d.CrusoeClient.DisksApi.DeleteDisk(ctx, d.HostInstance.ProjectId, request.GetVolumeId())
 	if err != nil {
-		klog.Errorf("failed to delete disk %s: %s", request.GetVolumeId(), common.UnpackSwaggerErr(err))
+		klog.ErrorS(common.UnpackSwaggerErr(err), "Failed to delete disk", common.LogKeyVolumeID, request.GetVolumeId())
 
 		return nil, status.Errorf(codes.Internal, "failed to delete disk %s: %s",
 			request.GetVolumeId(), common.UnpackSwaggerErr(err))
@@ -186,9 +246,9 @@ func (d *DefaultController) DeleteVolume(ctx context.Context,
 		d.HostInstance.ProjectId,
 		d.CrusoeClient.DiskOperationsApi.GetStorageDisksOperation)
 	if awaitErr != nil {
-		klog.Errorf("failed to get result of disk deletion for disk %s: %s",
-			request.GetVolumeId(),
-			common.UnpackSwaggerErr(awaitErr))
+		klog.ErrorS(common.UnpackSwaggerErr(awaitErr), "Failed to get result of disk deletion",
+			common.LogKeyVolumeID, request.GetVolumeId(),
+			common.LogKeyOperationID, op.Operation.OperationId)
 
 		return nil, status.Errorf(codes.Internal,
 			"failed to get result of disk deletion for disk %s: %s",
@@ -196,7 +256,7 @@ func (d *DefaultController) DeleteVolume(ctx context.Context,
 			common.UnpackSwaggerErr(awaitErr))
 	}
 
-	klog.Infof("Deleted volume: %+v", request)
+	klog.InfoS("Deleted volume", common.LogKeyVolumeID, request.GetVolumeId())
 
 	return &csi.DeleteVolumeResponse{}, nil
 }
@@ -206,8 +266,6 @@ func (d *DefaultController) ControllerPublishVolume(ctx context.Context,
 	*csi.ControllerPublishVolumeResponse,
 	error,
 ) {
-	klog.Infof("Received request to publish volume: %+v", request)
-
 	// Check if the disk is already attached to the instance
 	attached, err := crusoe.CheckDiskAttached(ctx,
 		d.CrusoeClient,
@@ -215,14 +273,18 @@ func (d *DefaultController) ControllerPublishVolume(ctx context.Context,
 		request.GetNodeId(),
 		d.HostInstance.ProjectId)
 	if err != nil {
-		klog.Errorf("failed to check if disk %s is attached to instance: %s", request.GetVolumeId(), err)
+		klog.ErrorS(err, "Failed to check if disk is attached to instance",
+			common.LogKeyVolumeID, request.GetVolumeId(),
+			common.LogKeyNodeID, request.GetNodeId())
 
 		return nil, status.Errorf(codes.NotFound, "failed to check if disk %s is attached to instance: %s",
 			request.GetVolumeId(), err)
 	}
 
 	if attached {
-		klog.Infof("Disk %s is already attached to instance %s, skipping publish", request.GetVolumeId(), request.GetNodeId())
+		klog.InfoS("Disk is already attached to instance, skipping publish",
+			common.LogKeyVolumeID, request.GetVolumeId(),
+			common.LogKeyNodeID, request.GetNodeId())
 
 		return &csi.ControllerPublishVolumeResponse{}, nil
 	}
@@ -245,7 +307,11 @@ func (d *DefaultController) ControllerPublishVolume(ctx context.Context,
 		},
 	}, d.HostInstance.ProjectId, request.GetNodeId())
 	if err != nil {
-		klog.Errorf("failed to attach disk %s: %s", request.GetVolumeId(), err)
+		klog.ErrorS(err, "Failed to attach disk",
+			common.LogKeyVolumeID, request.GetVolumeId(),
+			common.LogKeyNodeID, request.GetNodeId())
+		d.Events.VolumeEventf(request.GetVolumeId(), corev1.EventTypeWarning, events.ReasonAttachFailed,
+			"failed to attach disk to instance %s: %s", request.GetNodeId(), err)
 
 		return nil, status.Errorf(codes.Internal, "failed to attach disk %s: %s", request.GetVolumeId(), err)
 	}
@@ -255,14 +321,21 @@ func (d *DefaultController) ControllerPublishVolume(ctx context.Context,
 		d.HostInstance.ProjectId,
 		d.CrusoeClient.VMOperationsApi.GetComputeVMsInstancesOperation)
 	if err != nil {
-		klog.Errorf("failed to get result of disk attachment for disk %s: %s", request.GetVolumeId(), err)
+		klog.ErrorS(err, "Failed to get result of disk attachment",
+			common.LogKeyVolumeID, request.GetVolumeId(),
+			common.LogKeyNodeID, request.GetNodeId(),
+			common.LogKeyOperationID, op.Operation.OperationId)
+		d.Events.VolumeEventf(request.GetVolumeId(), corev1.EventTypeWarning, events.ReasonAttachFailed,
+			"failed to attach disk to instance %s: %s", request.GetNodeId(), err)
 
 		return nil, status.Errorf(codes.Internal, "failed to get result of disk attachment for disk %s: %s",
 			request.GetVolumeId(),
 			err)
 	}
 
-	klog.Infof("Published volume: %+v", request)
+	klog.InfoS("Published volume",
+		common.LogKeyVolumeID, request.GetVolumeId(),
+		common.LogKeyNodeID, request.GetNodeId())
 
 	return &csi.ControllerPublishVolumeResponse{}, nil
 }
@@ -272,8 +345,6 @@ func (d *DefaultController) ControllerUnpublishVolume(ctx context.Context,
 	*csi.ControllerUnpublishVolumeResponse,
 	error,
 ) {
-	klog.Infof("Received request to unpublish volume: %+v", request)
-
 	// Check if the disk is already detached from the instance
 	attached, err := crusoe.CheckDiskAttached(ctx,
 		d.CrusoeClient,
@@ -283,14 +354,18 @@ func (d *DefaultController) ControllerUnpublishVolume(ctx context.Context,
 	if err != nil {
 		if errors.Is(err, crusoe.ErrInstanceNotFound) {
 			// Instance does not exist
-			klog.Infof("Parent instance %s is already deleted, skipping unpublish for disk %s",
-				request.GetVolumeId(),
-				request.GetNodeId())
+			klog.InfoS("Parent instance is already deleted, skipping unpublish",
+				common.LogKeyVolumeID, request.GetVolumeId(),
+				common.LogKeyNodeID, request.GetNodeId())
+			d.Events.VolumeEventf(request.GetVolumeId(), corev1.EventTypeWarning, events.ReasonOrphanDetected,
+				"volume attachment references deleted instance %s", request.GetNodeId())
 
 			return &csi.ControllerUnpublishVolumeResponse{}, nil
 		}
 
-		klog.Errorf("failed to check if disk %s is attached to instance: %s", request.GetVolumeId(), err)
+		klog.ErrorS(err, "Failed to check if disk is attached to instance",
+			common.LogKeyVolumeID, request.GetVolumeId(),
+			common.LogKeyNodeID, request.GetNodeId())
 
 		return nil, status.Errorf(codes.NotFound, "failed to check if disk %s is attached to instance: %s",
 			request.GetVolumeId(),
@@ -298,10 +373,9 @@ func (d *DefaultController) ControllerUnpublishVolume(ctx context.Context,
 	}
 
 	if !attached {
-		klog.Infof(
-			"Disk %s is already detached from instance %s, skipping unpublish",
-			request.GetVolumeId(),
-			request.GetNodeId())
+		klog.InfoS("Disk is already detached from instance, skipping unpublish",
+			common.LogKeyVolumeID, request.GetVolumeId(),
+			common.LogKeyNodeID, request.GetNodeId())
 
 		return &csi.ControllerUnpublishVolumeResponse{}, nil
 	}
@@ -312,9 +386,11 @@ func (d *DefaultController) ControllerUnpublishVolume(ctx context.Context,
 		},
 	}, d.HostInstance.ProjectId, request.GetNodeId())
 	if err != nil {
-		klog.Errorf("failed to detach disk %s: %s",
-			request.GetVolumeId(),
-			err)
+		klog.ErrorS(err, "Failed to detach disk",
+			common.LogKeyVolumeID, request.GetVolumeId(),
+			common.LogKeyNodeID, request.GetNodeId())
+		d.Events.VolumeEventf(request.GetVolumeId(), corev1.EventTypeWarning, events.ReasonDetachFailed,
+			"failed to detach disk from instance %s: %s", request.GetNodeId(), err)
 
 		return nil, status.Errorf(codes.Internal, "failed to detach disk %s: %s",
 			request.GetVolumeId(),
@@ -326,16 +402,21 @@ func (d *DefaultController) ControllerUnpublishVolume(ctx context.Context,
 		d.HostInstance.ProjectId,
 		d.CrusoeClient.VMOperationsApi.GetComputeVMsInstancesOperation)
 	if err != nil {
-		klog.Errorf("failed to get result of disk detachment for disk %s: %s",
-			request.GetVolumeId(),
-			err)
+		klog.ErrorS(err, "Failed to get result of disk detachment",
+			common.LogKeyVolumeID, request.GetVolumeId(),
+			common.LogKeyNodeID, request.GetNodeId(),
+			common.LogKeyOperationID, op.Operation.OperationId)
+		d.Events.VolumeEventf(request.GetVolumeId(), corev1.EventTypeWarning, events.ReasonDetachFailed,
+			"failed to detach disk from instance %s: %s", request.GetNodeId(), err)
 
 		return nil, status.Errorf(codes.Internal, "failed to get result of disk detachment for disk %s: %s",
 			request.GetVolumeId(),
 			err)
 	}
 
-	klog.Infof("Unpublished volume: %+v", request)
+	klog.InfoS("Unpublished volume",
+		common.LogKeyVolumeID, request.GetVolumeId(),
+		common.LogKeyNodeID, request.GetNodeId())
 
 	return &csi.ControllerUnpublishVolumeResponse{}, nil
 }
@@ -419,21 +500,19 @@ func (d *DefaultController) ControllerExpandVolume(ctx context.Context, request
 	*csi.ControllerExpandVolumeResponse,
 	error,
 ) {
-	klog.Infof("Received request to expand volume: %+v", request)
-
 	// Find the existing disk
 	existingDisk, err := crusoe.FindDiskByIDFallible(ctx, d.CrusoeClient, d.HostInstance.ProjectId, request.GetVolumeId())
 	if err != nil {
-		klog.Errorf("failed to find disk %s: %s", request.GetVolumeId(), err)
+		klog.ErrorS(err, "Failed to find disk", common.LogKeyVolumeID, request.GetVolumeId())
 
 		return nil, status.Errorf(codes.NotFound, "failed to find disk %s: %s", request.GetVolumeId(), err)
 	}
 
-	// Only common.DiskTypeFS volumes can be expanded online
-	if d.DiskType != common.DiskTypeFS && len(existingDisk.AttachedTo) != 0 {
-		klog.Errorf("offline volume expansion failed: volume %s is attached to one or more nodes: %v",
-			request.GetVolumeId(),
-			existingDisk.AttachedTo)
+	// common.DiskTypeFS volumes can always be expanded online, common.DiskTypeSSD volumes only if enabled
+	if d.DiskType != common.DiskTypeFS && !d.OnlineExpansion && len(existingDisk.AttachedTo) != 0 {
+		klog.ErrorS(nil, "Offline volume expansion failed: volume is attached to one or more nodes",
+			common.LogKeyVolumeID, request.GetVolumeId(),
+			"attachedTo", existingDisk.AttachedTo)
 
 		return nil, status.Errorf(
 			codes.FailedPrecondition,
@@ -444,7 +523,7 @@ func (d *DefaultController) ControllerExpandVolume(ctx context.Context, request
 
 	existingSizeGiB, err := crusoe.NormalizeDiskSizeToGiB(existingDisk)
 	if err != nil {
-		klog.Errorf("failed to normalize disk size for disk %s: %s", request.GetVolumeId(), err)
+		klog.ErrorS(err, "Failed to normalize disk size", common.LogKeyVolumeID, request.GetVolumeId())
 
 		return nil, status.Errorf(codes.Internal, "failed to normalize disk size: %s", err)
 	}
@@ -453,13 +532,13 @@ func (d *DefaultController) ControllerExpandVolume(ctx context.Context, request
 	// and to return an accurate error message
 	requestSizeBytes, err := common.RequestSizeToBytes(request.GetCapacityRange())
 	if err != nil {
-		klog.Errorf("failed to get request size for disk %s: %s", request.GetVolumeId(), err)
+		klog.ErrorS(err, "Failed to get request size", common.LogKeyVolumeID, request.GetVolumeId())
 
 		return nil, status.Errorf(codes.OutOfRange, "failed to get request size: %s", err)
 	}
 	requestSizeGiB, err := common.RequestSizeToGiB(request.GetCapacityRange())
 	if err != nil {
-		klog.Errorf("failed to get request size for disk %s: %s", request.GetVolumeId(), err)
+		klog.ErrorS(err, "Failed to get request size", common.LogKeyVolumeID, request.GetVolumeId())
 
 		return nil, status.Errorf(codes.OutOfRange, "failed to get request size: %s", err)
 	}
@@ -467,16 +546,20 @@ func (d *DefaultController) ControllerExpandVolume(ctx context.Context, request
 	maxSizeBytes, minSizeBytes := getCapacity(d.DiskType)
 
 	if requestSizeBytes > maxSizeBytes {
-		klog.Errorf("%s: maximum size: %d, requested size: %d for disk %s",
-			errDiskTooLarge, maxSizeBytes, requestSizeBytes, request.GetVolumeId())
+		klog.ErrorS(errDiskTooLarge, "Requested size exceeds maximum",
+			common.LogKeyVolumeID, request.GetVolumeId(),
+			"maxSizeBytes", maxSizeBytes,
+			"requestSizeBytes", requestSizeBytes)
 
 		return nil, status.Errorf(codes.OutOfRange, "%s: maximum size: %d, requested size: %d",
 			errDiskTooLarge, maxSizeBytes, requestSizeBytes)
 	}
 
 	if requestSizeBytes < minSizeBytes {
-		klog.Errorf("%s: minimum size: %d, requested size: %d for disk %s",
-			errDiskTooSmall, minSizeBytes, requestSizeBytes, request.GetVolumeId())
+		klog.ErrorS(errDiskTooSmall, "Requested size is below minimum",
+			common.LogKeyVolumeID, request.GetVolumeId(),
+			"minSizeBytes", minSizeBytes,
+			"requestSizeBytes", requestSizeBytes)
 
 		return nil, status.Errorf(codes.OutOfRange, "%s: minimum size: %d, requested size: %d",
 			errDiskTooSmall, minSizeBytes, requestSizeBytes)
@@ -485,13 +568,13 @@ func (d *DefaultController) ControllerExpandVolume(ctx context.Context, request
 	// requestSizeGiB is the actual size that is sent to the Crusoe API in the resize request
 	existingSizeBytes := int64(existingSizeGiB) * common.NumBytesInGiB
 	if existingSizeBytes >= requestSizeBytes {
-		klog.Infof("Disk %s is already at or above the requested size %d GiB, skipping resize",
-			request.GetVolumeId(),
-			request.GetCapacityRange().GetRequiredBytes()/common.NumBytesInGiB)
+		klog.InfoS("Disk is already at or above the requested size, skipping resize",
+			common.LogKeyVolumeID, request.GetVolumeId(),
+			"requestSizeGiB", request.GetCapacityRange().GetRequiredBytes()/common.NumBytesInGiB)
 
 		return &csi.ControllerExpandVolumeResponse{
 			CapacityBytes:         existingSizeBytes,
-			NodeExpansionRequired: false,
+			NodeExpansionRequired: d.nodeExpansionRequired(request.GetVolumeCapability()),
 		}, nil
 	}
 
@@ -499,7 +582,7 @@ func (d *DefaultController) ControllerExpandVolume(ctx context.Context, request
 		Size: fmt.Sprintf("%dGiB", requestSizeGiB),
 	}, d.HostInstance.ProjectId, request.GetVolumeId())
 	if err != nil {
-		klog.Errorf("failed to resize disk %s: %s", request.GetVolumeId(), common.UnpackSwaggerErr(err))
+		klog.ErrorS(common.UnpackSwaggerErr(err), "Failed to resize disk", common.LogKeyVolumeID, request.GetVolumeId())
 
 		return nil, status.Errorf(codes.Internal, "failed to resize disk: %s", common.UnpackSwaggerErr(err))
 	}
@@ -509,22 +592,29 @@ func (d *DefaultController) ControllerExpandVolume(ctx context.Context, request
 		d.HostInstance.ProjectId,
 		d.CrusoeClient.DiskOperationsApi.GetStorageDisksOperation)
 	if err != nil {
-		klog.Errorf("failed to get result of disk resize for disk %s: %s",
-			request.GetVolumeId(),
-			common.UnpackSwaggerErr(err))
+		klog.ErrorS(common.UnpackSwaggerErr(err), "Failed to get result of disk resize",
+			common.LogKeyVolumeID, request.GetVolumeId(),
+			common.LogKeyOperationID, op.Operation.OperationId)
 
 		return nil, status.Errorf(codes.Internal,
 			"failed to get result of disk resize: %s", common.UnpackSwaggerErr(err))
 	}
 
-	klog.Infof("Resized volume %s to %d GiB", request.GetVolumeId(), requestSizeGiB)
+	klog.InfoS("Resized volume", common.LogKeyVolumeID, request.GetVolumeId(), "sizeGiB", requestSizeGiB)
 
 	return &csi.ControllerExpandVolumeResponse{
 		CapacityBytes:         int64(requestSizeGiB) * common.NumBytesInGiB,
-		NodeExpansionRequired: false,
+		NodeExpansionRequired: d.nodeExpansionRequired(request.GetVolumeCapability()),
 	}, nil
 }
 
+// nodeExpansionRequired reports whether the filesystem must be grown on the node after the disk is resized.
+// common.DiskTypeFS filesystems are grown by the storage backend, and block volumes have no filesystem.
+// Without online expansion, common.DiskTypeSSD filesystems are grown when the volume is next staged.
+func (d *DefaultController) nodeExpansionRequired(capability *csi.VolumeCapability) bool {
+	return d.DiskType == common.DiskTypeSSD && d.OnlineExpansion && capability.GetBlock() == nil
+}
+
 func (d *DefaultController) ControllerGetVolume(_ context.Context, _ *csi.ControllerGetVolumeRequest) (
 	*csi.ControllerGetVolumeResponse,
 	error,
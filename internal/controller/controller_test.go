@@ -0,0 +1,71 @@
+package controller_test
+
+import (
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/common"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/controller"
+)
+
+func TestDefaultController_NodeExpansionRequired(t *testing.T) {
+	t.Parallel()
+
+	mountCapability := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+	}
+	blockCapability := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Block{Block: &csi.VolumeCapability_BlockVolume{}},
+	}
+
+	tests := []struct {
+		name            string
+		diskType        common.DiskType
+		onlineExpansion bool
+		capability      *csi.VolumeCapability
+		want            bool
+	}{
+		{
+			name:            "SSD filesystem with online expansion",
+			diskType:        common.DiskTypeSSD,
+			onlineExpansion: true,
+			capability:      mountCapability,
+			want:            true,
+		},
+		{
+			// Older sidecars do not send the capability, the node then grows the filesystem
+			name:            "SSD without a capability",
+			diskType:        common.DiskTypeSSD,
+			onlineExpansion: true,
+			want:            true,
+		},
+		{
+			name:            "SSD block volume",
+			diskType:        common.DiskTypeSSD,
+			onlineExpansion: true,
+			capability:      blockCapability,
+		},
+		{
+			name:       "SSD filesystem without online expansion",
+			diskType:   common.DiskTypeSSD,
+			capability: mountCapability,
+		},
+		{
+			name:            "shared filesystem",
+			diskType:        common.DiskTypeFS,
+			onlineExpansion: true,
+			capability:      mountCapability,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			d := &controller.DefaultController{DiskType: tt.diskType, OnlineExpansion: tt.onlineExpansion}
+			if got := d.NodeExpansionRequired(tt.capability); got != tt.want {
+				t.Errorf("expected %t, got %t", tt.want, got)
+			}
+		})
+	}
+}
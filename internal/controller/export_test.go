@@ -0,0 +1,8 @@
+package controller
+
+import "github.com/container-storage-interface/spec/lib/go/csi"
+
+// NodeExpansionRequired exposes nodeExpansionRequired, ControllerExpandVolume needs the Crusoe API to reach it.
+func (d *DefaultController) NodeExpansionRequired(capability *csi.VolumeCapability) bool {
+	return d.nodeExpansionRequired(capability)
+}
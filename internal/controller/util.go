@@ -184,6 +184,115 @@ func validateDiskRequest(request *csi.CreateVolumeRequest, diskType common.DiskT
 		}
 	}
 
+	if err := validateMkfsParameters(request, diskType); err != nil {
+		return err
+	}
+
+	if err := validateEncryptionParameters(request, diskType); err != nil {
+		return err
+	}
+
+	return validateNFSMountParameters(request, diskType)
+}
+
+// validateMkfsParameters checks the filesystem creation and check parameters can be applied to every filesystem
+// requested, so that invalid StorageClasses are rejected when provisioning rather than when the volume is first staged.
+func validateMkfsParameters(request *csi.CreateVolumeRequest, diskType common.DiskType) error {
+	forceFormat, err := common.IsForceFormat(request.GetParameters())
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "%s", err)
+	}
+
+	fsckPolicy, err := common.ParseFsckPolicy(request.GetParameters())
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "%s", err)
+	}
+
+	mkfsParams := common.MkfsParameters(request.GetParameters())
+	if len(mkfsParams) == 0 && !forceFormat && fsckPolicy == common.FsckPolicyDefault {
+		return nil
+	}
+
+	switch diskType {
+	case common.DiskTypeSSD:
+		for _, capability := range request.GetVolumeCapabilities() {
+			if capability.GetMount() == nil {
+				continue
+			}
+
+			if _, err := common.MkfsOptions(capability.GetMount().GetFsType(), mkfsParams); err != nil {
+				return status.Errorf(codes.InvalidArgument, "%s", err)
+			}
+		}
+	case common.DiskTypeFS:
+		return status.Errorf(codes.InvalidArgument, "%s: shared volumes are not formatted by the driver",
+			common.ErrUnsupportedMkfsParameter)
+	default:
+		// Switch is intended to be exhaustive, reaching this case is a bug
+		panic(fmt.Sprintf(
+			"Switch is intended to be exhaustive, %s is not a valid switch case", diskType))
+	}
+
+	return nil
+}
+
+// isFilesystemVolume reports whether every capability requested mounts the volume as a filesystem.
+func isFilesystemVolume(capabilities []*csi.VolumeCapability) bool {
+	for _, capability := range capabilities {
+		if capability.GetMount() == nil {
+			return false
+		}
+	}
+
+	return len(capabilities) > 0
+}
+
+// validateEncryptionParameters checks encryption is only requested for volumes which the node formats.
+func validateEncryptionParameters(request *csi.CreateVolumeRequest, diskType common.DiskType) error {
+	encrypted, err := common.IsEncrypted(request.GetParameters())
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "%s", err)
+	}
+
+	if !encrypted {
+		return nil
+	}
+
+	switch diskType {
+	case common.DiskTypeSSD:
+		return nil
+	case common.DiskTypeFS:
+		return status.Errorf(codes.InvalidArgument, "%s: shared volumes are encrypted by the storage backend",
+			common.ErrInvalidEncryptionParameter)
+	default:
+		// Switch is intended to be exhaustive, reaching this case is a bug
+		panic(fmt.Sprintf(
+			"Switch is intended to be exhaustive, %s is not a valid switch case", diskType))
+	}
+}
+
+// validateNFSMountParameters checks the NFS mount parameters are allowed, so that invalid StorageClasses
+// are rejected when provisioning rather than when the volume is first staged.
+func validateNFSMountParameters(request *csi.CreateVolumeRequest, diskType common.DiskType) error {
+	nfsParams := common.NFSMountParameters(request.GetParameters())
+	if len(nfsParams) == 0 {
+		return nil
+	}
+
+	switch diskType {
+	case common.DiskTypeSSD:
+		return status.Errorf(codes.InvalidArgument, "%s: persistent SSD volumes are not mounted over NFS",
+			common.ErrInvalidNFSMountParameter)
+	case common.DiskTypeFS:
+		if _, err := common.NFSMountOptions(nfsParams); err != nil {
+			return status.Errorf(codes.InvalidArgument, "%s", err)
+		}
+	default:
+		// Switch is intended to be exhaustive, reaching this case is a bug
+		panic(fmt.Sprintf(
+			"Switch is intended to be exhaustive, %s is not a valid switch case", diskType))
+	}
+
 	return nil
 }
 
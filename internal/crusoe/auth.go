@@ -13,6 +13,7 @@ import (
 	"time"
 
 	crusoeapi "github.com/crusoecloud/client-go/swagger/v1alpha5"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 // AuthenticatingTransport is a struct implementing http.Roundtripper
@@ -187,18 +188,26 @@ func NewCrusoeClient(host, key, secret, userAgent string) *crusoeapi.APIClient {
 	cfg := crusoeapi.NewConfiguration()
 	cfg.UserAgent = userAgent
 	cfg.BasePath = host
-	if cfg.HTTPClient == nil {
-		cfg.HTTPClient = http.DefaultClient
+	// Use a dedicated client rather than http.DefaultClient so that transports
+	// are not stacked on a shared client each time a Crusoe client is created
+	cfg.HTTPClient = &http.Client{
+		Transport: newTracingTransport(NewAuthenticatingTransport(nil, key, secret)),
 	}
 
-	cfg.HTTPClient.Transport = NewAuthenticatingTransport(cfg.HTTPClient.Transport, key, secret)
-
 	return crusoeapi.NewAPIClient(cfg)
 }
 
 func NewCrusoeHTTPClient(apiKey, secretKey string) *http.Client {
 	crusoeHTTPClient := http.Client{}
-	crusoeHTTPClient.Transport = NewAuthenticatingTransport(nil, apiKey, secretKey)
+	crusoeHTTPClient.Transport = newTracingTransport(NewAuthenticatingTransport(nil, apiKey, secretKey))
 
 	return &crusoeHTTPClient
 }
+
+// newTracingTransport wraps r so that every Crusoe API request is recorded as a client span.
+func newTracingTransport(r http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(r,
+		otelhttp.WithSpanNameFormatter(func(_ string, req *http.Request) string {
+			return "crusoe-api " + req.Method
+		}))
+}
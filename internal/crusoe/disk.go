@@ -91,6 +91,16 @@ func FindDiskByIDFallible(ctx context.Context,
 	return &disks.Items[0], nil
 }
 
+// DiskClient looks up disks through the Crusoe API.
+type DiskClient struct {
+	Client *crusoeapi.APIClient
+}
+
+// GetDisk returns the disk with diskID in projectID.
+func (c DiskClient) GetDisk(ctx context.Context, projectID, diskID string) (*crusoeapi.DiskV1Alpha5, error) {
+	return FindDiskByIDFallible(ctx, c.Client, projectID, diskID)
+}
+
 func GetCreateDiskRequest(request *csi.CreateVolumeRequest,
 	location string,
 	diskType common.DiskType,
@@ -173,13 +183,17 @@ func ResolveNFSTarget(disk *crusoeapi.DiskV1Alpha5) (host, remotePorts string, o
 	case ExpectedVIPRangeLen:
 		return disk.Vips[0], fmt.Sprintf("%s-%s", disk.Vips[0], disk.Vips[1]), true
 	case 1:
-		klog.Warningf("disk %s returned %d vip(s), expected %d ([startIP, endIP]); using single vip %q",
-			disk.Id, len(disk.Vips), ExpectedVIPRangeLen, disk.Vips[0])
+		klog.ErrorS(nil, "Disk returned a single VIP instead of a [startIP, endIP] range, using it for host and remoteports",
+			common.LogKeyVolumeID, disk.Id,
+			common.LogKeyDiskName, disk.Name,
+			"vips", disk.Vips)
 
 		return disk.Vips[0], disk.Vips[0], true
 	default:
-		klog.Warningf("disk %s returned %d vips, expected %d ([startIP, endIP]); using first and last %q-%q",
-			disk.Id, len(disk.Vips), ExpectedVIPRangeLen, disk.Vips[0], disk.Vips[len(disk.Vips)-1])
+		klog.ErrorS(nil, "Disk returned more VIPs than a [startIP, endIP] range, using the first and last",
+			common.LogKeyVolumeID, disk.Id,
+			common.LogKeyDiskName, disk.Name,
+			"vips", disk.Vips)
 
 		return disk.Vips[0], fmt.Sprintf("%s-%s", disk.Vips[0], disk.Vips[len(disk.Vips)-1]), true
 	}
@@ -206,12 +220,15 @@ func GetVolumeFromDisk(disk *crusoeapi.DiskV1Alpha5,
 		segments[common.GetTopologyKey(pluginName, common.TopologySupportsSharedDisksKey)] = strconv.FormatBool(true)
 	}
 
+	capacityBytes := int64(common.NumBytesInGiB) * int64(diskSizeGiB)
+
 	return &csi.Volume{
-		CapacityBytes: int64(common.NumBytesInGiB) * int64(diskSizeGiB),
+		CapacityBytes: capacityBytes,
 		VolumeId:      disk.Id,
 		VolumeContext: map[string]string{
 			common.VolumeContextDiskSerialNumberKey: disk.SerialNumber,
 			common.VolumeContextDiskNameKey:         disk.Name,
+			common.VolumeContextDiskSizeBytesKey:    strconv.FormatInt(capacityBytes, 10),
 		},
 		AccessibleTopology: []*csi.Topology{
 			{
@@ -0,0 +1,275 @@
+package crusoe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	DefaultFlagCacheTTL     = 5 * time.Minute
+	DefaultFlagRetryBackoff = 5 * time.Second
+
+	// flagRefreshTimeout bounds background refreshes, which are not tied to a request.
+	flagRefreshTimeout = 30 * time.Second
+)
+
+var ErrInvalidFlagOverride = errors.New("invalid project flag override")
+
+// ParseFlagOverrides parses comma separated flag=value pairs, e.g. "nfs=true,vast-use-secondary-cluster=false".
+// Values are parsed according to the type of the flag.
+func ParseFlagOverrides(value string) (map[ProjectFlag]any, error) {
+	overrides := make(map[ProjectFlag]any)
+
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		name, rawValue, found := strings.Cut(pair, "=")
+		if !found {
+			return nil, fmt.Errorf("%w: %q is not of the form flag=value", ErrInvalidFlagOverride, pair)
+		}
+
+		definition, err := LookupFlag(ProjectFlag(strings.TrimSpace(name)))
+		if err != nil {
+			return nil, err
+		}
+
+		parsed, err := definition.parseValue(strings.TrimSpace(rawValue))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidFlagOverride, err)
+		}
+
+		overrides[definition.Name] = parsed
+	}
+
+	return overrides, nil
+}
+
+type projectFlagKey struct {
+	projectID string
+	flag      ProjectFlag
+}
+
+type cachedProjectFlag struct {
+	// ready is closed once the first fetch returned, value and err are only set afterwards
+	ready chan struct{}
+	value any
+	// err is the error of a first fetch which left no value to serve
+	err error
+	// fetchedAt is zero if value is the default, served because the flag could not be fetched
+	fetchedAt time.Time
+	// failedAt and failures describe the fetches which failed since the last one which succeeded
+	failedAt   time.Time
+	failures   int
+	refreshing bool
+}
+
+// refreshAt returns when the value should be refreshed: TTL after it was fetched,
+// or after a backoff doubling from retryBackoff up to TTL if fetches have failed since.
+func (c *cachedProjectFlag) refreshAt(ttl, retryBackoff time.Duration) time.Time {
+	if c.failures == 0 {
+		return c.fetchedAt.Add(ttl)
+	}
+
+	backoff := retryBackoff
+	for i := 1; i < c.failures && backoff < ttl; i++ {
+		backoff *= 2
+	}
+
+	return c.failedAt.Add(min(backoff, ttl))
+}
+
+func (c *cachedProjectFlag) fetched(value any) {
+	c.value = value
+	c.fetchedAt = time.Now()
+	c.failures = 0
+}
+
+func (c *cachedProjectFlag) failed() {
+	c.failedAt = time.Now()
+	c.failures++
+}
+
+// FlagProvider serves project feature flags from a cache, so that volume operations
+// neither wait for nor fail because of the flag API.
+// A flag is fetched synchronously the first time it is requested, concurrent requests wait for the same fetch.
+// Afterwards the cached value is served and refreshed in the background once it is older than TTL.
+// If a refresh fails, the last known value is served and the refresh is retried with a backoff.
+// If the first fetch fails, the OnFailure policy of the flag decides whether its default is served.
+type FlagProvider struct {
+	HTTPClient  *http.Client
+	APIEndpoint string
+	// TTL is how long a value is served before it is refreshed, defaults to DefaultFlagCacheTTL.
+	TTL time.Duration
+	// RetryBackoff is how long a failed fetch is first retried after, doubling up to TTL with each failure.
+	// It defaults to DefaultFlagRetryBackoff.
+	RetryBackoff time.Duration
+	// Overrides pin flags to a value of their type, they are never fetched.
+	Overrides map[ProjectFlag]any
+
+	mu    sync.Mutex
+	cache map[projectFlagKey]*cachedProjectFlag
+}
+
+var _ FlagSource = (*FlagProvider)(nil)
+
+func (p *FlagProvider) Bool(ctx context.Context, projectID string, name ProjectFlag) (bool, error) {
+	return getTyped[bool](ctx, p, projectID, name, FlagTypeBool)
+}
+
+func (p *FlagProvider) String(ctx context.Context, projectID string, name ProjectFlag) (string, error) {
+	return getTyped[string](ctx, p, projectID, name, FlagTypeString)
+}
+
+func (p *FlagProvider) Int(ctx context.Context, projectID string, name ProjectFlag) (int, error) {
+	return getTyped[int](ctx, p, projectID, name, FlagTypeInt)
+}
+
+func getTyped[T any](ctx context.Context, p *FlagProvider, projectID string, name ProjectFlag, flagType FlagType) (
+	T,
+	error,
+) {
+	var zero T
+
+	definition, err := LookupFlag(name)
+	if err != nil {
+		return zero, err
+	}
+
+	if definition.Type != flagType {
+		return zero, fmt.Errorf("%w: %s is a %s, not a %s", ErrFlagTypeMismatch, name, definition.Type, flagType)
+	}
+
+	value, err := p.get(ctx, projectID, definition)
+	if err != nil {
+		return zero, err
+	}
+
+	typed, ok := value.(T)
+	if !ok {
+		return zero, fmt.Errorf("%w: %s has value %v", ErrFlagTypeMismatch, name, value)
+	}
+
+	return typed, nil
+}
+
+func (p *FlagProvider) get(ctx context.Context, projectID string, definition FlagDefinition) (any, error) {
+	if value, ok := p.Overrides[definition.Name]; ok {
+		return value, nil
+	}
+
+	key := projectFlagKey{projectID: projectID, flag: definition.Name}
+
+	p.mu.Lock()
+	if p.cache == nil {
+		p.cache = make(map[projectFlagKey]*cachedProjectFlag)
+	}
+
+	cached, ok := p.cache[key]
+	if !ok {
+		cached = &cachedProjectFlag{ready: make(chan struct{})}
+		p.cache[key] = cached
+		p.mu.Unlock()
+
+		return p.fetchFirst(ctx, key, definition, cached)
+	}
+	p.mu.Unlock()
+
+	select {
+	case <-cached.ready:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for project flag %s: %w", definition.Name, ctx.Err())
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if cached.err != nil {
+		return nil, cached.err
+	}
+
+	if !cached.refreshing && !time.Now().Before(cached.refreshAt(p.ttl(), p.retryBackoff())) {
+		cached.refreshing = true
+		go p.refresh(key, definition, cached)
+	}
+
+	return cached.value, nil
+}
+
+// fetchFirst fetches the flag for the first time into cached, which other requests for the flag wait for.
+func (p *FlagProvider) fetchFirst(ctx context.Context,
+	key projectFlagKey,
+	definition FlagDefinition,
+	cached *cachedProjectFlag,
+) (any, error) {
+	value, err := fetchFlag(ctx, p.HTTPClient, p.APIEndpoint, key.projectID, definition)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	defer close(cached.ready)
+
+	switch {
+	case err == nil:
+		cached.fetched(value)
+	case definition.OnFailure == FlagFailurePolicyDefault:
+		klog.ErrorS(err, "Failed to fetch project flag, serving its default",
+			"projectID", key.projectID, "flag", definition.Name, "default", definition.Default)
+
+		// The default is served while the flag is retried in the background
+		cached.value = definition.Default
+		cached.failed()
+	default:
+		// Nothing to serve, the next request fetches the flag again
+		cached.err = err
+		delete(p.cache, key)
+	}
+
+	return cached.value, cached.err
+}
+
+func (p *FlagProvider) ttl() time.Duration {
+	if p.TTL == 0 {
+		return DefaultFlagCacheTTL
+	}
+
+	return p.TTL
+}
+
+func (p *FlagProvider) retryBackoff() time.Duration {
+	if p.RetryBackoff == 0 {
+		return DefaultFlagRetryBackoff
+	}
+
+	return p.RetryBackoff
+}
+
+func (p *FlagProvider) refresh(key projectFlagKey, definition FlagDefinition, cached *cachedProjectFlag) {
+	ctx, cancel := context.WithTimeout(context.Background(), flagRefreshTimeout)
+	defer cancel()
+
+	value, err := fetchFlag(ctx, p.HTTPClient, p.APIEndpoint, key.projectID, definition)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cached.refreshing = false
+
+	if err != nil {
+		klog.ErrorS(err, "Failed to refresh project flag, serving the last known value",
+			"projectID", key.projectID, "flag", key.flag, "failures", cached.failures+1)
+		cached.failed()
+
+		return
+	}
+
+	cached.fetched(value)
+}
@@ -0,0 +1,393 @@
+package crusoe_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/crusoecloud/crusoe-csi-driver/internal/crusoe"
+)
+
+const testProjectID = "project"
+
+// flagServer serves the flag API, answering with enabled, or with an error if unavailable is set.
+type flagServer struct {
+	enabled     atomic.Bool
+	unavailable atomic.Bool
+	requests    atomic.Int32
+}
+
+func newFlagServer(t *testing.T, enabled bool) (*flagServer, *crusoe.FlagProvider) {
+	t.Helper()
+
+	s := &flagServer{}
+	s.enabled.Store(enabled)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		s.requests.Add(1)
+		if s.unavailable.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		if s.enabled.Load() {
+			_, _ = w.Write([]byte(`{"enabled": true}`))
+		} else {
+			_, _ = w.Write([]byte(`{"enabled": false}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return s, &crusoe.FlagProvider{HTTPClient: server.Client(), APIEndpoint: server.URL}
+}
+
+// eventually polls the provider until it returns want.
+func eventually(t *testing.T, provider *crusoe.FlagProvider, want bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		enabled, err := provider.Bool(context.Background(), testProjectID, crusoe.ProjectFlagNFS)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if enabled == want {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("flag was not refreshed to %t", want)
+}
+
+func TestFlagProvider_Bool_Cached(t *testing.T) {
+	t.Parallel()
+
+	server, provider := newFlagServer(t, true)
+
+	for range 3 {
+		enabled, err := provider.Bool(context.Background(), testProjectID, crusoe.ProjectFlagNFS)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !enabled {
+			t.Fatalf("expected flag to be enabled")
+		}
+	}
+
+	if requests := server.requests.Load(); requests != 1 {
+		t.Errorf("expected 1 request, got %d", requests)
+	}
+}
+
+func TestFlagProvider_Bool_RefreshesInBackground(t *testing.T) {
+	t.Parallel()
+
+	server, provider := newFlagServer(t, true)
+	provider.TTL = time.Nanosecond
+
+	if _, err := provider.Bool(context.Background(), testProjectID, crusoe.ProjectFlagNFS); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	server.enabled.Store(false)
+	eventually(t, provider, false)
+}
+
+func TestFlagProvider_Bool_ServesLastKnownValue(t *testing.T) {
+	t.Parallel()
+
+	server, provider := newFlagServer(t, true)
+	provider.TTL = time.Nanosecond
+
+	if _, err := provider.Bool(context.Background(), testProjectID, crusoe.ProjectFlagNFS); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	server.unavailable.Store(true)
+	for server.requests.Load() < 3 {
+		enabled, err := provider.Bool(context.Background(), testProjectID, crusoe.ProjectFlagNFS)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !enabled {
+			t.Fatalf("expected the last known value to be served")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	server.unavailable.Store(false)
+	server.enabled.Store(false)
+	eventually(t, provider, false)
+}
+
+func TestFlagProvider_Bool_FirstFetchFails(t *testing.T) {
+	t.Parallel()
+
+	server, provider := newFlagServer(t, true)
+	server.unavailable.Store(true)
+
+	if _, err := provider.Bool(context.Background(), testProjectID, crusoe.ProjectFlagNFS); err == nil {
+		t.Fatalf("expected an error without a known value")
+	}
+}
+
+func TestFlagProvider_Bool_Override(t *testing.T) {
+	t.Parallel()
+
+	server, provider := newFlagServer(t, true)
+	provider.Overrides = map[crusoe.ProjectFlag]any{crusoe.ProjectFlagNFS: false}
+
+	enabled, err := provider.Bool(context.Background(), testProjectID, crusoe.ProjectFlagNFS)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if enabled {
+		t.Errorf("expected the override to be served")
+	}
+
+	if requests := server.requests.Load(); requests != 0 {
+		t.Errorf("expected no requests, got %d", requests)
+	}
+}
+
+func TestFlagProvider_Bool_FirstFetchFailsWithDefaultPolicy(t *testing.T) {
+	t.Parallel()
+
+	server, provider := newFlagServer(t, true)
+	server.unavailable.Store(true)
+	provider.RetryBackoff = 10 * time.Millisecond
+
+	enabled, err := provider.Bool(context.Background(), testProjectID, crusoe.ProjectFlagVastUseSecondaryCluster)
+	if err != nil {
+		t.Fatalf("expected the default to be served, got error: %v", err)
+	}
+
+	if enabled {
+		t.Fatalf("expected the default to be served")
+	}
+
+	// The default is served until the flag can be fetched
+	server.unavailable.Store(false)
+	deadline := time.Now().Add(5 * time.Second)
+	for !enabled && time.Now().Before(deadline) {
+		enabled, err = provider.Bool(context.Background(), testProjectID, crusoe.ProjectFlagVastUseSecondaryCluster)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if !enabled {
+		t.Errorf("flag was not fetched after the API recovered")
+	}
+}
+
+func TestFlagProvider_Bool_BacksOffAfterFailures(t *testing.T) {
+	t.Parallel()
+
+	server, provider := newFlagServer(t, true)
+	server.unavailable.Store(true)
+	provider.TTL = time.Hour
+	provider.RetryBackoff = time.Hour
+
+	// The default served after the first fetch failed is not retried before the backoff
+	for range 5 {
+		_, _ = provider.Bool(context.Background(), testProjectID, crusoe.ProjectFlagVastUseSecondaryCluster)
+	}
+
+	if requests := server.requests.Load(); requests != 1 {
+		t.Errorf("expected 1 request, got %d", requests)
+	}
+}
+
+func TestFlagProvider_Bool_BacksOffAfterFailedRefresh(t *testing.T) {
+	t.Parallel()
+
+	server, provider := newFlagServer(t, true)
+	provider.TTL = 200 * time.Millisecond
+	provider.RetryBackoff = time.Hour
+
+	if _, err := provider.Bool(context.Background(), testProjectID, crusoe.ProjectFlagNFS); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	time.Sleep(provider.TTL)
+	server.unavailable.Store(true)
+
+	for server.requests.Load() < 2 {
+		if _, err := provider.Bool(context.Background(), testProjectID, crusoe.ProjectFlagNFS); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	// The failed refresh is not retried before min(TTL, backoff)
+	for range 5 {
+		if _, err := provider.Bool(context.Background(), testProjectID, crusoe.ProjectFlagNFS); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if requests := server.requests.Load(); requests != 2 {
+		t.Errorf("expected 2 requests, got %d", requests)
+	}
+}
+
+func TestFlagProvider_Bool_DedupesFirstFetch(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		<-release
+		_, _ = w.Write([]byte(`{"enabled": true}`))
+	}))
+	t.Cleanup(server.Close)
+
+	provider := &crusoe.FlagProvider{HTTPClient: server.Client(), APIEndpoint: server.URL}
+
+	const callers = 5
+	results := make(chan error, callers)
+	for range callers {
+		go func() {
+			enabled, err := provider.Bool(context.Background(), testProjectID, crusoe.ProjectFlagNFS)
+			if err == nil && !enabled {
+				err = errors.New("expected flag to be enabled")
+			}
+			results <- err
+		}()
+	}
+
+	for requests.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// Give the other callers time to find the fetch in flight
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	for range callers {
+		if err := <-results; err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+
+	if got := requests.Load(); got != 1 {
+		t.Errorf("expected 1 request, got %d", got)
+	}
+}
+
+func TestFlagProvider_Bool_Value(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"value": true}`))
+	}))
+	t.Cleanup(server.Close)
+
+	provider := &crusoe.FlagProvider{HTTPClient: server.Client(), APIEndpoint: server.URL}
+
+	enabled, err := provider.Bool(context.Background(), testProjectID, crusoe.ProjectFlagNFS)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !enabled {
+		t.Errorf("expected flag to be enabled")
+	}
+}
+
+func TestFlagProvider_TypeMismatch(t *testing.T) {
+	t.Parallel()
+
+	server, provider := newFlagServer(t, true)
+
+	if _, err := provider.String(context.Background(), testProjectID, crusoe.ProjectFlagNFS); !errors.Is(
+		err, crusoe.ErrFlagTypeMismatch) {
+		t.Errorf("expected error %v, got %v", crusoe.ErrFlagTypeMismatch, err)
+	}
+
+	if _, err := provider.Int(context.Background(), testProjectID, crusoe.ProjectFlagNFS); !errors.Is(
+		err, crusoe.ErrFlagTypeMismatch) {
+		t.Errorf("expected error %v, got %v", crusoe.ErrFlagTypeMismatch, err)
+	}
+
+	if requests := server.requests.Load(); requests != 0 {
+		t.Errorf("expected no requests, got %d", requests)
+	}
+}
+
+func TestParseFlagOverrides(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		value   string
+		want    map[crusoe.ProjectFlag]any
+		wantErr error
+	}{
+		{
+			name:  "empty",
+			value: "",
+			want:  map[crusoe.ProjectFlag]any{},
+		},
+		{
+			name:  "flags",
+			value: "nfs=true, vast-use-secondary-cluster=false",
+			want: map[crusoe.ProjectFlag]any{
+				crusoe.ProjectFlagNFS:                     true,
+				crusoe.ProjectFlagVastUseSecondaryCluster: false,
+			},
+		},
+		{
+			name:    "unknown flag",
+			value:   "nfs=true,unknown=true",
+			wantErr: crusoe.ErrUnknownProjectFlag,
+		},
+		{
+			name:    "missing value",
+			value:   "nfs",
+			wantErr: crusoe.ErrInvalidFlagOverride,
+		},
+		{
+			name:    "invalid value",
+			value:   "nfs=maybe",
+			wantErr: crusoe.ErrInvalidFlagOverride,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := crusoe.ParseFlagOverrides(tt.value)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+
+			for flag, enabled := range tt.want {
+				if got[flag] != enabled {
+					t.Errorf("expected %s=%v, got %v", flag, enabled, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package crusoe
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/crusoecloud/crusoe-csi-driver/internal/metrics"
+	"k8s.io/klog/v2"
+)
+
+const (
+	flagRouteTemplate = "%s/projects/%s/%s"
+	DEBUG             = 8
+)
+
+// ProjectFlag names a feature flag of a project.
+type ProjectFlag string
+
+const (
+	ProjectFlagNFS                     ProjectFlag = "nfs"
+	ProjectFlagVastUseSecondaryCluster ProjectFlag = "vast-use-secondary-cluster"
+)
+
+// FlagType is the type of the value of a flag.
+type FlagType string
+
+const (
+	FlagTypeBool   FlagType = "bool"
+	FlagTypeString FlagType = "string"
+	FlagTypeInt    FlagType = "int"
+)
+
+// FlagFailurePolicy decides what is served when a flag has never been fetched successfully.
+type FlagFailurePolicy string
+
+const (
+	// FlagFailurePolicyFail returns the error, failing the operation which needs the flag.
+	FlagFailurePolicyFail FlagFailurePolicy = "fail"
+	// FlagFailurePolicyDefault serves the default of the flag.
+	FlagFailurePolicyDefault FlagFailurePolicy = "default"
+)
+
+// FlagDefinition declares a flag of the storage flag API.
+type FlagDefinition struct {
+	Name ProjectFlag
+	// Route is the path of the flag below the project.
+	Route string
+	Type  FlagType
+	// Default must be of the Go type matching Type.
+	Default   any
+	OnFailure FlagFailurePolicy
+}
+
+var (
+	ErrUnknownProjectFlag = errors.New("unknown project flag")
+	ErrFlagTypeMismatch   = errors.New("project flag has a different type")
+
+	errCreateFlagRequest = errors.New("failed to create flag request")
+	errGetFlag           = errors.New("failed to get flag")
+	errReadFlagResponse  = errors.New("failed to read flag response")
+	errUnmarshalFlag     = errors.New("failed to unmarshal flag response")
+)
+
+// flagRegistry declares every flag the driver reads, each flag is added here and nowhere else.
+//
+//nolint:gochecknoglobals // can't construct const map
+var flagRegistry = map[ProjectFlag]FlagDefinition{
+	ProjectFlagNFS: {
+		Name:      ProjectFlagNFS,
+		Route:     "storage/nfs/is-using-nfs",
+		Type:      FlagTypeBool,
+		Default:   false,
+		OnFailure: FlagFailurePolicyFail,
+	},
+	ProjectFlagVastUseSecondaryCluster: {
+		Name:      ProjectFlagVastUseSecondaryCluster,
+		Route:     "storage/nfs/is-using-secondary-cluster",
+		Type:      FlagTypeBool,
+		Default:   false,
+		OnFailure: FlagFailurePolicyDefault,
+	},
+}
+
+// LookupFlag returns the definition of a flag.
+func LookupFlag(name ProjectFlag) (FlagDefinition, error) {
+	definition, ok := flagRegistry[name]
+	if !ok {
+		return FlagDefinition{}, fmt.Errorf("%w: %s", ErrUnknownProjectFlag, name)
+	}
+
+	return definition, nil
+}
+
+// FlagSource provides the values of project feature flags.
+// Each getter fails with ErrFlagTypeMismatch if the flag is declared with a different type.
+type FlagSource interface {
+	Bool(ctx context.Context, projectID string, name ProjectFlag) (bool, error)
+	String(ctx context.Context, projectID string, name ProjectFlag) (string, error)
+	Int(ctx context.Context, projectID string, name ProjectFlag) (int, error)
+}
+
+// FlagResponse is the body returned by the flag API.
+// Boolean flags are reported in status or enabled, other flags in value.
+type FlagResponse struct {
+	Status  bool            `json:"status,omitempty"`
+	Enabled bool            `json:"enabled,omitempty"`
+	Value   json.RawMessage `json:"value,omitempty"`
+}
+
+// parseValue parses the string representation of a value of the flag.
+func (f FlagDefinition) parseValue(value string) (any, error) {
+	var parsed any
+	var err error
+
+	switch f.Type {
+	case FlagTypeBool:
+		parsed, err = strconv.ParseBool(value)
+	case FlagTypeString:
+		parsed = value
+	case FlagTypeInt:
+		parsed, err = strconv.Atoi(value)
+	default:
+		// flagRegistry only declares the types above, reaching this case is a bug
+		panic(fmt.Sprintf("Switch is intended to be exhaustive, %s is not a valid switch case", f.Type))
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a %s, got %q: %w", f.Name, f.Type, value, err)
+	}
+
+	return parsed, nil
+}
+
+func (f FlagDefinition) decode(response FlagResponse) (any, error) {
+	var err error
+
+	switch f.Type {
+	case FlagTypeBool:
+		if len(response.Value) == 0 {
+			return response.Status || response.Enabled, nil
+		}
+
+		var value bool
+		err = json.Unmarshal(response.Value, &value)
+
+		return value, err
+	case FlagTypeString:
+		var value string
+		err = json.Unmarshal(response.Value, &value)
+
+		return value, err
+	case FlagTypeInt:
+		var value int
+		err = json.Unmarshal(response.Value, &value)
+
+		return value, err
+	default:
+		// flagRegistry only declares the types above, reaching this case is a bug
+		panic(fmt.Sprintf("Switch is intended to be exhaustive, %s is not a valid switch case", f.Type))
+	}
+}
+
+// fetchFlag fetches the value of a flag from the API. It is the only place the flag API is called.
+func fetchFlag(
+	ctx context.Context,
+	crusoeHTTPClient *http.Client,
+	apiEndpoint, projectID string,
+	definition FlagDefinition,
+) (any, error) {
+	start := time.Now()
+
+	flagRoute := fmt.Sprintf(flagRouteTemplate, apiEndpoint, projectID, definition.Route)
+	value, err := getFlag(ctx, crusoeHTTPClient, flagRoute, definition)
+
+	outcome := metrics.OutcomeSuccess
+	if err != nil {
+		outcome = metrics.OutcomeFailed
+	}
+	metrics.RecordFlagFetch(ctx, string(definition.Name), outcome, time.Since(start))
+
+	return value, err
+}
+
+func getFlag(ctx context.Context, crusoeHTTPClient *http.Client, flagRoute string, definition FlagDefinition) (
+	any,
+	error,
+) {
+	klog.V(DEBUG).InfoS("Fetching flag", "url", flagRoute)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, flagRoute, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errCreateFlagRequest, err)
+	}
+	resp, err := crusoeHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errGetFlag, err)
+	}
+
+	if resp != nil && resp.Body != nil {
+		defer resp.Body.Close()
+	}
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errReadFlagResponse, err)
+	}
+
+	klog.V(DEBUG).InfoS("Received flag API response",
+		"url", flagRoute,
+		"statusCode", resp.StatusCode,
+		"contentType", resp.Header.Get("Content-Type"),
+		"body", string(bodyBytes))
+
+	// Check HTTP status code before unmarshaling
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: HTTP %d: %s", errGetFlag, resp.StatusCode, string(bodyBytes))
+	}
+
+	var flagResponse FlagResponse
+
+	unmarshalErr := json.Unmarshal(bodyBytes, &flagResponse)
+	if unmarshalErr != nil {
+		return nil, fmt.Errorf("%w: %w (response body: %q)", errUnmarshalFlag, unmarshalErr, string(bodyBytes))
+	}
+
+	value, decodeErr := definition.decode(flagResponse)
+	if decodeErr != nil {
+		return nil, fmt.Errorf("%w: %s is not a %s: %w (response body: %q)",
+			errUnmarshalFlag, definition.Name, definition.Type, decodeErr, string(bodyBytes))
+	}
+
+	return value, nil
+}
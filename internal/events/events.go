@@ -0,0 +1,169 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/crusoecloud/crusoe-csi-driver/internal/common"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+)
+
+// Event reasons emitted by the driver.
+const (
+	ReasonProvisioningFailed = "ProvisioningFailed"
+	ReasonAttachFailed       = "AttachFailed"
+	ReasonDetachFailed       = "DetachFailed"
+	ReasonMountFailed        = "MountFailed"
+	ReasonFormatFailed       = "FormatFailed"
+	ReasonResizeFailed       = "ResizeFailed"
+	ReasonUnmountFailed      = "UnmountFailed"
+	ReasonOrphanDetected     = "OrphanDetected"
+	ReasonFilesystemErrors   = "FilesystemErrors"
+	ReasonFilesystemRepaired = "FilesystemRepaired"
+)
+
+// Rate limiting for the event correlator. Identical events are aggregated,
+// and each object may emit a burst of events before being limited to one
+// event every spamFilterRefillPeriod.
+const (
+	spamFilterBurst        = 10
+	spamFilterRefillPeriod = 5 * time.Minute
+	maxAggregatedEvents    = 5
+	aggregationInterval    = 10 * time.Minute
+
+	volumeHandleIndex = "volumeHandle"
+	pvResyncPeriod    = 10 * time.Minute
+)
+
+var errUnexpectedObjectType = errors.New("unexpected object type in indexer")
+
+// Recorder emits Kubernetes Events for volume lifecycle failures.
+type Recorder interface {
+	// VolumeEventf records an event on the PersistentVolume backing volumeID, if one exists.
+	VolumeEventf(volumeID, eventType, reason, messageFmt string, args ...any)
+	// ClaimEventf records an event on the PersistentVolumeClaim namespace/name.
+	ClaimEventf(namespace, name, eventType, reason, messageFmt string, args ...any)
+	// NodeEventf records an event on the Kubernetes Node the driver runs on.
+	NodeEventf(eventType, reason, messageFmt string, args ...any)
+}
+
+// NoopRecorder discards all events. It is used when event emission is disabled.
+type NoopRecorder struct{}
+
+func (NoopRecorder) VolumeEventf(_, _, _, _ string, _ ...any)   {}
+func (NoopRecorder) ClaimEventf(_, _, _, _, _ string, _ ...any) {}
+func (NoopRecorder) NodeEventf(_, _, _ string, _ ...any)        {}
+
+// KubeRecorder emits events through the Kubernetes API.
+// PersistentVolumes are resolved from CSI volume IDs through an informer cache.
+type KubeRecorder struct {
+	recorder  record.EventRecorder
+	pvIndexer cache.Indexer
+	nodeName  string
+}
+
+// NewKubeRecorder starts an event broadcaster and PersistentVolume informer which run until ctx is cancelled.
+func NewKubeRecorder(ctx context.Context,
+	kubeClient kubernetes.Interface,
+	driverName,
+	nodeName string,
+) (*KubeRecorder, error) {
+	broadcaster := record.NewBroadcaster(
+		record.WithContext(ctx),
+		record.WithCorrelatorOptions(record.CorrelatorOptions{
+			BurstSize:            spamFilterBurst,
+			QPS:                  float32(1 / spamFilterRefillPeriod.Seconds()),
+			MaxEvents:            maxAggregatedEvents,
+			MaxIntervalInSeconds: int(aggregationInterval.Seconds()),
+		}))
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+
+	recorder := broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: driverName, Host: nodeName})
+
+	factory := informers.NewSharedInformerFactory(kubeClient, pvResyncPeriod)
+	pvInformer := factory.Core().V1().PersistentVolumes().Informer()
+
+	err := pvInformer.AddIndexers(cache.Indexers{volumeHandleIndex: volumeHandleIndexFunc(driverName)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to add persistent volume indexer: %w", err)
+	}
+
+	factory.Start(ctx.Done())
+
+	return &KubeRecorder{
+		recorder:  recorder,
+		pvIndexer: pvInformer.GetIndexer(),
+		nodeName:  nodeName,
+	}, nil
+}
+
+// volumeHandleIndexFunc indexes PersistentVolumes provisioned by driverName by their CSI volume handle.
+func volumeHandleIndexFunc(driverName string) cache.IndexFunc {
+	return func(obj any) ([]string, error) {
+		pv, ok := obj.(*corev1.PersistentVolume)
+		if !ok {
+			return nil, fmt.Errorf("%w: %T", errUnexpectedObjectType, obj)
+		}
+
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != driverName {
+			return nil, nil
+		}
+
+		return []string{pv.Spec.CSI.VolumeHandle}, nil
+	}
+}
+
+func (r *KubeRecorder) VolumeEventf(volumeID, eventType, reason, messageFmt string, args ...any) {
+	pvs, err := r.pvIndexer.ByIndex(volumeHandleIndex, volumeID)
+	if err != nil || len(pvs) == 0 {
+		klog.V(4).InfoS("Could not find persistent volume for event",
+			common.LogKeyVolumeID, volumeID, "reason", reason, "err", err)
+
+		return
+	}
+
+	for _, obj := range pvs {
+		pv, ok := obj.(*corev1.PersistentVolume)
+		if !ok {
+			continue
+		}
+
+		r.recorder.Eventf(pv, eventType, reason, messageFmt, args...)
+	}
+}
+
+func (r *KubeRecorder) ClaimEventf(namespace, name, eventType, reason, messageFmt string, args ...any) {
+	if namespace == "" || name == "" {
+		return
+	}
+
+	r.recorder.Eventf(&corev1.ObjectReference{
+		Kind:       "PersistentVolumeClaim",
+		APIVersion: "v1",
+		Namespace:  namespace,
+		Name:       name,
+	}, eventType, reason, messageFmt, args...)
+}
+
+func (r *KubeRecorder) NodeEventf(eventType, reason, messageFmt string, args ...any) {
+	if r.nodeName == "" {
+		return
+	}
+
+	// Node events are conventionally recorded with the node name as the UID, as kubelet does
+	r.recorder.Eventf(&corev1.ObjectReference{
+		Kind: "Node",
+		Name: r.nodeName,
+		UID:  types.UID(r.nodeName),
+	}, eventType, reason, messageFmt, args...)
+}
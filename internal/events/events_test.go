@@ -0,0 +1,142 @@
+package events_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/crusoecloud/crusoe-csi-driver/internal/events"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const (
+	testDriverName = "ssd.csi.crusoe.ai"
+	testNodeName   = "node-a"
+	testVolumeID   = "vol-123"
+
+	pollInterval = 10 * time.Millisecond
+	pollTimeout  = 5 * time.Second
+)
+
+func newTestPV(name, driver, volumeHandle string) *corev1.PersistentVolume {
+	return &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: corev1.PersistentVolumeSpec{
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{
+					Driver:       driver,
+					VolumeHandle: volumeHandle,
+				},
+			},
+		},
+	}
+}
+
+// waitForEvents polls until the fake clientset has recorded want events.
+func waitForEvents(ctx context.Context, t *testing.T, client *fake.Clientset, want int) []corev1.Event {
+	t.Helper()
+
+	var items []corev1.Event
+
+	err := wait.PollUntilContextTimeout(ctx, pollInterval, pollTimeout, true,
+		func(ctx context.Context) (bool, error) {
+			list, err := client.CoreV1().Events("").List(ctx, metav1.ListOptions{})
+			if err != nil {
+				return false, err
+			}
+			items = list.Items
+
+			return len(items) >= want, nil
+		})
+	if err != nil {
+		t.Fatalf("expected %d events, got %d: %v", want, len(items), err)
+	}
+
+	return items
+}
+
+func TestKubeRecorder_VolumeEvent(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := fake.NewClientset(
+		newTestPV("pv-ours", testDriverName, testVolumeID),
+		newTestPV("pv-other-driver", "other.csi.example.com", testVolumeID),
+	)
+
+	recorder, err := events.NewKubeRecorder(ctx, client, testDriverName, testNodeName)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The PV informer syncs asynchronously, keep emitting until the event lands
+	var items []corev1.Event
+	err = wait.PollUntilContextTimeout(ctx, pollInterval, pollTimeout, true,
+		func(ctx context.Context) (bool, error) {
+			recorder.VolumeEventf(testVolumeID, corev1.EventTypeWarning, events.ReasonMountFailed, "mount failed")
+			list, listErr := client.CoreV1().Events("").List(ctx, metav1.ListOptions{})
+			if listErr != nil {
+				return false, listErr
+			}
+			items = list.Items
+
+			return len(items) > 0, nil
+		})
+	if err != nil {
+		t.Fatalf("expected an event to be recorded: %v", err)
+	}
+
+	for i := range items {
+		if items[i].InvolvedObject.Name != "pv-ours" {
+			t.Errorf("expected event on pv-ours, got %s/%s",
+				items[i].InvolvedObject.Kind, items[i].InvolvedObject.Name)
+		}
+
+		if items[i].Reason != events.ReasonMountFailed {
+			t.Errorf("expected reason %s, got %s", events.ReasonMountFailed, items[i].Reason)
+		}
+	}
+}
+
+func TestKubeRecorder_ClaimAndNodeEvents(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := fake.NewClientset()
+
+	recorder, err := events.NewKubeRecorder(ctx, client, testDriverName, testNodeName)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Events without a PVC reference are dropped
+	recorder.ClaimEventf("", "", corev1.EventTypeWarning, events.ReasonProvisioningFailed, "dropped")
+	recorder.ClaimEventf("default", "data", corev1.EventTypeWarning, events.ReasonProvisioningFailed, "failed")
+	recorder.NodeEventf(corev1.EventTypeWarning, events.ReasonFormatFailed, "failed")
+
+	items := waitForEvents(ctx, t, client, 2)
+
+	kinds := map[string]string{}
+	for i := range items {
+		kinds[items[i].InvolvedObject.Kind] = items[i].InvolvedObject.Name
+	}
+
+	if kinds["PersistentVolumeClaim"] != "data" {
+		t.Errorf("expected event on PVC data, got %v", kinds)
+	}
+
+	if kinds["Node"] != testNodeName {
+		t.Errorf("expected event on node %s, got %v", testNodeName, kinds)
+	}
+
+	if len(items) != 2 {
+		t.Errorf("expected 2 events, got %d", len(items))
+	}
+}
@@ -0,0 +1,270 @@
+package identity
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	crusoeapi "github.com/crusoecloud/client-go/swagger/v1alpha5"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/common"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// ProcFilesystemsPath lists the filesystem types supported by the running kernel.
+	ProcFilesystemsPath = "/proc/filesystems"
+	// SysBlockPath lists the block devices of the host, SSD devices are resolved through it.
+	// Unlike /dev/disk/by-id it exists before any disk is attached.
+	SysBlockPath = "/sys/block"
+
+	kernelModulesPath = "/lib/modules"
+)
+
+var (
+	ErrCredentialsInvalid    = errors.New("crusoe API rejected the configured credentials")
+	ErrAPIUnreachable        = errors.New("crusoe API is unreachable")
+	ErrPathUnavailable       = errors.New("required path is unavailable")
+	ErrFilesystemUnsupported = errors.New("required filesystem is not supported by the kernel")
+)
+
+// ReadinessCheck is a single condition which must hold for the driver to be ready to serve requests.
+type ReadinessCheck interface {
+	// Name identifies the check in probe failure messages.
+	Name() string
+	// Check returns a non-nil error if the driver is not ready.
+	Check(ctx context.Context) error
+}
+
+// CachedCheck wraps a ReadinessCheck and reuses its last result for TTL.
+// Probe is called frequently, so expensive checks such as API calls should be cached.
+type CachedCheck struct {
+	check ReadinessCheck
+	ttl   time.Duration
+
+	mu        sync.Mutex
+	lastErr   error
+	checkedAt time.Time
+}
+
+func NewCachedCheck(check ReadinessCheck, ttl time.Duration) *CachedCheck {
+	return &CachedCheck{check: check, ttl: ttl}
+}
+
+func (c *CachedCheck) Name() string {
+	return c.check.Name()
+}
+
+func (c *CachedCheck) Check(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.checkedAt.IsZero() && time.Since(c.checkedAt) < c.ttl {
+		return c.lastErr
+	}
+
+	err := c.check.Check(ctx)
+
+	// Don't cache results of cancelled probes, they say nothing about the driver
+	if ctx.Err() != nil {
+		return err
+	}
+
+	c.lastErr = err
+	c.checkedAt = time.Now()
+
+	return err
+}
+
+// CrusoeAPICheck verifies that the Crusoe API is reachable and accepts the driver's credentials
+// by fetching the host instance. It is only run by the controller.
+type CrusoeAPICheck struct {
+	CrusoeClient *crusoeapi.APIClient
+	ProjectID    string
+	InstanceID   string
+}
+
+func (c *CrusoeAPICheck) Name() string {
+	return "crusoe-api"
+}
+
+func (c *CrusoeAPICheck) Check(ctx context.Context) error {
+	_, resp, err := c.CrusoeClient.VMsApi.GetInstance(ctx, c.ProjectID, c.InstanceID)
+	if resp != nil && resp.Body != nil {
+		_ = resp.Body.Close()
+	}
+
+	if err == nil {
+		return nil
+	}
+
+	if resp != nil && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+		return fmt.Errorf("%w: %w", ErrCredentialsInvalid, common.UnpackSwaggerErr(err))
+	}
+
+	return fmt.Errorf("%w: %w", ErrAPIUnreachable, common.UnpackSwaggerErr(err))
+}
+
+// PathCheck verifies that a directory the node service depends on exists.
+type PathCheck struct {
+	Path string
+}
+
+func (c *PathCheck) Name() string {
+	return "path:" + c.Path
+}
+
+func (c *PathCheck) Check(_ context.Context) error {
+	info, err := os.Stat(c.Path)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrPathUnavailable, err)
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("%w: %s is not a directory", ErrPathUnavailable, c.Path)
+	}
+
+	return nil
+}
+
+// FilesystemCheck verifies that the kernel supports at least one of Filesystems.
+// Which filesystem a node mounts depends on the project, so any of them is sufficient.
+// /proc/filesystems only lists filesystems whose module is loaded, which happens on the first mount,
+// so filesystems whose module is built in or installed for the running kernel are supported as well.
+type FilesystemCheck struct {
+	// ProcFilesystemsPath defaults to ProcFilesystemsPath if empty.
+	ProcFilesystemsPath string
+	// ModulesPath defaults to the modules directory of the running kernel, /lib/modules/$(uname -r), if empty.
+	ModulesPath string
+	Filesystems []string
+}
+
+func (c *FilesystemCheck) Name() string {
+	return "filesystems:" + strings.Join(c.Filesystems, ",")
+}
+
+func (c *FilesystemCheck) Check(_ context.Context) error {
+	path := c.ProcFilesystemsPath
+	if path == "" {
+		path = ProcFilesystemsPath
+	}
+
+	supported, err := readSupportedFilesystems(path)
+	if err != nil {
+		return err
+	}
+
+	if c.anySupported(supported) {
+		return nil
+	}
+
+	modulesPath := c.ModulesPath
+	if modulesPath == "" {
+		modulesPath, err = runningKernelModulesPath()
+		if err != nil {
+			return err
+		}
+	}
+
+	modules, err := readKernelModules(modulesPath)
+	if err != nil {
+		return err
+	}
+
+	if c.anySupported(modules) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: none of %s found in %s or %s",
+		ErrFilesystemUnsupported, strings.Join(c.Filesystems, ", "), path, modulesPath)
+}
+
+func (c *FilesystemCheck) anySupported(supported map[string]struct{}) bool {
+	for _, fsType := range c.Filesystems {
+		if _, ok := supported[fsType]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func runningKernelModulesPath() (string, error) {
+	var uname unix.Utsname
+	if err := unix.Uname(&uname); err != nil {
+		return "", fmt.Errorf("failed to read the kernel release: %w", err)
+	}
+
+	return filepath.Join(kernelModulesPath, unix.ByteSliceToString(uname.Release[:])), nil
+}
+
+// readKernelModules returns the names of the modules built into the kernel or installed for it,
+// as listed in modules.builtin and modules.dep of modulesPath, e.g. kernel/fs/nfs/nfs.ko.xz is nfs.
+// Missing files are skipped, the modules directory is often not mounted into the container.
+func readKernelModules(modulesPath string) (map[string]struct{}, error) {
+	modules := map[string]struct{}{}
+
+	for _, name := range []string{"modules.builtin", "modules.dep"} {
+		path := filepath.Join(modulesPath, name)
+
+		file, err := os.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", path, err)
+		}
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			// modules.dep lines are the module followed by a colon and its dependencies
+			module, _, _ := strings.Cut(scanner.Text(), ":")
+			module = filepath.Base(strings.TrimSpace(module))
+			if moduleName, _, ok := strings.Cut(module, ".ko"); ok {
+				modules[moduleName] = struct{}{}
+			}
+		}
+
+		err = scanner.Err()
+		_ = file.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+	}
+
+	return modules, nil
+}
+
+// readSupportedFilesystems parses /proc/filesystems, where each line is an
+// optional "nodev" marker followed by the filesystem type.
+func readSupportedFilesystems(path string) (map[string]struct{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	supported := map[string]struct{}{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		supported[fields[len(fields)-1]] = struct{}{}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return supported, nil
+}
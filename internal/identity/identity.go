@@ -2,8 +2,12 @@ package identity
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"k8s.io/klog/v2"
 )
 
 type Service struct {
@@ -11,6 +15,8 @@ type Service struct {
 	PluginName    string
 	PluginVersion string
 	Capabilities  []*csi.PluginCapability
+	// ReadinessChecks are run on every Probe, the driver is reported ready only if all of them pass.
+	ReadinessChecks []ReadinessCheck
 }
 
 func (s *Service) GetPluginInfo(_ context.Context,
@@ -30,8 +36,24 @@ func (s *Service) GetPluginCapabilities(_ context.Context,
 	}, nil
 }
 
-func (s *Service) Probe(_ context.Context,
+// Probe runs the readiness checks. ProbeResponse has no message field, so the reasons
+// for a Ready=false response are logged for the livenessprobe sidecar's operator to find.
+func (s *Service) Probe(ctx context.Context,
 	_ *csi.ProbeRequest,
 ) (*csi.ProbeResponse, error) {
-	return &csi.ProbeResponse{}, nil
+	var failures []string
+
+	for _, check := range s.ReadinessChecks {
+		if err := check.Check(ctx); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", check.Name(), err))
+		}
+	}
+
+	if len(failures) > 0 {
+		klog.FromContext(ctx).Error(nil, "Driver is not ready", "failures", strings.Join(failures, "; "))
+
+		return &csi.ProbeResponse{Ready: wrapperspb.Bool(false)}, nil
+	}
+
+	return &csi.ProbeResponse{Ready: wrapperspb.Bool(true)}, nil
 }
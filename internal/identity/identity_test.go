@@ -0,0 +1,156 @@
+package identity_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/identity"
+)
+
+var errNotReady = errors.New("not ready")
+
+type fakeCheck struct {
+	err   error
+	calls int
+}
+
+func (f *fakeCheck) Name() string {
+	return "fake"
+}
+
+func (f *fakeCheck) Check(_ context.Context) error {
+	f.calls++
+
+	return f.err
+}
+
+func writeProcFilesystems(t *testing.T, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "filesystems")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+
+	return path
+}
+
+func TestProbe_Ready(t *testing.T) {
+	t.Parallel()
+
+	service := &identity.Service{ReadinessChecks: []identity.ReadinessCheck{&fakeCheck{}}}
+
+	resp, err := service.Probe(context.Background(), &csi.ProbeRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !resp.GetReady().GetValue() {
+		t.Error("expected driver to be ready")
+	}
+}
+
+func TestProbe_NotReady(t *testing.T) {
+	t.Parallel()
+
+	service := &identity.Service{ReadinessChecks: []identity.ReadinessCheck{
+		&fakeCheck{},
+		&fakeCheck{err: errNotReady},
+	}}
+
+	resp, err := service.Probe(context.Background(), &csi.ProbeRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if resp.GetReady() == nil || resp.GetReady().GetValue() {
+		t.Error("expected driver to report Ready=false")
+	}
+}
+
+func TestCachedCheck(t *testing.T) {
+	t.Parallel()
+
+	inner := &fakeCheck{err: errNotReady}
+	cached := identity.NewCachedCheck(inner, time.Hour)
+
+	for range 3 {
+		if err := cached.Check(context.Background()); !errors.Is(err, errNotReady) {
+			t.Errorf("expected cached error, got %v", err)
+		}
+	}
+
+	if inner.calls != 1 {
+		t.Errorf("expected check to run once, ran %d times", inner.calls)
+	}
+}
+
+func TestFilesystemCheck(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		contents string
+		builtin  string
+		dep      string
+		wantErr  bool
+	}{
+		{name: "nfs", contents: "nodev\tsysfs\nnodev\tnfs\n\text4\n", wantErr: false},
+		{name: "virtiofs", contents: "nodev\tvirtiofs\n\text4\n", wantErr: false},
+		{
+			name:     "nfs module not loaded yet",
+			contents: "nodev\tsysfs\n\text4\n",
+			dep:      "kernel/fs/nfs/nfs.ko.xz: kernel/fs/lockd/lockd.ko.xz kernel/net/sunrpc/sunrpc.ko.xz\n",
+			wantErr:  false,
+		},
+		{
+			name:     "virtiofs built in",
+			contents: "nodev\tsysfs\n\text4\n",
+			builtin:  "kernel/fs/ext4/ext4.ko\nkernel/fs/fuse/virtiofs.ko\n",
+			wantErr:  false,
+		},
+		{
+			name:     "neither",
+			contents: "nodev\tsysfs\n\text4\n",
+			builtin:  "kernel/fs/ext4/ext4.ko\n",
+			dep:      "kernel/fs/nfsd/nfsd.ko.zst: kernel/fs/lockd/lockd.ko.zst\n",
+			wantErr:  true,
+		},
+		{name: "no modules directory", contents: "nodev\tsysfs\n\text4\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			modulesPath := t.TempDir()
+			for name, contents := range map[string]string{"modules.builtin": tt.builtin, "modules.dep": tt.dep} {
+				if contents == "" {
+					continue
+				}
+
+				if err := os.WriteFile(filepath.Join(modulesPath, name), []byte(contents), 0o600); err != nil {
+					t.Fatalf("failed to write test file: %v", err)
+				}
+			}
+
+			check := &identity.FilesystemCheck{
+				ProcFilesystemsPath: writeProcFilesystems(t, tt.contents),
+				ModulesPath:         modulesPath,
+				Filesystems:         []string{"nfs", "virtiofs"},
+			}
+
+			err := check.Check(context.Background())
+			if tt.wantErr && !errors.Is(err, identity.ErrFilesystemUnsupported) {
+				t.Errorf("expected unsupported filesystem error, got %v", err)
+			} else if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
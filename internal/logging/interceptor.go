@@ -0,0 +1,111 @@
+package logging
+
+import (
+	"context"
+	"time"
+
+	"github.com/crusoecloud/crusoe-csi-driver/internal/common"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"k8s.io/klog/v2"
+)
+
+const (
+	requestIDKey = "requestID"
+
+	// routineLogLevel is the verbosity at which frequently polled RPCs are logged.
+	routineLogLevel = 4
+)
+
+// routineMethods are polled continuously by kubelet and the sidecars,
+// logging them at the default level would drown out everything else.
+//
+//nolint:gochecknoglobals // can't construct const map
+var routineMethods = map[string]struct{}{
+	"/csi.v1.Identity/Probe":                       {},
+	"/csi.v1.Identity/GetPluginInfo":               {},
+	"/csi.v1.Identity/GetPluginCapabilities":       {},
+	"/csi.v1.Controller/ControllerGetCapabilities": {},
+	"/csi.v1.Node/NodeGetCapabilities":             {},
+	"/csi.v1.Node/NodeGetInfo":                     {},
+	"/csi.v1.Node/NodeGetVolumeStats":              {},
+}
+
+type volumeIDGetter interface {
+	GetVolumeId() string
+}
+
+type nodeIDGetter interface {
+	GetNodeId() string
+}
+
+// requestFields returns the structured key/value pairs identifying a CSI request.
+func requestFields(method, requestID string, req any) []any {
+	fields := []any{"method", method, requestIDKey, requestID}
+
+	if r, ok := req.(volumeIDGetter); ok && r.GetVolumeId() != "" {
+		fields = append(fields, common.LogKeyVolumeID, r.GetVolumeId())
+	}
+
+	if r, ok := req.(nodeIDGetter); ok && r.GetNodeId() != "" {
+		fields = append(fields, common.LogKeyNodeID, r.GetNodeId())
+	}
+
+	return fields
+}
+
+// sanitizeRequest strips secrets from req if it is a protobuf message.
+// Non-protobuf requests are not logged at all since they cannot be sanitized.
+func sanitizeRequest(req any) any {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return nil
+	}
+
+	return StripSecrets(msg)
+}
+
+// UnaryServerInterceptor logs every unary RPC with a unique request ID.
+// The request is logged with secrets removed when it is received, and the method,
+// duration and resulting gRPC code are logged when it completes.
+// A logger carrying the request ID is attached to the handler's context.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		requestID := uuid.NewString()
+		fields := requestFields(info.FullMethod, requestID, req)
+
+		if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.HasTraceID() {
+			fields = append(fields, "traceID", spanCtx.TraceID().String())
+		}
+
+		logger := klog.FromContext(ctx).WithValues(fields...)
+		ctx = klog.NewContext(ctx, logger)
+
+		infoLogger := logger
+		if _, ok := routineMethods[info.FullMethod]; ok {
+			infoLogger = logger.V(routineLogLevel)
+		}
+
+		infoLogger.Info("Received request", "request", sanitizeRequest(req))
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		duration := time.Since(start)
+
+		code := status.Code(err)
+		if err != nil {
+			logger.Error(err, "Request failed", "duration", duration, "code", code.String())
+		} else {
+			infoLogger.Info("Request completed", "duration", duration, "code", code.String())
+		}
+
+		return resp, err
+	}
+}
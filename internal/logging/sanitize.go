@@ -0,0 +1,107 @@
+package logging
+
+import (
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// RedactedValue replaces the value of every sensitive field in a sanitized message.
+const RedactedValue = "***redacted***"
+
+// sensitiveContextKeys are volume/publish context keys which carry credentials
+// even though the CSI spec does not mark the surrounding field as a secret.
+//
+//nolint:gochecknoglobals // can't construct const map
+var sensitiveContextKeys = map[string]struct{}{
+	// Service account tokens passed by kubelet when the CSIDriver object sets tokenRequests
+	"csi.storage.k8s.io/serviceAccount.tokens": {},
+}
+
+// StripSecrets returns a copy of msg which is safe to log. Fields marked with
+// the csi_secret option are redacted, as are known sensitive context keys.
+// The original message is not modified.
+func StripSecrets(msg proto.Message) proto.Message {
+	if msg == nil {
+		return nil
+	}
+
+	sanitized := proto.Clone(msg)
+	stripMessage(sanitized.ProtoReflect())
+
+	return sanitized
+}
+
+func isSecretField(fd protoreflect.FieldDescriptor) bool {
+	isSecret, ok := proto.GetExtension(fd.Options(), csi.E_CsiSecret).(bool)
+
+	return ok && isSecret
+}
+
+func isStringMap(fd protoreflect.FieldDescriptor) bool {
+	return fd.IsMap() && fd.MapValue().Kind() == protoreflect.StringKind
+}
+
+func stripMessage(m protoreflect.Message) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case isSecretField(fd):
+			redactField(m, fd, v)
+		case isStringMap(fd):
+			redactMapKeys(v.Map(), sensitiveContextKeys)
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+				stripMessage(mv.Message())
+
+				return true
+			})
+		case fd.IsList() && fd.Message() != nil:
+			list := v.List()
+			for i := range list.Len() {
+				stripMessage(list.Get(i).Message())
+			}
+		case fd.Message() != nil:
+			stripMessage(v.Message())
+		default:
+			// Scalar fields are not sensitive unless marked as a secret
+		}
+
+		return true
+	})
+}
+
+// redactField replaces the contents of a secret field while preserving its shape,
+// so that logs still show which secrets were supplied.
+func redactField(m protoreflect.Message, fd protoreflect.FieldDescriptor, v protoreflect.Value) {
+	switch {
+	case isStringMap(fd):
+		redactMapKeys(v.Map(), nil)
+	case fd.Kind() == protoreflect.StringKind && !fd.IsList():
+		m.Set(fd, protoreflect.ValueOfString(RedactedValue))
+	default:
+		m.Clear(fd)
+	}
+}
+
+// redactMapKeys redacts the values of keys present in keys, or every value if keys is nil.
+func redactMapKeys(mv protoreflect.Map, keys map[string]struct{}) {
+	var toRedact []protoreflect.MapKey
+
+	mv.Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+		if keys == nil {
+			toRedact = append(toRedact, k)
+
+			return true
+		}
+
+		if _, ok := keys[k.String()]; ok {
+			toRedact = append(toRedact, k)
+		}
+
+		return true
+	})
+
+	for _, k := range toRedact {
+		mv.Set(k, protoreflect.ValueOfString(RedactedValue))
+	}
+}
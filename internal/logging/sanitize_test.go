@@ -0,0 +1,87 @@
+package logging_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/logging"
+)
+
+const (
+	secretValue = "super-secret-passphrase"
+	tokensKey   = "csi.storage.k8s.io/serviceAccount.tokens"
+)
+
+func TestStripSecrets_NodePublishVolume(t *testing.T) {
+	t.Parallel()
+
+	request := &csi.NodePublishVolumeRequest{
+		VolumeId:   "vol-123",
+		TargetPath: "/var/lib/kubelet/pods/abc/volumes/mount",
+		Secrets:    map[string]string{"passphrase": secretValue},
+		VolumeContext: map[string]string{
+			"csi.crusoe.ai/serial-number": "SERIAL",
+			tokensKey:                     secretValue,
+		},
+	}
+
+	sanitized, ok := logging.StripSecrets(request).(*csi.NodePublishVolumeRequest)
+	if !ok {
+		t.Fatal("expected sanitized message to be a NodePublishVolumeRequest")
+	}
+
+	if got := sanitized.GetSecrets()["passphrase"]; got != logging.RedactedValue {
+		t.Errorf("expected secret to be redacted, got %q", got)
+	}
+
+	if got := sanitized.GetVolumeContext()[tokensKey]; got != logging.RedactedValue {
+		t.Errorf("expected service account tokens to be redacted, got %q", got)
+	}
+
+	if got := sanitized.GetVolumeContext()["csi.crusoe.ai/serial-number"]; got != "SERIAL" {
+		t.Errorf("expected non-sensitive context to be preserved, got %q", got)
+	}
+
+	if sanitized.GetVolumeId() != "vol-123" {
+		t.Errorf("expected volume ID to be preserved, got %q", sanitized.GetVolumeId())
+	}
+
+	if strings.Contains(sanitized.String(), secretValue) {
+		t.Errorf("sanitized message still contains secret: %s", sanitized.String())
+	}
+
+	// The original request must not be modified
+	if request.GetSecrets()["passphrase"] != secretValue {
+		t.Error("expected original request secrets to be untouched")
+	}
+}
+
+func TestStripSecrets_CreateVolume(t *testing.T) {
+	t.Parallel()
+
+	request := &csi.CreateVolumeRequest{
+		Name: "pvc-123",
+		VolumeContentSource: &csi.VolumeContentSource{
+			Type: &csi.VolumeContentSource_Snapshot{
+				Snapshot: &csi.VolumeContentSource_SnapshotSource{SnapshotId: "snap-1"},
+			},
+		},
+		Secrets: map[string]string{"key": secretValue},
+	}
+
+	sanitized := logging.StripSecrets(request)
+
+	if strings.Contains(fmt.Sprint(sanitized), secretValue) {
+		t.Errorf("sanitized message still contains secret: %v", sanitized)
+	}
+}
+
+func TestStripSecrets_Nil(t *testing.T) {
+	t.Parallel()
+
+	if logging.StripSecrets(nil) != nil {
+		t.Error("expected nil message to remain nil")
+	}
+}
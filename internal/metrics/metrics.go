@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// MeterName is the instrumentation scope used for all instruments created by the driver.
+const MeterName = "github.com/crusoecloud/crusoe-csi-driver"
+
+// Metric attribute keys shared across packages.
+const (
+	OutcomeKey = attribute.Key("outcome")
+	FlagKey    = attribute.Key("flag")
+)
+
+// Outcomes of a corrupted mount repair or a flag fetch.
+const (
+	OutcomeRepaired = "repaired"
+	OutcomeSuccess  = "success"
+	OutcomeFailed   = "failed"
+)
+
+var errEmptyEndpoint = errors.New("metrics endpoint must be provided")
+
+// Config configures the OTLP metric exporter.
+type Config struct {
+	Endpoint       string
+	ServiceName    string
+	ServiceVersion string
+	Insecure       bool
+	// Interval is how often metrics are exported, zero uses the SDK default of one minute.
+	Interval time.Duration
+}
+
+// Setup installs a global meter provider which periodically exports metrics over OTLP/gRPC.
+// The returned function flushes and shuts down the provider and should be called on exit.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	if cfg.Endpoint == "" {
+		return nil, errEmptyEndpoint
+	}
+
+	opts := []otlpmetricgrpc.Option{otlpmetricgrpc.WithEndpoint(cfg.Endpoint)}
+	if cfg.Insecure {
+		opts = append(opts, otlpmetricgrpc.WithInsecure())
+	}
+
+	exporter, err := otlpmetricgrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP metric exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName),
+		semconv.ServiceVersion(cfg.ServiceVersion),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metric resource: %w", err)
+	}
+
+	var readerOpts []sdkmetric.PeriodicReaderOption
+	if cfg.Interval > 0 {
+		readerOpts = append(readerOpts, sdkmetric.WithInterval(cfg.Interval))
+	}
+
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter, readerOpts...)),
+		sdkmetric.WithResource(res),
+	)
+
+	otel.SetMeterProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// RecordMountRepair counts an attempt to repair a corrupted mount point.
+// Unless Setup installed a meter provider the global no-op provider discards the measurement.
+func RecordMountRepair(ctx context.Context, outcome string) {
+	counter, err := otel.Meter(MeterName).Int64Counter("csi.node.mount_repairs",
+		metric.WithDescription("Corrupted or stale mount points detected and unmounted before remounting"))
+	if err != nil {
+		otel.Handle(err)
+
+		return
+	}
+
+	counter.Add(ctx, 1, metric.WithAttributes(OutcomeKey.String(outcome)))
+}
+
+// RecordFlagFetch counts a request to the project feature flag API and records its latency.
+// Unless Setup installed a meter provider the global no-op provider discards the measurements.
+func RecordFlagFetch(ctx context.Context, flag, outcome string, duration time.Duration) {
+	meter := otel.Meter(MeterName)
+	attributes := metric.WithAttributes(FlagKey.String(flag), OutcomeKey.String(outcome))
+
+	counter, err := meter.Int64Counter("csi.flags.fetches",
+		metric.WithDescription("Requests to the project feature flag API"))
+	if err != nil {
+		otel.Handle(err)
+
+		return
+	}
+
+	counter.Add(ctx, 1, attributes)
+
+	histogram, err := meter.Float64Histogram("csi.flags.fetch_duration",
+		metric.WithDescription("Latency of requests to the project feature flag API"),
+		metric.WithUnit("s"))
+	if err != nil {
+		otel.Handle(err)
+
+		return
+	}
+
+	histogram.Record(ctx, duration.Seconds(), attributes)
+}
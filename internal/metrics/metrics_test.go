@@ -0,0 +1,118 @@
+package metrics_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/crusoecloud/crusoe-csi-driver/internal/metrics"
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// installReader installs a global meter provider whose measurements are collected by the returned reader.
+func installReader(t *testing.T) *sdkmetric.ManualReader {
+	t.Helper()
+
+	reader := sdkmetric.NewManualReader()
+	previous := otel.GetMeterProvider()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	t.Cleanup(func() { otel.SetMeterProvider(previous) })
+
+	return reader
+}
+
+// collect returns the metric named name, failing the test if it was not recorded.
+func collect(t *testing.T, reader *sdkmetric.ManualReader, name string) metricdata.Aggregation {
+	t.Helper()
+
+	var resourceMetrics metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &resourceMetrics); err != nil {
+		t.Fatalf("failed to collect metrics: %v", err)
+	}
+
+	for _, scopeMetrics := range resourceMetrics.ScopeMetrics {
+		for _, m := range scopeMetrics.Metrics {
+			if m.Name == name {
+				return m.Data
+			}
+		}
+	}
+
+	t.Fatalf("expected metric %s to be recorded, got %v", name, resourceMetrics.ScopeMetrics)
+
+	return nil
+}
+
+// counts returns the value of each data point of sum keyed by its outcome.
+func counts(t *testing.T, data metricdata.Aggregation) map[string]int64 {
+	t.Helper()
+
+	sum, ok := data.(metricdata.Sum[int64])
+	if !ok {
+		t.Fatalf("expected an int64 sum, got %T", data)
+	}
+
+	values := make(map[string]int64)
+	for _, point := range sum.DataPoints {
+		outcome, _ := point.Attributes.Value(metrics.OutcomeKey)
+		values[outcome.AsString()] = point.Value
+	}
+
+	return values
+}
+
+//nolint:paralleltest // replaces the global meter provider
+func TestRecordMountRepair(t *testing.T) {
+	reader := installReader(t)
+
+	metrics.RecordMountRepair(context.Background(), metrics.OutcomeRepaired)
+	metrics.RecordMountRepair(context.Background(), metrics.OutcomeRepaired)
+	metrics.RecordMountRepair(context.Background(), metrics.OutcomeFailed)
+
+	got := counts(t, collect(t, reader, "csi.node.mount_repairs"))
+	if got[metrics.OutcomeRepaired] != 2 || got[metrics.OutcomeFailed] != 1 {
+		t.Errorf("expected 2 repaired and 1 failed repair, got %v", got)
+	}
+}
+
+func TestSetup_EmptyEndpoint(t *testing.T) {
+	t.Parallel()
+
+	if _, err := metrics.Setup(context.Background(), metrics.Config{}); err == nil {
+		t.Error("expected an error without an endpoint")
+	}
+}
+
+//nolint:paralleltest // replaces the global meter provider
+func TestRecordFlagFetch(t *testing.T) {
+	reader := installReader(t)
+
+	metrics.RecordFlagFetch(context.Background(), "nfs", metrics.OutcomeSuccess, 100*time.Millisecond)
+	metrics.RecordFlagFetch(context.Background(), "nfs", metrics.OutcomeFailed, 2*time.Second)
+
+	got := counts(t, collect(t, reader, "csi.flags.fetches"))
+	if got[metrics.OutcomeSuccess] != 1 || got[metrics.OutcomeFailed] != 1 {
+		t.Errorf("expected 1 successful and 1 failed fetch, got %v", got)
+	}
+
+	data := collect(t, reader, "csi.flags.fetch_duration")
+	histogram, ok := data.(metricdata.Histogram[float64])
+	if !ok {
+		t.Fatalf("expected a float64 histogram, got %T", data)
+	}
+
+	var fetches uint64
+	for _, point := range histogram.DataPoints {
+		if flag, _ := point.Attributes.Value(metrics.FlagKey); flag.AsString() != "nfs" {
+			t.Errorf("expected the flag to be recorded, got %v", point.Attributes)
+		}
+
+		fetches += point.Count
+	}
+
+	if fetches != 2 {
+		t.Errorf("expected the latency of 2 fetches, got %d", fetches)
+	}
+}
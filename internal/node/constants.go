@@ -6,4 +6,8 @@ const (
 	ExpectedTypeSegments = 2
 	ReadOnlyMountOption  = "ro"
 	NoLoadMountOption    = "noload"
+
+	SysDevBlockPath = "/sys/dev/block"
+	// SysfsSectorSize is the unit of sysfs block device sizes, regardless of the device's logical block size.
+	SysfsSectorSize = 512
 )
@@ -20,6 +20,14 @@ var (
 	ErrFailedResize      = errors.New("failed to resize disk")
 	ErrVolumeIDEmpty     = errors.New("volume ID must be provided")
 	ErrVolumePathEmpty   = errors.New("volume path must be provided")
+	ErrStagingPathEmpty  = errors.New("staging target path must be provided")
+	ErrTargetPathEmpty   = errors.New("target path must be provided")
+	ErrCapabilityEmpty   = errors.New("volume capability must be provided")
+	ErrStillPublished    = errors.New("volume is still published")
 	ErrVolumePathStat    = errors.New("failed to stat volume path")
 	ErrStatfs            = errors.New("failed to statfs volume path")
+	ErrNotBlockDevice    = errors.New("path is not a block device")
+	ErrFormatRefused     = errors.New("refusing to format device")
+	ErrFilesystemErrors  = errors.New("filesystem has errors which could not be repaired")
+	ErrNoNFSTarget       = errors.New("no NFS target resolver returned a target")
 )
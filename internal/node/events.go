@@ -0,0 +1,28 @@
+package node
+
+import (
+	"errors"
+
+	"github.com/crusoecloud/crusoe-csi-driver/internal/events"
+	"k8s.io/mount-utils"
+)
+
+// PublishFailureReason maps a NodePublishVolume error to the event reason describing the failed step.
+func PublishFailureReason(err error) string {
+	var mountErr mount.MountError
+	isMountErr := errors.As(err, &mountErr)
+
+	if (isMountErr && mountErr.Type == mount.FormatFailed) || errors.Is(err, ErrFormatRefused) {
+		return events.ReasonFormatFailed
+	}
+
+	if (isMountErr && mountErr.Type == mount.HasFilesystemErrors) || errors.Is(err, ErrFilesystemErrors) {
+		return events.ReasonFilesystemErrors
+	}
+
+	if errors.Is(err, ErrFailedResize) {
+		return events.ReasonResizeFailed
+	}
+
+	return events.ReasonMountFailed
+}
@@ -0,0 +1,6 @@
+package node
+
+// RepairCorruptedMountWithStat lets tests simulate the stat errors of a corrupted mount.
+//
+//nolint:gochecknoglobals // test hook
+var RepairCorruptedMountWithStat = repairCorruptedMount
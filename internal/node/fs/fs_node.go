@@ -2,163 +2,267 @@ package fs
 
 import (
 	"context"
-	"net/http"
+	"fmt"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	crusoeapi "github.com/crusoecloud/client-go/swagger/v1alpha5"
 	"github.com/crusoecloud/crusoe-csi-driver/internal/common"
 	"github.com/crusoecloud/crusoe-csi-driver/internal/crusoe"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/events"
 	"github.com/crusoecloud/crusoe-csi-driver/internal/node"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/tracing"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/klog/v2"
 	"k8s.io/mount-utils"
 )
 
-const (
-	crusoeCloudDNSNFSHost = "nfs.crusoecloudcompute.com"
-	icatLocation          = "eu-iceland1-a"
-	dnsRemotePorts        = "dns"
-)
-
 type Node struct {
 	csi.UnimplementedNodeServer
-	CrusoeClient      *crusoeapi.APIClient
-	CrusoeHTTPClient  *http.Client
+	Flags crusoe.FlagSource
+	// NFSTargets resolves the NFS server each volume is mounted from
+	NFSTargets        NFSTargetChain
 	HostInstance      *crusoeapi.InstanceV1Alpha5
+	Events            events.Recorder
 	Mounter           *mount.SafeFormatAndMount
 	Resizer           *mount.ResizeFs
-	CrusoeAPIEndpoint string
-	NFSRemotePorts    string
-	NFSHost           string
 	DiskType          common.DiskType
 	PluginName        string
 	PluginVersion     string
 	Capabilities      []*csi.NodeServiceCapability
 	MaxVolumesPerNode int64
+	VolumeHealth      node.VolumeHealthChecker
+	// OperationTimeout bounds each mount and unmount, zero leaves only the RPC deadline
+	OperationTimeout time.Duration
+
+	// locks rejects overlapping operations on the same volume and path
+	locks node.VolumeLocks
+	// nfsTargets holds the NFSTarget of each volume staged over NFS, it is reported in the volume condition
+	nfsTargets sync.Map
 }
 
-func (d *Node) NodeStageVolume(_ context.Context, _ *csi.NodeStageVolumeRequest) (
+func (d *Node) NodeStageVolume(ctx context.Context, request *csi.NodeStageVolumeRequest) (
 	*csi.NodeStageVolumeResponse,
 	error,
 ) {
-	klog.Errorf("%s: NodeStageVolume", common.ErrNotImplemented)
-
-	return nil, status.Errorf(codes.Unimplemented, "%s: NodeStageVolume", common.ErrNotImplemented)
-}
-
-func (d *Node) NodeUnstageVolume(_ context.Context, _ *csi.NodeUnstageVolumeRequest) (
-	*csi.NodeUnstageVolumeResponse,
-	error,
-) {
-	klog.Errorf("%s: NodeUnstageVolume", common.ErrNotImplemented)
+	if err := node.ValidateStageRequest(request); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid stage request: %s", err)
+	}
 
-	return nil, status.Errorf(codes.Unimplemented, "%s: NodeUnstageVolume", common.ErrNotImplemented)
-}
+	if _, err := common.NFSMountOptions(request.GetVolumeContext()); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid stage request: %s", err)
+	}
 
-func (d *Node) NodePublishVolume(ctx context.Context, request *csi.NodePublishVolumeRequest) (
-	*csi.NodePublishVolumeResponse,
-	error,
-) {
-	klog.Infof("Received request to publish volume: %+v", request)
+	release, err := d.locks.Lock(request.GetVolumeId(), request.GetStagingTargetPath())
+	if err != nil {
+		//nolint:wrapcheck // error is already a gRPC status; wrapping would lose the status code
+		return nil, err
+	}
+	defer release()
 
-	nfsEnabled, err := crusoe.GetNFSFlag(ctx, d.CrusoeHTTPClient, d.CrusoeAPIEndpoint, d.HostInstance.ProjectId)
+	nfsEnabled, err := d.Flags.Bool(ctx, d.HostInstance.ProjectId, crusoe.ProjectFlagNFS)
 	if err != nil {
-		klog.Errorf("%s: %s", node.ErrFailedToFetchNFSFlag, err)
+		klog.ErrorS(err, node.ErrFailedToFetchNFSFlag.Error(), common.LogKeyVolumeID, request.GetVolumeId())
 
 		return nil, status.Errorf(codes.Internal, "%s: %s", node.ErrFailedToFetchNFSFlag, err)
 	}
-	klog.Infof("NFS enabled: %v", nfsEnabled)
+	klog.InfoS("Fetched NFS flag", common.LogKeyVolumeID, request.GetVolumeId(), "nfsEnabled", nfsEnabled)
 
 	var mountOpts []string
 
-	if request.GetReadonly() {
+	if node.IsReadOnlyAccessMode(request.GetVolumeCapability()) {
 		// Read-only volumes cannot be written to in any way
 		mountOpts = append(mountOpts, node.ReadOnlyMountOption)
 	}
 
-	nfsHost, nfsRemotePorts := d.resolveNFSTarget(ctx, request.GetVolumeId(), nfsEnabled)
+	var target NFSTarget
+	if nfsEnabled {
+		target, err = d.NFSTargets.Resolve(ctx, NFSTargetQuery{
+			VolumeID:  request.GetVolumeId(),
+			ProjectID: d.HostInstance.ProjectId,
+			Location:  d.HostInstance.Location,
+		})
+		if err != nil {
+			klog.ErrorS(err, "Failed to resolve NFS target", common.LogKeyVolumeID, request.GetVolumeId())
 
-	err = nodePublishVolume(d.Mounter, d.Resizer, mountOpts, nfsEnabled, nfsRemotePorts, nfsHost, request)
+			return nil, status.Errorf(codes.FailedPrecondition, "failed to stage volume %s: %s",
+				request.GetVolumeId(), err)
+		}
+	}
+
+	err = nodeStageVolume(ctx, d.Mounter, mountOpts, nfsEnabled, target.RemotePorts, target.Host, d.OperationTimeout,
+		request)
 	if err != nil {
-		klog.Errorf("failed to publish volume %s: %s", request.GetVolumeId(), err.Error())
+		klog.ErrorS(err, "Failed to stage volume",
+			common.LogKeyVolumeID, request.GetVolumeId(),
+			common.LogKeyTargetPath, request.GetStagingTargetPath())
+		reason := node.PublishFailureReason(err)
+		d.Events.VolumeEventf(request.GetVolumeId(), corev1.EventTypeWarning, reason,
+			"failed to stage volume on node %s: %s", d.HostInstance.Name, err)
+		d.Events.NodeEventf(corev1.EventTypeWarning, reason,
+			"failed to stage volume %s: %s", request.GetVolumeId(), err)
+
+		return nil, status.Errorf(node.OperationErrorCode(err), "failed to stage volume %s: %s",
+			request.GetVolumeId(), err.Error())
+	}
 
-		return nil, status.Errorf(codes.Internal, "failed to publish volume %s: %s", request.GetVolumeId(), err.Error())
+	if nfsEnabled {
+		d.nfsTargets.Store(request.GetVolumeId(), target)
 	}
 
-	klog.Infof("Successfully published volume: %s", request.GetVolumeId())
+	klog.InfoS("Successfully staged volume",
+		common.LogKeyVolumeID, request.GetVolumeId(),
+		common.LogKeyTargetPath, request.GetStagingTargetPath())
 
-	return &csi.NodePublishVolumeResponse{}, nil
+	return &csi.NodeStageVolumeResponse{}, nil
 }
 
-// resolveNFSTarget determines the NFS host and remoteports value to use when
-// publishing a volume. It prefers per-disk data path connectivity returned by
-// the storage API (dns_name / vips, CRUSOE-60428), falling back to legacy
-// configuration (the ICAT secondary-cluster DNS escape hatch and finally the
-// CLI-flag defaults) when the API does not yet populate those fields.
-func (d *Node) resolveNFSTarget(
-	ctx context.Context, volumeID string, nfsEnabled bool,
-) (nfsHost, nfsRemotePorts string) {
-	if nfsEnabled && volumeID != "" {
-		disk, err := crusoe.FindDiskByIDFallible(ctx, d.CrusoeClient, d.HostInstance.ProjectId, volumeID)
-		if err != nil {
-			klog.Warningf("failed to fetch disk %s for NFS target resolution, falling back to defaults: %s",
-				volumeID, err.Error())
-		} else if host, remotePorts, ok := crusoe.ResolveNFSTarget(disk); ok {
-			klog.Infof("Resolved NFS target from disk API for %s: host=%s remoteports=%s",
-				volumeID, host, remotePorts)
-
-			return host, remotePorts
-		} else {
-			klog.Warningf("disk %s did not return data path connectivity fields; falling back to defaults",
-				volumeID)
-		}
+func (d *Node) NodeUnstageVolume(ctx context.Context, request *csi.NodeUnstageVolumeRequest) (
+	*csi.NodeUnstageVolumeResponse,
+	error,
+) {
+	if request.GetVolumeId() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid unstage request: %s", node.ErrVolumeIDEmpty)
+	}
+
+	stagingPath := request.GetStagingTargetPath()
+	if stagingPath == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid unstage request: %s", node.ErrStagingPathEmpty)
+	}
+
+	release, err := d.locks.Lock(request.GetVolumeId(), stagingPath)
+	if err != nil {
+		//nolint:wrapcheck // error is already a gRPC status; wrapping would lose the status code
+		return nil, err
+	}
+	defer release()
+
+	// The shared mount must outlive every pod using it, only unstage once the last publish is gone
+	refs, err := node.StagingPathRefs(ctx, d.Mounter, stagingPath, d.OperationTimeout)
+	if err != nil {
+		klog.ErrorS(err, "Failed to check staging mount point references",
+			common.LogKeyVolumeID, request.GetVolumeId(),
+			common.LogKeyTargetPath, stagingPath)
+
+		return nil, status.Errorf(node.OperationErrorCode(err), "failed to check if volume %s is still published: %s",
+			request.GetVolumeId(), err.Error())
 	}
 
-	nfsHost = d.NFSHost
-	nfsRemotePorts = d.NFSRemotePorts
-	klog.Infof("Host instance location: %q, checking against icatLocation: %q", d.HostInstance.Location, icatLocation)
-	if d.useDNSForMount(ctx) {
-		klog.Warningf("falling back to ICAT DNS-based NFS host: %s", crusoeCloudDNSNFSHost)
-		nfsHost = crusoeCloudDNSNFSHost
-		nfsRemotePorts = dnsRemotePorts
-	} else {
-		klog.Warningf("falling back to configured IP-based NFS host: %s with remote ports: %s",
-			nfsHost, nfsRemotePorts)
+	if len(refs) > 0 {
+		klog.InfoS("Refusing to unstage volume which is still published",
+			common.LogKeyVolumeID, request.GetVolumeId(),
+			common.LogKeyTargetPath, stagingPath,
+			"publishedTo", refs)
+
+		return nil, status.Errorf(codes.FailedPrecondition, "%s: volume %s is mounted at %s",
+			node.ErrStillPublished, request.GetVolumeId(), strings.Join(refs, ", "))
+	}
+
+	_, span := tracing.Start(ctx, "CleanupMountPoint", tracing.TargetPathKey.String(stagingPath))
+	err = node.CleanupMountPoint(ctx, d.Mounter, stagingPath, d.OperationTimeout)
+	tracing.End(span, err)
+	if err != nil {
+		klog.ErrorS(err, "Failed to cleanup staging mount point",
+			common.LogKeyVolumeID, request.GetVolumeId(),
+			common.LogKeyTargetPath, stagingPath)
+		d.Events.VolumeEventf(request.GetVolumeId(), corev1.EventTypeWarning, events.ReasonUnmountFailed,
+			"failed to unstage volume on node %s: %s", d.HostInstance.Name, err)
+		d.Events.NodeEventf(corev1.EventTypeWarning, events.ReasonUnmountFailed,
+			"failed to unstage volume %s: %s", request.GetVolumeId(), err)
+
+		return nil, status.Errorf(node.OperationErrorCode(err), "failed to cleanup staging mount point for volume %s: %s",
+			request.GetVolumeId(), err.Error())
 	}
 
-	return nfsHost, nfsRemotePorts
+	d.nfsTargets.Delete(request.GetVolumeId())
+
+	klog.InfoS("Successfully unstaged volume",
+		common.LogKeyVolumeID, request.GetVolumeId(),
+		common.LogKeyTargetPath, stagingPath)
+
+	return &csi.NodeUnstageVolumeResponse{}, nil
 }
 
-func (d *Node) useDNSForMount(ctx context.Context) bool {
-	useSecondaryVast, err := crusoe.GetVastUseSecondaryClusterFlag(
-		ctx, d.CrusoeHTTPClient, d.CrusoeAPIEndpoint, d.HostInstance.ProjectId)
+func (d *Node) NodePublishVolume(ctx context.Context, request *csi.NodePublishVolumeRequest) (
+	*csi.NodePublishVolumeResponse,
+	error,
+) {
+	if err := node.ValidateVolumeMountGroup(request.GetVolumeCapability()); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid publish request: %s", err)
+	}
+
+	release, err := d.locks.Lock(request.GetVolumeId(), request.GetTargetPath())
 	if err != nil {
-		klog.Errorf("failed to fetch VastUseSecondaryCluster flag: %s", err.Error())
+		//nolint:wrapcheck // error is already a gRPC status; wrapping would lose the status code
+		return nil, err
+	}
+	defer release()
 
-		return false
+	var mountOpts []string
+
+	if request.GetReadonly() {
+		// Read-only volumes cannot be written to in any way
+		mountOpts = append(mountOpts, node.ReadOnlyMountOption)
 	}
 
-	return useSecondaryVast && d.HostInstance.Location == icatLocation
+	err = nodePublishVolume(ctx, d.Mounter, mountOpts, d.OperationTimeout, request)
+	if err != nil {
+		klog.ErrorS(err, "Failed to publish volume",
+			common.LogKeyVolumeID, request.GetVolumeId(),
+			common.LogKeyTargetPath, request.GetTargetPath())
+		reason := node.PublishFailureReason(err)
+		d.Events.VolumeEventf(request.GetVolumeId(), corev1.EventTypeWarning, reason,
+			"failed to publish volume on node %s: %s", d.HostInstance.Name, err)
+		d.Events.NodeEventf(corev1.EventTypeWarning, reason,
+			"failed to publish volume %s: %s", request.GetVolumeId(), err)
+
+		return nil, status.Errorf(node.OperationErrorCode(err), "failed to publish volume %s: %s",
+			request.GetVolumeId(), err.Error())
+	}
+
+	klog.InfoS("Successfully published volume",
+		common.LogKeyVolumeID, request.GetVolumeId(),
+		common.LogKeyTargetPath, request.GetTargetPath())
+
+	return &csi.NodePublishVolumeResponse{}, nil
 }
 
-func (d *Node) NodeUnpublishVolume(_ context.Context, request *csi.NodeUnpublishVolumeRequest) (
+func (d *Node) NodeUnpublishVolume(ctx context.Context, request *csi.NodeUnpublishVolumeRequest) (
 	*csi.NodeUnpublishVolumeResponse,
 	error,
 ) {
-	klog.Infof("Received request to unpublish volume: %+v", request)
+	release, err := d.locks.Lock(request.GetVolumeId(), request.GetTargetPath())
+	if err != nil {
+		//nolint:wrapcheck // error is already a gRPC status; wrapping would lose the status code
+		return nil, err
+	}
+	defer release()
 
 	targetPath := request.GetTargetPath()
-	err := mount.CleanupMountPoint(targetPath, d.Mounter, false)
+	_, span := tracing.Start(ctx, "CleanupMountPoint", tracing.TargetPathKey.String(targetPath))
+	err = node.CleanupMountPoint(ctx, d.Mounter, targetPath, d.OperationTimeout)
+	tracing.End(span, err)
 	if err != nil {
-		klog.Errorf("failed to cleanup mount point for volume %s: %s", request.GetVolumeId(), err.Error())
-
-		return nil, status.Errorf(codes.Internal, "failed to cleanup mount point for volume %s: %s",
+		klog.ErrorS(err, "Failed to cleanup mount point",
+			common.LogKeyVolumeID, request.GetVolumeId(),
+			common.LogKeyTargetPath, targetPath)
+		d.Events.VolumeEventf(request.GetVolumeId(), corev1.EventTypeWarning, events.ReasonUnmountFailed,
+			"failed to unpublish volume on node %s: %s", d.HostInstance.Name, err)
+		d.Events.NodeEventf(corev1.EventTypeWarning, events.ReasonUnmountFailed,
+			"failed to unpublish volume %s: %s", request.GetVolumeId(), err)
+
+		return nil, status.Errorf(node.OperationErrorCode(err), "failed to cleanup mount point for volume %s: %s",
 			request.GetVolumeId(), err.Error())
 	}
 
-	klog.Infof("Successfully unpublished volume: %s", request.GetVolumeId())
+	klog.InfoS("Successfully unpublished volume",
+		common.LogKeyVolumeID, request.GetVolumeId(),
+		common.LogKeyTargetPath, targetPath)
 
 	return &csi.NodeUnpublishVolumeResponse{}, nil
 }
@@ -167,20 +271,43 @@ func (d *Node) NodeGetVolumeStats(_ context.Context, req *csi.NodeGetVolumeStats
 	*csi.NodeGetVolumeStatsResponse,
 	error,
 ) {
-	//nolint:wrapcheck // error is already a gRPC status; wrapping would lose the status code
-	return node.GetVolumeStats(req)
+	// Only NFS mounts have a checkable source, virtiofs mounts are identified by the disk name
+	expected := node.ExpectedVolume{ExportSuffix: ":" + nfsExportPath(req.GetVolumeId())}
+
+	resp, err := d.VolumeHealth.GetVolumeStatsWithCondition(req, expected)
+	if err != nil {
+		//nolint:wrapcheck // error is already a gRPC status; wrapping would lose the status code
+		return nil, err
+	}
+
+	d.applyNFSTarget(req.GetVolumeId(), resp)
+
+	return resp, nil
+}
+
+// applyNFSTarget adds the NFS server, and the resolver which chose it, to an otherwise normal volume condition.
+func (d *Node) applyNFSTarget(volumeID string, resp *csi.NodeGetVolumeStatsResponse) {
+	value, ok := d.nfsTargets.Load(volumeID)
+	if !ok || resp.GetVolumeCondition().GetAbnormal() {
+		return
+	}
+
+	target, _ := value.(NFSTarget)
+	resp.VolumeCondition = &csi.VolumeCondition{
+		Message: fmt.Sprintf("%s, mounted from NFS server %s chosen by the %s resolver",
+			resp.GetVolumeCondition().GetMessage(), target.Host, target.Resolver),
+	}
 }
 
-// NodeExpandVolume This function is currently unused.
-// common.DiskTypeFS disks do not require expansion on the node.
-// common.DiskTypeSSD disks would require expansion on the node if they supported online expansion.
+// NodeExpandVolume is never called, common.DiskTypeFS volumes are expanded by the storage backend alone
+// and do not require expansion on the node.
 func (d *Node) NodeExpandVolume(_ context.Context, _ *csi.NodeExpandVolumeRequest) (
 	*csi.NodeExpandVolumeResponse,
 	error,
 ) {
-	klog.Errorf("%s: NodeGetVolumeStats", common.ErrNotImplemented)
+	klog.ErrorS(common.ErrNotImplemented, "NodeExpandVolume")
 
-	return nil, status.Errorf(codes.Unimplemented, "%s: NodeGetVolumeStats", common.ErrNotImplemented)
+	return nil, status.Errorf(codes.Unimplemented, "%s: NodeExpandVolume", common.ErrNotImplemented)
 }
 
 func (d *Node) NodeGetCapabilities(_ context.Context, _ *csi.NodeGetCapabilitiesRequest) (
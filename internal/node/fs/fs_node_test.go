@@ -0,0 +1,97 @@
+package fs_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	crusoeapi "github.com/crusoecloud/client-go/swagger/v1alpha5"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/common"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/crusoe"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/events"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/node/fs"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/mount-utils"
+)
+
+var errFlagAPIDown = errors.New("flag API is down")
+
+// fakeFlags serves boolean flags from a map, or err for every flag if set.
+type fakeFlags struct {
+	bools map[crusoe.ProjectFlag]bool
+	err   error
+}
+
+func (f *fakeFlags) Bool(_ context.Context, _ string, name crusoe.ProjectFlag) (bool, error) {
+	return f.bools[name], f.err
+}
+
+func (f *fakeFlags) String(_ context.Context, _ string, _ crusoe.ProjectFlag) (string, error) {
+	return "", crusoe.ErrFlagTypeMismatch
+}
+
+func (f *fakeFlags) Int(_ context.Context, _ string, _ crusoe.ProjectFlag) (int, error) {
+	return 0, crusoe.ErrFlagTypeMismatch
+}
+
+func TestNode_NodeStageVolume_Flags(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		flags      *fakeFlags
+		wantCode   codes.Code
+		wantMounts int
+	}{
+		{
+			name:       "virtiofs when NFS is disabled",
+			flags:      &fakeFlags{bools: map[crusoe.ProjectFlag]bool{crusoe.ProjectFlagNFS: false}},
+			wantCode:   codes.OK,
+			wantMounts: 1,
+		},
+		{
+			name:     "flag API failure",
+			flags:    &fakeFlags{err: errFlagAPIDown},
+			wantCode: codes.Internal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockMnt := &mockMounter{}
+			d := &fs.Node{
+				Flags:        tt.flags,
+				HostInstance: &crusoeapi.InstanceV1Alpha5{ProjectId: "project", Name: "node"},
+				Events:       events.NoopRecorder{},
+				Mounter:      &mount.SafeFormatAndMount{Interface: mockMnt},
+			}
+
+			_, err := d.NodeStageVolume(context.Background(), &csi.NodeStageVolumeRequest{
+				VolumeId:          "test-volume-id",
+				StagingTargetPath: t.TempDir(),
+				VolumeCapability: &csi.VolumeCapability{
+					AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+					AccessMode: &csi.VolumeCapability_AccessMode{
+						Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+					},
+				},
+				VolumeContext: map[string]string{common.VolumeContextDiskNameKey: "test-disk-name"},
+			})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("expected code %s, got %s (%v)", tt.wantCode, code, err)
+			}
+
+			if len(mockMnt.mountCalls) != tt.wantMounts {
+				t.Fatalf("expected %d mount calls, got %d", tt.wantMounts, len(mockMnt.mountCalls))
+			}
+
+			if tt.wantMounts > 0 && mockMnt.mountCalls[0].fstype != "virtiofs" {
+				t.Errorf("expected fstype virtiofs, got %s", mockMnt.mountCalls[0].fstype)
+			}
+		})
+	}
+}
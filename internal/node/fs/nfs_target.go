@@ -0,0 +1,172 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	crusoeapi "github.com/crusoecloud/client-go/swagger/v1alpha5"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/common"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/crusoe"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/node"
+	"k8s.io/klog/v2"
+)
+
+// Names of the NFS target resolvers, used in the resolver config file, logs and volume conditions.
+const (
+	NFSTargetResolverDiskAPI  = "disk-api"
+	NFSTargetResolverFlag     = "flag"
+	NFSTargetResolverLocation = "location"
+	NFSTargetResolverStatic   = "static"
+)
+
+// NFSTarget is the NFS server a volume is mounted from.
+type NFSTarget struct {
+	Host        string `json:"host"`
+	RemotePorts string `json:"remotePorts"`
+	// Resolver is the name of the resolver which returned the target.
+	Resolver string `json:"-"`
+}
+
+// NFSTargetQuery identifies the volume an NFS target is resolved for.
+type NFSTargetQuery struct {
+	VolumeID  string
+	ProjectID string
+	Location  string
+}
+
+// NFSTargetResolver returns the NFS target of a volume.
+// Resolvers return ok false if they have no target for the volume, so that the next resolver is tried.
+type NFSTargetResolver interface {
+	Name() string
+	Resolve(ctx context.Context, query NFSTargetQuery) (target NFSTarget, ok bool, err error)
+}
+
+// NFSTargetChain tries each resolver in order, the first target returned wins.
+// Resolvers which fail are logged and skipped, later resolvers are the fallbacks of earlier ones.
+// Only node.ErrNoNFSTarget ends the chain, a resolver returns it if no later resolver may choose a target.
+type NFSTargetChain []NFSTargetResolver
+
+func (c NFSTargetChain) Resolve(ctx context.Context, query NFSTargetQuery) (NFSTarget, error) {
+	for _, resolver := range c {
+		target, ok, err := resolver.Resolve(ctx, query)
+		if errors.Is(err, node.ErrNoNFSTarget) {
+			return NFSTarget{}, err
+		}
+
+		if err != nil {
+			klog.ErrorS(err, "NFS target resolver failed, trying the next resolver",
+				common.LogKeyVolumeID, query.VolumeID, "resolver", resolver.Name())
+
+			continue
+		}
+
+		if !ok {
+			klog.InfoS("NFS target resolver has no target, trying the next resolver",
+				common.LogKeyVolumeID, query.VolumeID, "resolver", resolver.Name())
+
+			continue
+		}
+
+		target.Resolver = resolver.Name()
+		klog.InfoS("Resolved NFS target",
+			common.LogKeyVolumeID, query.VolumeID,
+			common.LogKeyLocation, query.Location,
+			"resolver", target.Resolver,
+			"nfsHost", target.Host,
+			"nfsRemotePorts", target.RemotePorts)
+
+		return target, nil
+	}
+
+	return NFSTarget{}, fmt.Errorf("%w: volume %s in location %s", node.ErrNoNFSTarget, query.VolumeID, query.Location)
+}
+
+// DiskGetter looks up a disk, crusoe.DiskClient is the implementation backed by the Crusoe API.
+type DiskGetter interface {
+	GetDisk(ctx context.Context, projectID, diskID string) (*crusoeapi.DiskV1Alpha5, error)
+}
+
+// DiskAPIResolver returns the per-disk data path connectivity of the storage API (dns_name / vips, CRUSOE-60428).
+type DiskAPIResolver struct {
+	Disks DiskGetter
+}
+
+func (r *DiskAPIResolver) Name() string {
+	return NFSTargetResolverDiskAPI
+}
+
+func (r *DiskAPIResolver) Resolve(ctx context.Context, query NFSTargetQuery) (NFSTarget, bool, error) {
+	disk, err := r.Disks.GetDisk(ctx, query.ProjectID, query.VolumeID)
+	if err != nil {
+		return NFSTarget{}, false, fmt.Errorf("failed to fetch disk: %w", err)
+	}
+
+	host, remotePorts, ok := crusoe.ResolveNFSTarget(disk)
+
+	return NFSTarget{Host: host, RemotePorts: remotePorts}, ok, nil
+}
+
+// FlagResolver returns Target in Locations while a boolean project flag is enabled,
+// e.g. the DNS name of the secondary VAST cluster in ICAT.
+type FlagResolver struct {
+	Flags     crusoe.FlagSource
+	Flag      crusoe.ProjectFlag
+	Locations []string
+	Target    NFSTarget
+}
+
+func (r *FlagResolver) Name() string {
+	return NFSTargetResolverFlag
+}
+
+func (r *FlagResolver) Resolve(ctx context.Context, query NFSTargetQuery) (NFSTarget, bool, error) {
+	if !slices.Contains(r.Locations, query.Location) {
+		return NFSTarget{}, false, nil
+	}
+
+	enabled, err := r.Flags.Bool(ctx, query.ProjectID, r.Flag)
+	if err != nil {
+		return NFSTarget{}, false, fmt.Errorf("failed to fetch flag %s: %w", r.Flag, err)
+	}
+
+	return r.Target, enabled, nil
+}
+
+// LocationResolver returns the target configured for the location of the node.
+type LocationResolver struct {
+	Targets map[string]NFSTarget
+	// Required fails locations without a target with node.ErrNoNFSTarget, rather than trying the next resolver.
+	Required bool
+}
+
+func (r *LocationResolver) Name() string {
+	return NFSTargetResolverLocation
+}
+
+func (r *LocationResolver) Resolve(_ context.Context, query NFSTargetQuery) (NFSTarget, bool, error) {
+	target, ok := r.Targets[query.Location]
+	if !ok && r.Required {
+		return NFSTarget{}, false, fmt.Errorf("%w: location %s has no NFS host and remote ports configured, "+
+			"the configured locations are %s", node.ErrNoNFSTarget, query.Location,
+			strings.Join(slices.Sorted(maps.Keys(r.Targets)), ", "))
+	}
+
+	return target, ok, nil
+}
+
+// StaticResolver always returns Target, it ends a chain.
+type StaticResolver struct {
+	Target NFSTarget
+}
+
+func (r *StaticResolver) Name() string {
+	return NFSTargetResolverStatic
+}
+
+func (r *StaticResolver) Resolve(_ context.Context, _ NFSTargetQuery) (NFSTarget, bool, error) {
+	return r.Target, true, nil
+}
@@ -0,0 +1,244 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+
+	"github.com/crusoecloud/crusoe-csi-driver/internal/crusoe"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	crusoeCloudDNSNFSHost = "nfs.crusoecloudcompute.com"
+	icatLocation          = "eu-iceland1-a"
+	dnsRemotePorts        = "dns"
+)
+
+var (
+	ErrInvalidNFSTargetConfig = errors.New("invalid NFS target resolver config")
+	ErrInvalidNFSTarget       = errors.New("invalid NFS target")
+)
+
+// NFSTargetConfig configures the NFS target resolver chain. It is read from a YAML or JSON file.
+//
+//	resolvers:
+//	- type: disk-api
+//	- type: location
+//	  targets:
+//	    us-east1-a: {host: 100.64.0.2, remotePorts: 100.64.0.2-100.64.0.17}
+//	- type: static
+type NFSTargetConfig struct {
+	Resolvers []NFSTargetResolverConfig `json:"resolvers"`
+}
+
+// NFSTargetResolverConfig configures one resolver, Type is one of the NFSTargetResolver* names.
+type NFSTargetResolverConfig struct {
+	Type string `json:"type"`
+	// Flag and Locations select when a flag resolver returns its target.
+	Flag      string   `json:"flag,omitempty"`
+	Locations []string `json:"locations,omitempty"`
+	// Host and RemotePorts are the target of flag and static resolvers.
+	// Static resolvers default to the --nfs-host and --nfs-remote-ports flags.
+	Host        string `json:"host,omitempty"`
+	RemotePorts string `json:"remotePorts,omitempty"`
+	// Targets are the targets of a location resolver, keyed by location.
+	Targets map[string]NFSTarget `json:"targets,omitempty"`
+	// Required makes a location resolver fail the volume if the location has no target,
+	// instead of trying the next resolver.
+	Required bool `json:"required,omitempty"`
+}
+
+// DefaultNFSTargetConfig is the chain used without a config file: the disk API,
+// the ICAT secondary-cluster DNS escape hatch and finally the --nfs-host and --nfs-remote-ports flags.
+// If locationTargets are set, they replace the flags and every location of the cluster needs a target.
+func DefaultNFSTargetConfig(locationTargets map[string]NFSTarget) NFSTargetConfig {
+	fallback := NFSTargetResolverConfig{Type: NFSTargetResolverStatic}
+	if len(locationTargets) > 0 {
+		fallback = NFSTargetResolverConfig{Type: NFSTargetResolverLocation, Targets: locationTargets, Required: true}
+	}
+
+	return NFSTargetConfig{Resolvers: []NFSTargetResolverConfig{
+		{Type: NFSTargetResolverDiskAPI},
+		{
+			Type:        NFSTargetResolverFlag,
+			Flag:        string(crusoe.ProjectFlagVastUseSecondaryCluster),
+			Locations:   []string{icatLocation},
+			Host:        crusoeCloudDNSNFSHost,
+			RemotePorts: dnsRemotePorts,
+		},
+		fallback,
+	}}
+}
+
+// LoadNFSTargetConfig reads the config file at path.
+// An empty path returns DefaultNFSTargetConfig with locationTargets, which may not be combined with a file.
+func LoadNFSTargetConfig(path string, locationTargets map[string]NFSTarget) (NFSTargetConfig, error) {
+	if path == "" {
+		return DefaultNFSTargetConfig(locationTargets), nil
+	}
+
+	if len(locationTargets) > 0 {
+		return NFSTargetConfig{}, fmt.Errorf("%w: location targets must be set in the config file %s",
+			ErrInvalidNFSTargetConfig, path)
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return NFSTargetConfig{}, fmt.Errorf("failed to read NFS target resolver config: %w", err)
+	}
+
+	var config NFSTargetConfig
+	if err := yaml.UnmarshalStrict(contents, &config); err != nil {
+		return NFSTargetConfig{}, fmt.Errorf("%w: %s: %w", ErrInvalidNFSTargetConfig, path, err)
+	}
+
+	return config, nil
+}
+
+// Build validates the config and returns its resolver chain.
+// fallback is the target of static resolvers which do not set one.
+func (c NFSTargetConfig) Build(disks DiskGetter, flags crusoe.FlagSource, fallback NFSTarget) (NFSTargetChain, error) {
+	if len(c.Resolvers) == 0 {
+		return nil, fmt.Errorf("%w: no resolvers", ErrInvalidNFSTargetConfig)
+	}
+
+	chain := make(NFSTargetChain, 0, len(c.Resolvers))
+
+	for i, resolverConfig := range c.Resolvers {
+		resolver, err := resolverConfig.build(disks, flags, fallback)
+		if err != nil {
+			return nil, fmt.Errorf("%w: resolver %d: %w", ErrInvalidNFSTargetConfig, i, err)
+		}
+
+		chain = append(chain, resolver)
+	}
+
+	return chain, nil
+}
+
+func (c NFSTargetResolverConfig) build(disks DiskGetter, flags crusoe.FlagSource, fallback NFSTarget) (
+	NFSTargetResolver,
+	error,
+) {
+	target := NFSTarget{Host: c.Host, RemotePorts: c.RemotePorts}
+
+	switch c.Type {
+	case NFSTargetResolverDiskAPI:
+		return &DiskAPIResolver{Disks: disks}, nil
+	case NFSTargetResolverFlag:
+		definition, err := crusoe.LookupFlag(crusoe.ProjectFlag(c.Flag))
+		if err != nil {
+			return nil, err
+		}
+
+		if definition.Type != crusoe.FlagTypeBool {
+			return nil, fmt.Errorf("%w: %s is a %s, not a %s",
+				crusoe.ErrFlagTypeMismatch, c.Flag, definition.Type, crusoe.FlagTypeBool)
+		}
+
+		if len(c.Locations) == 0 || c.Host == "" {
+			return nil, fmt.Errorf("%s resolver needs locations and a host", c.Type)
+		}
+
+		return &FlagResolver{Flags: flags, Flag: definition.Name, Locations: c.Locations, Target: target}, nil
+	case NFSTargetResolverLocation:
+		if len(c.Targets) == 0 {
+			return nil, fmt.Errorf("%s resolver needs targets", c.Type)
+		}
+
+		for location, locationTarget := range c.Targets {
+			if err := locationTarget.Validate(); err != nil {
+				return nil, fmt.Errorf("%s resolver target of %s: %w", c.Type, location, err)
+			}
+		}
+
+		return &LocationResolver{Targets: c.Targets, Required: c.Required}, nil
+	case NFSTargetResolverStatic:
+		if target.Host == "" {
+			target = fallback
+		}
+
+		return &StaticResolver{Target: target}, nil
+	default:
+		return nil, fmt.Errorf("unknown resolver type %q", c.Type)
+	}
+}
+
+// ParseNFSLocationTargets parses comma separated location=VIP range pairs,
+// e.g. "us-east1-a=100.64.0.2-100.64.0.17,eu-iceland1-a=100.64.8.2-100.64.8.17".
+// The host of each location is the first VIP of its range.
+func ParseNFSLocationTargets(value string) (map[string]NFSTarget, error) {
+	targets := make(map[string]NFSTarget)
+
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		location, remotePorts, found := strings.Cut(pair, "=")
+		location = strings.TrimSpace(location)
+		remotePorts = strings.TrimSpace(remotePorts)
+		if !found || location == "" || remotePorts == dnsRemotePorts {
+			return nil, fmt.Errorf("%w: %q is not of the form location=VIP range", ErrInvalidNFSTarget, pair)
+		}
+
+		if _, ok := targets[location]; ok {
+			return nil, fmt.Errorf("%w: location %s is set more than once", ErrInvalidNFSTarget, location)
+		}
+
+		host, _, _ := strings.Cut(remotePorts, "-")
+		target := NFSTarget{Host: host, RemotePorts: remotePorts}
+		if err := target.Validate(); err != nil {
+			return nil, fmt.Errorf("location %s: %w", location, err)
+		}
+
+		targets[location] = target
+	}
+
+	return targets, nil
+}
+
+// Validate checks that RemotePorts is dns, a single IP or a range of IPs, and that an IP Host is within it.
+func (t NFSTarget) Validate() error {
+	if t.Host == "" {
+		return fmt.Errorf("%w: host is empty", ErrInvalidNFSTarget)
+	}
+
+	if t.RemotePorts == dnsRemotePorts {
+		return nil
+	}
+
+	startValue, endValue, isRange := strings.Cut(t.RemotePorts, "-")
+	if !isRange {
+		endValue = startValue
+	}
+
+	start, err := netip.ParseAddr(startValue)
+	if err != nil {
+		return fmt.Errorf("%w: remote ports %q must be %s, an IP or an IP range: %w",
+			ErrInvalidNFSTarget, t.RemotePorts, dnsRemotePorts, err)
+	}
+
+	end, err := netip.ParseAddr(endValue)
+	if err != nil {
+		return fmt.Errorf("%w: remote ports %q must be %s, an IP or an IP range: %w",
+			ErrInvalidNFSTarget, t.RemotePorts, dnsRemotePorts, err)
+	}
+
+	if start.BitLen() != end.BitLen() || end.Less(start) {
+		return fmt.Errorf("%w: remote ports %q must start at a lower IP of the same family than they end",
+			ErrInvalidNFSTarget, t.RemotePorts)
+	}
+
+	// A host given by name is resolved by the NFS client, only hosts given by IP can be checked
+	host, err := netip.ParseAddr(t.Host)
+	if err == nil && (host.BitLen() != start.BitLen() || host.Less(start) || end.Less(host)) {
+		return fmt.Errorf("%w: host %s is not within remote ports %s", ErrInvalidNFSTarget, t.Host, t.RemotePorts)
+	}
+
+	return nil
+}
@@ -0,0 +1,334 @@
+package fs_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	crusoeapi "github.com/crusoecloud/client-go/swagger/v1alpha5"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/crusoe"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/node"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/node/fs"
+)
+
+var errDiskAPIDown = errors.New("disk API is down")
+
+// fakeDisks returns disk for every volume, or err if set.
+type fakeDisks struct {
+	disk *crusoeapi.DiskV1Alpha5
+	err  error
+}
+
+func (f *fakeDisks) GetDisk(_ context.Context, _, _ string) (*crusoeapi.DiskV1Alpha5, error) {
+	return f.disk, f.err
+}
+
+var testFallbackTarget = fs.NFSTarget{Host: "100.64.0.2", RemotePorts: "100.64.0.2-100.64.0.17"}
+
+func TestNFSTargetChain_DefaultConfig(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		disks    *fakeDisks
+		flags    *fakeFlags
+		location string
+		want     fs.NFSTarget
+	}{
+		{
+			name:     "disk VIPs",
+			disks:    &fakeDisks{disk: &crusoeapi.DiskV1Alpha5{Vips: []string{"10.0.0.1", "10.0.0.8"}}},
+			flags:    &fakeFlags{},
+			location: "us-east1-a",
+			want:     fs.NFSTarget{Host: "10.0.0.1", RemotePorts: "10.0.0.1-10.0.0.8", Resolver: fs.NFSTargetResolverDiskAPI},
+		},
+		{
+			name:     "secondary cluster in ICAT",
+			disks:    &fakeDisks{disk: &crusoeapi.DiskV1Alpha5{}},
+			flags:    &fakeFlags{bools: map[crusoe.ProjectFlag]bool{crusoe.ProjectFlagVastUseSecondaryCluster: true}},
+			location: "eu-iceland1-a",
+			want:     fs.NFSTarget{Host: "nfs.crusoecloudcompute.com", RemotePorts: "dns", Resolver: fs.NFSTargetResolverFlag},
+		},
+		{
+			name:     "secondary cluster outside ICAT",
+			disks:    &fakeDisks{disk: &crusoeapi.DiskV1Alpha5{}},
+			flags:    &fakeFlags{bools: map[crusoe.ProjectFlag]bool{crusoe.ProjectFlagVastUseSecondaryCluster: true}},
+			location: "us-east1-a",
+			want: fs.NFSTarget{
+				Host: testFallbackTarget.Host, RemotePorts: testFallbackTarget.RemotePorts, Resolver: fs.NFSTargetResolverStatic,
+			},
+		},
+		{
+			name:     "disk API and flag failures fall back to static",
+			disks:    &fakeDisks{err: errDiskAPIDown},
+			flags:    &fakeFlags{err: errFlagAPIDown},
+			location: "eu-iceland1-a",
+			want: fs.NFSTarget{
+				Host: testFallbackTarget.Host, RemotePorts: testFallbackTarget.RemotePorts, Resolver: fs.NFSTargetResolverStatic,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			chain, err := fs.DefaultNFSTargetConfig(nil).Build(tt.disks, tt.flags, testFallbackTarget)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got, err := chain.Resolve(context.Background(), fs.NFSTargetQuery{
+				VolumeID:  "test-volume-id",
+				ProjectID: "project",
+				Location:  tt.location,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNFSTargetChain_NoTarget(t *testing.T) {
+	t.Parallel()
+
+	chain := fs.NFSTargetChain{
+		&fs.LocationResolver{Targets: map[string]fs.NFSTarget{"us-east1-a": testFallbackTarget}},
+	}
+
+	_, err := chain.Resolve(context.Background(), fs.NFSTargetQuery{VolumeID: "test-volume-id", Location: "eu-iceland1-a"})
+	if !errors.Is(err, node.ErrNoNFSTarget) {
+		t.Errorf("expected error %v, got %v", node.ErrNoNFSTarget, err)
+	}
+}
+
+func TestLoadNFSTargetConfig(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "nfs-targets.yaml")
+	contents := `
+resolvers:
+- type: location
+  targets:
+    us-east1-a:
+      host: 100.64.1.2
+      remotePorts: 100.64.1.2-100.64.1.17
+- type: static
+  host: 100.64.0.9
+  remotePorts: 100.64.0.9
+`
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	config, err := fs.LoadNFSTargetConfig(path, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	chain, err := config.Build(&fakeDisks{}, &fakeFlags{}, testFallbackTarget)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for location, want := range map[string]fs.NFSTarget{
+		"us-east1-a": {Host: "100.64.1.2", RemotePorts: "100.64.1.2-100.64.1.17", Resolver: fs.NFSTargetResolverLocation},
+		"us-west1-a": {Host: "100.64.0.9", RemotePorts: "100.64.0.9", Resolver: fs.NFSTargetResolverStatic},
+	} {
+		got, err := chain.Resolve(context.Background(), fs.NFSTargetQuery{VolumeID: "test-volume-id", Location: location})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got != want {
+			t.Errorf("expected %+v in %s, got %+v", want, location, got)
+		}
+	}
+}
+
+func TestNFSTargetConfig_Build_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		config fs.NFSTargetConfig
+	}{
+		{
+			name: "no resolvers",
+		},
+		{
+			name:   "unknown type",
+			config: fs.NFSTargetConfig{Resolvers: []fs.NFSTargetResolverConfig{{Type: "magic"}}},
+		},
+		{
+			name: "unknown flag",
+			config: fs.NFSTargetConfig{Resolvers: []fs.NFSTargetResolverConfig{
+				{Type: fs.NFSTargetResolverFlag, Flag: "unknown", Locations: []string{"us-east1-a"}, Host: "nfs"},
+			}},
+		},
+		{
+			name: "flag without host",
+			config: fs.NFSTargetConfig{Resolvers: []fs.NFSTargetResolverConfig{
+				{Type: fs.NFSTargetResolverFlag, Flag: string(crusoe.ProjectFlagNFS), Locations: []string{"us-east1-a"}},
+			}},
+		},
+		{
+			name: "location with an invalid VIP range",
+			config: fs.NFSTargetConfig{Resolvers: []fs.NFSTargetResolverConfig{
+				{Type: fs.NFSTargetResolverLocation, Targets: map[string]fs.NFSTarget{
+					"us-east1-a": {Host: "100.64.0.2", RemotePorts: "100.64.0.17-100.64.0.2"},
+				}},
+			}},
+		},
+		{
+			name: "location without targets",
+			config: fs.NFSTargetConfig{Resolvers: []fs.NFSTargetResolverConfig{
+				{Type: fs.NFSTargetResolverLocation},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := tt.config.Build(&fakeDisks{}, &fakeFlags{}, testFallbackTarget); !errors.Is(
+				err, fs.ErrInvalidNFSTargetConfig) {
+				t.Errorf("expected error %v, got %v", fs.ErrInvalidNFSTargetConfig, err)
+			}
+		})
+	}
+}
+
+func TestNFSTargetChain_DefaultConfigWithLocationTargets(t *testing.T) {
+	t.Parallel()
+
+	locationTargets, err := fs.ParseNFSLocationTargets("us-east1-a=100.64.1.2-100.64.1.17")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	chain, err := fs.DefaultNFSTargetConfig(locationTargets).Build(
+		&fakeDisks{disk: &crusoeapi.DiskV1Alpha5{}}, &fakeFlags{}, testFallbackTarget)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := chain.Resolve(context.Background(), fs.NFSTargetQuery{VolumeID: "test-volume-id", Location: "us-east1-a"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := fs.NFSTarget{Host: "100.64.1.2", RemotePorts: "100.64.1.2-100.64.1.17", Resolver: fs.NFSTargetResolverLocation}
+	if got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	// Locations without an entry fail rather than falling back to the global flags
+	_, err = chain.Resolve(context.Background(), fs.NFSTargetQuery{VolumeID: "test-volume-id", Location: "us-west1-a"})
+	if !errors.Is(err, node.ErrNoNFSTarget) {
+		t.Errorf("expected error %v, got %v", node.ErrNoNFSTarget, err)
+	}
+}
+
+func TestParseNFSLocationTargets(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		value   string
+		want    map[string]fs.NFSTarget
+		wantErr error
+	}{
+		{
+			name:  "empty",
+			value: "",
+			want:  map[string]fs.NFSTarget{},
+		},
+		{
+			name:  "ranges and single VIPs",
+			value: "us-east1-a=100.64.0.2-100.64.0.17, eu-iceland1-a=100.64.8.2",
+			want: map[string]fs.NFSTarget{
+				"us-east1-a":    {Host: "100.64.0.2", RemotePorts: "100.64.0.2-100.64.0.17"},
+				"eu-iceland1-a": {Host: "100.64.8.2", RemotePorts: "100.64.8.2"},
+			},
+		},
+		{
+			name:    "not an IP",
+			value:   "us-east1-a=nfs.example.com",
+			wantErr: fs.ErrInvalidNFSTarget,
+		},
+		{
+			name:    "reversed range",
+			value:   "us-east1-a=100.64.0.17-100.64.0.2",
+			wantErr: fs.ErrInvalidNFSTarget,
+		},
+		{
+			name:    "mixed families",
+			value:   "us-east1-a=100.64.0.2-fd00::1",
+			wantErr: fs.ErrInvalidNFSTarget,
+		},
+		{
+			name:    "dns",
+			value:   "us-east1-a=dns",
+			wantErr: fs.ErrInvalidNFSTarget,
+		},
+		{
+			name:    "duplicate location",
+			value:   "us-east1-a=100.64.0.2,us-east1-a=100.64.0.3",
+			wantErr: fs.ErrInvalidNFSTarget,
+		},
+		{
+			name:    "missing range",
+			value:   "us-east1-a",
+			wantErr: fs.ErrInvalidNFSTarget,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := fs.ParseNFSLocationTargets(tt.value)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+
+			for location, target := range tt.want {
+				if got[location] != target {
+					t.Errorf("expected %+v in %s, got %+v", target, location, got[location])
+				}
+			}
+		})
+	}
+}
+
+func TestNFSTarget_Validate_HostOutsideRange(t *testing.T) {
+	t.Parallel()
+
+	target := fs.NFSTarget{Host: "100.64.0.1", RemotePorts: "100.64.0.2-100.64.0.17"}
+	if err := target.Validate(); !errors.Is(err, fs.ErrInvalidNFSTarget) {
+		t.Errorf("expected error %v, got %v", fs.ErrInvalidNFSTarget, err)
+	}
+}
+
+func TestLoadNFSTargetConfig_RejectsLocationTargetsWithFile(t *testing.T) {
+	t.Parallel()
+
+	_, err := fs.LoadNFSTargetConfig(filepath.Join(t.TempDir(), "nfs-targets.yaml"),
+		map[string]fs.NFSTarget{"us-east1-a": testFallbackTarget})
+	if !errors.Is(err, fs.ErrInvalidNFSTargetConfig) {
+		t.Errorf("expected error %v, got %v", fs.ErrInvalidNFSTargetConfig, err)
+	}
+}
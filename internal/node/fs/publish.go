@@ -1,7 +1,9 @@
 package fs
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/crusoecloud/crusoe-csi-driver/internal/node"
@@ -9,20 +11,23 @@ import (
 )
 
 func nodePublishVolume(
+	ctx context.Context,
 	mounter *mount.SafeFormatAndMount,
-	resizer *mount.ResizeFs,
 	mountOpts []string,
-	nfsEnabled bool,
-	nfsRemotePorts string,
-	nfsHost string,
+	timeout time.Duration,
 	request *csi.NodePublishVolumeRequest,
 ) error {
-	devicePath, err := getFSDevicePath(request, nfsEnabled, nfsHost)
-	if err != nil {
-		return fmt.Errorf("failed to get device path: %w", err)
+	if request.GetStagingTargetPath() == "" {
+		return node.ErrStagingPathEmpty
 	}
 
-	alreadyMounted, checkErr := node.VerifyMountedVolumeWithUtils(mounter, request.GetTargetPath(), devicePath)
+	if repairErr := node.RepairCorruptedMount(ctx, mounter, request.GetTargetPath(), timeout); repairErr != nil {
+		return repairErr
+	}
+
+	// The target path is a bind mount of the staging target path, so its source
+	// cannot be compared against the export. Any mount at the target path was made by us.
+	alreadyMounted, checkErr := node.IsMountPoint(ctx, mounter, request.GetTargetPath(), timeout)
 	if checkErr != nil {
 		return fmt.Errorf("failed to verify if volume is already mounted: %w", checkErr)
 	}
@@ -36,15 +41,11 @@ func nodePublishVolume(
 		return fmt.Errorf("%w: %s", node.ErrUnsupportedVolumeCapability, request.GetVolumeCapability())
 	case request.GetVolumeCapability().GetMount() != nil:
 		return (&PublishFilesystem{
-			Mounter:        mounter,
-			Resizer:        resizer,
-			Request:        request,
-			DevicePath:     devicePath,
-			NFSRemotePorts: nfsRemotePorts,
-			NFSHost:        nfsHost,
-			MountOpts:      mountOpts,
-			NFSEnabled:     nfsEnabled,
-		}).Publish()
+			Mounter:   mounter,
+			Request:   request,
+			MountOpts: mountOpts,
+			Timeout:   timeout,
+		}).Publish(ctx)
 	default:
 		return fmt.Errorf("%w: %s", node.ErrUnexpectedVolumeCapability, request.GetVolumeCapability())
 	}
@@ -1,28 +1,28 @@
 package fs
 
 import (
+	"context"
 	"fmt"
 	"os"
-
-	"github.com/crusoecloud/crusoe-csi-driver/internal/node"
-	"k8s.io/klog/v2"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/node"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/tracing"
 	"k8s.io/mount-utils"
 )
 
+// PublishFilesystem bind mounts a staged filesystem volume to a pod's target path.
+// The export is mounted once per node by NodeStageVolume.
 type PublishFilesystem struct {
-	Mounter        *mount.SafeFormatAndMount
-	Resizer        *mount.ResizeFs
-	Request        *csi.NodePublishVolumeRequest
-	DevicePath     string
-	NFSRemotePorts string
-	NFSHost        string
-	MountOpts      []string
-	NFSEnabled     bool
+	Mounter   *mount.SafeFormatAndMount
+	Request   *csi.NodePublishVolumeRequest
+	MountOpts []string
+	// Timeout bounds the bind mount, zero leaves only the RPC deadline
+	Timeout time.Duration
 }
 
-func (p *PublishFilesystem) Publish() error {
+func (p *PublishFilesystem) Publish(ctx context.Context) error {
 	// Make parent directory for target path
 	// os.MkdirAll will be a noop if the directory already exists
 	mkDirErr := os.MkdirAll(p.Request.GetTargetPath(), node.NewDirPerms)
@@ -30,26 +30,21 @@ func (p *PublishFilesystem) Publish() error {
 		return fmt.Errorf("failed to make directory for target path: %w", mkDirErr)
 	}
 
-	p.MountOpts = append(p.MountOpts, p.Request.GetVolumeCapability().GetMount().GetMountFlags()...)
-
-	mountOpts := p.MountOpts
-	var filesystem string
-
-	switch {
-	case p.NFSEnabled:
-		klog.Infof("Publishing NFS volume")
-		// Append mandatory NFS mount options
-		mountOpts = append(mountOpts, getNFSMountOpts(p.NFSRemotePorts)...)
-		filesystem = nfsFilesystem
-	default:
-		klog.Infof("Publishing VirtioFS volume")
-		filesystem = virtioFilesystem
+	if err := node.ApplyPublishVolumeMountGroup(p.Request); err != nil {
+		return err
 	}
 
-	// Mount the disk to the target path
-	err := p.Mounter.Mount(p.DevicePath, p.Request.GetTargetPath(), filesystem, mountOpts)
+	p.MountOpts = append(p.MountOpts, "bind")
+
+	_, span := tracing.Start(ctx, "BindMount",
+		tracing.DevicePathKey.String(p.Request.GetStagingTargetPath()),
+		tracing.TargetPathKey.String(p.Request.GetTargetPath()))
+	err := node.RunWithTimeout(ctx, p.Timeout, "bind mount", func() error {
+		return p.Mounter.Mount(p.Request.GetStagingTargetPath(), p.Request.GetTargetPath(), "", p.MountOpts)
+	})
+	tracing.End(span, err)
 	if err != nil {
-		return fmt.Errorf("%w at target path %s: %s", node.ErrFailedMount, p.Request.GetTargetPath(), err.Error())
+		return fmt.Errorf("%w at target path %s: %w", node.ErrFailedMount, p.Request.GetTargetPath(), err)
 	}
 
 	return nil
@@ -0,0 +1,136 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/crusoecloud/crusoe-csi-driver/internal/common"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/node"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/tracing"
+	"k8s.io/klog/v2"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"k8s.io/mount-utils"
+)
+
+func nodeStageVolume(
+	ctx context.Context,
+	mounter *mount.SafeFormatAndMount,
+	mountOpts []string,
+	nfsEnabled bool,
+	nfsRemotePorts string,
+	nfsHost string,
+	timeout time.Duration,
+	request *csi.NodeStageVolumeRequest,
+) error {
+	devicePath, err := getFSDevicePath(request.GetVolumeId(), request.GetVolumeContext(), nfsEnabled, nfsHost)
+	if err != nil {
+		return fmt.Errorf("failed to get device path: %w", err)
+	}
+
+	if repairErr := node.RepairCorruptedMount(ctx, mounter, request.GetStagingTargetPath(), timeout); repairErr != nil {
+		return repairErr
+	}
+
+	alreadyMounted, checkErr := node.VerifyMountedVolumeWithUtils(ctx, mounter, request.GetStagingTargetPath(),
+		devicePath, timeout)
+	if checkErr != nil {
+		return fmt.Errorf("failed to verify if volume is already staged: %w", checkErr)
+	}
+
+	if alreadyMounted {
+		return nil
+	}
+
+	switch {
+	case request.GetVolumeCapability().GetBlock() != nil:
+		return fmt.Errorf("%w: %s", node.ErrUnsupportedVolumeCapability, request.GetVolumeCapability())
+	case request.GetVolumeCapability().GetMount() != nil:
+		return (&StageFilesystem{
+			Mounter:        mounter,
+			Request:        request,
+			DevicePath:     devicePath,
+			NFSRemotePorts: nfsRemotePorts,
+			NFSHost:        nfsHost,
+			MountOpts:      mountOpts,
+			NFSEnabled:     nfsEnabled,
+			Timeout:        timeout,
+		}).Stage(ctx)
+	default:
+		return fmt.Errorf("%w: %s", node.ErrUnexpectedVolumeCapability, request.GetVolumeCapability())
+	}
+}
+
+type StageFilesystem struct {
+	Mounter        *mount.SafeFormatAndMount
+	Request        *csi.NodeStageVolumeRequest
+	DevicePath     string
+	NFSRemotePorts string
+	NFSHost        string
+	MountOpts      []string
+	NFSEnabled     bool
+	// Timeout bounds the mount, zero leaves only the RPC deadline
+	Timeout time.Duration
+}
+
+// Stage mounts the export once per node at the staging target path, so that every
+// pod using the volume on this node shares a single NFS connection pool.
+func (p *StageFilesystem) Stage(ctx context.Context) error {
+	stagingPath := p.Request.GetStagingTargetPath()
+
+	// os.MkdirAll will be a noop if the directory already exists
+	mkDirErr := os.MkdirAll(stagingPath, node.NewDirPerms)
+	if mkDirErr != nil {
+		return fmt.Errorf("failed to make directory for staging target path: %w", mkDirErr)
+	}
+
+	mountFlags := p.Request.GetVolumeCapability().GetMount().GetMountFlags()
+	mountOpts := append(slices.Clone(p.MountOpts), mountFlags...)
+	var filesystem string
+
+	switch {
+	case p.NFSEnabled:
+		klog.InfoS("Staging NFS volume",
+			common.LogKeyVolumeID, p.Request.GetVolumeId(),
+			common.LogKeyDevicePath, p.DevicePath)
+		volumeOpts, err := common.NFSMountOptions(p.Request.GetVolumeContext())
+		if err != nil {
+			return err
+		}
+
+		// The driver's own options, such as ro for read-only access modes, must not be overridden by mount flags
+		mountOpts = getNFSMountOpts(p.NFSRemotePorts, volumeOpts, mountFlags, p.MountOpts)
+		filesystem = nfsFilesystem
+	default:
+		klog.InfoS("Staging VirtioFS volume",
+			common.LogKeyVolumeID, p.Request.GetVolumeId(),
+			common.LogKeyDevicePath, p.DevicePath)
+		filesystem = virtioFilesystem
+	}
+
+	// Mount the disk to the staging target path
+	_, span := tracing.Start(ctx, "Mount",
+		tracing.DevicePathKey.String(p.DevicePath),
+		tracing.TargetPathKey.String(stagingPath),
+		tracing.FilesystemKey.String(filesystem))
+	err := node.RunWithTimeout(ctx, p.Timeout, "mount", func() error {
+		return p.Mounter.Mount(p.DevicePath, stagingPath, filesystem, mountOpts)
+	})
+	tracing.End(span, err)
+	if err != nil {
+		return fmt.Errorf("%w at staging target path %s: %w", node.ErrFailedMount, stagingPath, err)
+	}
+
+	// The root of the share is owned by the storage side, hand it to the pod's fsGroup
+	if !node.IsReadOnlyAccessMode(p.Request.GetVolumeCapability()) {
+		group := node.VolumeMountGroup(p.Request.GetVolumeCapability())
+		if err = node.ApplyVolumeMountGroup(stagingPath, group); err != nil {
+			return fmt.Errorf("failed to apply volume mount group at staging target path %s: %w", stagingPath, err)
+		}
+	}
+
+	return nil
+}
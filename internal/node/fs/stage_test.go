@@ -0,0 +1,600 @@
+package fs_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/common"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/node"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/node/fs"
+	"k8s.io/mount-utils"
+)
+
+var errMockMount = errors.New("mock mount error")
+
+// mockMounter is a mock implementation of mount.Interface for testing.
+type mockMounter struct {
+	mount.Interface
+	mountError error
+	mountCalls []mountCall
+	// onMount, if set, is called with the target of each successful mount
+	onMount func(target string)
+}
+
+type mountCall struct {
+	source  string
+	target  string
+	fstype  string
+	options []string
+}
+
+func (m *mockMounter) Mount(source, target, fstype string, options []string) error {
+	m.mountCalls = append(m.mountCalls, mountCall{
+		source:  source,
+		target:  target,
+		fstype:  fstype,
+		options: options,
+	})
+
+	if m.mountError == nil && m.onMount != nil {
+		m.onMount(target)
+	}
+
+	return m.mountError
+}
+
+func (m *mockMounter) MountSensitive(source, target, fstype string, options, sensitiveOptions []string) error {
+	return m.Mount(source, target, fstype, append(options, sensitiveOptions...))
+}
+
+func (m *mockMounter) Unmount(_ string) error {
+	return nil
+}
+
+func (m *mockMounter) List() ([]mount.MountPoint, error) {
+	return nil, nil
+}
+
+func (m *mockMounter) IsLikelyNotMountPoint(_ string) (bool, error) {
+	return true, nil
+}
+
+func (m *mockMounter) IsMountPoint(_ string) (bool, error) {
+	return false, nil
+}
+
+func (m *mockMounter) GetMountRefs(_ string) ([]string, error) {
+	return nil, nil
+}
+
+func TestStageFilesystem_Stage_NFSVolume(t *testing.T) {
+	t.Parallel()
+	mockMnt := &mockMounter{}
+	mounter := &mount.SafeFormatAndMount{
+		Interface: mockMnt,
+	}
+
+	stagingPath := t.TempDir()
+
+	volumeCapability := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{
+			Mount: &csi.VolumeCapability_MountVolume{
+				MountFlags: []string{"ro"},
+			},
+		},
+	}
+
+	request := &csi.NodeStageVolumeRequest{
+		VolumeId:          "test-volume-id",
+		StagingTargetPath: stagingPath,
+		VolumeCapability:  volumeCapability,
+	}
+
+	stager := &fs.StageFilesystem{
+		Mounter:        mounter,
+		Request:        request,
+		DevicePath:     "nfs.example.com:/volumes/test-volume-id",
+		NFSRemotePorts: "2049-2050",
+		MountOpts:      []string{"defaults"},
+		NFSEnabled:     true,
+	}
+
+	err := stager.Stage(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(mockMnt.mountCalls) != 1 {
+		t.Fatalf("expected 1 mount call, got %d", len(mockMnt.mountCalls))
+	}
+
+	call := mockMnt.mountCalls[0]
+	if call.source != "nfs.example.com:/volumes/test-volume-id" {
+		t.Errorf("expected source 'nfs.example.com:/volumes/test-volume-id', got '%s'", call.source)
+	}
+
+	if call.fstype != "nfs" {
+		t.Errorf("expected fstype 'nfs', got '%s'", call.fstype)
+	}
+
+	// Check that NFS mount options are present
+	expectedOptions := []string{
+		"defaults", "ro", "vers=3", "nconnect=16", "spread_reads", "spread_writes", "remoteports=2049-2050",
+	}
+	if len(call.options) != len(expectedOptions) {
+		t.Errorf("expected %d mount options, got %d: %v", len(expectedOptions), len(call.options), call.options)
+	}
+
+	// Verify all expected options are present
+	optionsMap := make(map[string]bool)
+	for _, opt := range call.options {
+		optionsMap[opt] = true
+	}
+	for _, expected := range expectedOptions {
+		if !optionsMap[expected] {
+			t.Errorf("expected mount option '%s' not found in %v", expected, call.options)
+		}
+	}
+}
+
+func TestStageFilesystem_Stage_NFSVolumeWithDNS(t *testing.T) {
+	t.Parallel()
+	mockMnt := &mockMounter{}
+	mounter := &mount.SafeFormatAndMount{
+		Interface: mockMnt,
+	}
+
+	stagingPath := t.TempDir()
+
+	volumeCapability := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{
+			Mount: &csi.VolumeCapability_MountVolume{
+				MountFlags: []string{},
+			},
+		},
+	}
+
+	request := &csi.NodeStageVolumeRequest{
+		VolumeId:          "test-volume-id",
+		StagingTargetPath: stagingPath,
+		VolumeCapability:  volumeCapability,
+	}
+
+	stager := &fs.StageFilesystem{
+		Mounter:        mounter,
+		Request:        request,
+		DevicePath:     "nfs.crusoecloudcompute.com:/volumes/test-volume-id",
+		NFSRemotePorts: "dns", // DNS for ICAT locations
+		MountOpts:      []string{},
+		NFSEnabled:     true,
+	}
+
+	err := stager.Stage(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(mockMnt.mountCalls) != 1 {
+		t.Fatalf("expected 1 mount call, got %d", len(mockMnt.mountCalls))
+	}
+
+	call := mockMnt.mountCalls[0]
+	if call.source != "nfs.crusoecloudcompute.com:/volumes/test-volume-id" {
+		t.Errorf("expected source 'nfs.crusoecloudcompute.com:/volumes/test-volume-id', got '%s'", call.source)
+	}
+
+	if call.fstype != "nfs" {
+		t.Errorf("expected fstype 'nfs', got '%s'", call.fstype)
+	}
+
+	// Check that NFS mount options include remoteports=dns
+	expectedOptions := []string{"vers=3", "nconnect=16", "spread_reads", "spread_writes", "remoteports=dns"}
+	if len(call.options) != len(expectedOptions) {
+		t.Errorf("expected %d mount options, got %d: %v", len(expectedOptions), len(call.options), call.options)
+	}
+
+	// Verify remoteports=dns is present
+	foundRemotePorts := false
+	for _, opt := range call.options {
+		if opt == "remoteports=dns" {
+			foundRemotePorts = true
+
+			break
+		}
+	}
+	if !foundRemotePorts {
+		t.Errorf("expected remoteports=dns option, got: %v", call.options)
+	}
+}
+
+func TestStageFilesystem_Stage_NFSVolumeNoRemotePorts(t *testing.T) {
+	t.Parallel()
+	mockMnt := &mockMounter{}
+	mounter := &mount.SafeFormatAndMount{
+		Interface: mockMnt,
+	}
+
+	stagingPath := t.TempDir()
+
+	volumeCapability := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{
+			Mount: &csi.VolumeCapability_MountVolume{
+				MountFlags: []string{},
+			},
+		},
+	}
+
+	request := &csi.NodeStageVolumeRequest{
+		VolumeId:          "test-volume-id",
+		StagingTargetPath: stagingPath,
+		VolumeCapability:  volumeCapability,
+	}
+
+	stager := &fs.StageFilesystem{
+		Mounter:        mounter,
+		Request:        request,
+		DevicePath:     "nfs.example.com:/volumes/test-volume-id",
+		NFSRemotePorts: "", // Empty - no remoteports option should be added
+		MountOpts:      []string{},
+		NFSEnabled:     true,
+	}
+
+	err := stager.Stage(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(mockMnt.mountCalls) != 1 {
+		t.Fatalf("expected 1 mount call, got %d", len(mockMnt.mountCalls))
+	}
+
+	call := mockMnt.mountCalls[0]
+
+	// Check that NFS mount options do NOT include remoteports when empty
+	expectedOptions := []string{"vers=3", "nconnect=16", "spread_reads", "spread_writes"}
+	if len(call.options) != len(expectedOptions) {
+		t.Errorf("expected %d mount options, got %d: %v", len(expectedOptions), len(call.options), call.options)
+	}
+
+	// Verify remoteports is not present
+	for _, opt := range call.options {
+		if len(opt) >= 11 && opt[:11] == "remoteports" {
+			t.Errorf("remoteports option should not be present when NFSRemotePorts is empty, got: %v", call.options)
+		}
+	}
+}
+
+func TestStageFilesystem_Stage_VirtioFSVolume(t *testing.T) {
+	t.Parallel()
+	mockMnt := &mockMounter{}
+	mounter := &mount.SafeFormatAndMount{
+		Interface: mockMnt,
+	}
+
+	stagingPath := t.TempDir()
+
+	volumeCapability := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{
+			Mount: &csi.VolumeCapability_MountVolume{
+				MountFlags: []string{"rw"},
+			},
+		},
+	}
+
+	request := &csi.NodeStageVolumeRequest{
+		VolumeId:          "test-volume-id",
+		StagingTargetPath: stagingPath,
+		VolumeCapability:  volumeCapability,
+	}
+
+	stager := &fs.StageFilesystem{
+		Mounter:    mounter,
+		Request:    request,
+		DevicePath: "test-disk-name",
+		MountOpts:  []string{"defaults"},
+		NFSEnabled: false, // VirtioFS
+	}
+
+	err := stager.Stage(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(mockMnt.mountCalls) != 1 {
+		t.Fatalf("expected 1 mount call, got %d", len(mockMnt.mountCalls))
+	}
+
+	call := mockMnt.mountCalls[0]
+	if call.source != "test-disk-name" {
+		t.Errorf("expected source 'test-disk-name', got '%s'", call.source)
+	}
+
+	if call.fstype != "virtiofs" {
+		t.Errorf("expected fstype 'virtiofs', got '%s'", call.fstype)
+	}
+
+	// Check that mount options do not include NFS-specific options
+	for _, opt := range call.options {
+		if opt == "vers=3" || opt == "nconnect=16" {
+			t.Errorf("VirtioFS mount should not have NFS options, got: %v", call.options)
+		}
+	}
+}
+
+func TestStageFilesystem_Stage_MountError(t *testing.T) {
+	t.Parallel()
+
+	mockMnt := &mockMounter{
+		mountError: errMockMount,
+	}
+	mounter := &mount.SafeFormatAndMount{
+		Interface: mockMnt,
+	}
+
+	stagingPath := t.TempDir()
+
+	volumeCapability := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{
+			Mount: &csi.VolumeCapability_MountVolume{
+				MountFlags: []string{},
+			},
+		},
+	}
+
+	request := &csi.NodeStageVolumeRequest{
+		VolumeId:          "test-volume-id",
+		StagingTargetPath: stagingPath,
+		VolumeCapability:  volumeCapability,
+	}
+
+	stager := &fs.StageFilesystem{
+		Mounter:    mounter,
+		Request:    request,
+		DevicePath: "test-disk",
+		MountOpts:  []string{},
+		NFSEnabled: false,
+	}
+
+	err := stager.Stage(context.Background())
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	if !errors.Is(err, node.ErrFailedMount) {
+		t.Errorf("expected error to wrap ErrFailedMount, got: %v", err)
+	}
+}
+
+func TestStageFilesystem_Stage_CustomMountOptions(t *testing.T) {
+	t.Parallel()
+	mockMnt := &mockMounter{}
+	mounter := &mount.SafeFormatAndMount{
+		Interface: mockMnt,
+	}
+
+	stagingPath := t.TempDir()
+
+	volumeCapability := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{
+			Mount: &csi.VolumeCapability_MountVolume{
+				MountFlags: []string{"noatime", "nodiratime"},
+			},
+		},
+	}
+
+	request := &csi.NodeStageVolumeRequest{
+		VolumeId:          "test-volume-id",
+		StagingTargetPath: stagingPath,
+		VolumeCapability:  volumeCapability,
+	}
+
+	stager := &fs.StageFilesystem{
+		Mounter:    mounter,
+		Request:    request,
+		DevicePath: "test-disk",
+		MountOpts:  []string{"defaults", "ro"},
+		NFSEnabled: false,
+	}
+
+	err := stager.Stage(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	call := mockMnt.mountCalls[0]
+
+	// Verify all custom mount options are present
+	expectedOpts := map[string]bool{
+		"defaults":   false,
+		"ro":         false,
+		"noatime":    false,
+		"nodiratime": false,
+	}
+
+	for _, opt := range call.options {
+		if _, exists := expectedOpts[opt]; exists {
+			expectedOpts[opt] = true
+		}
+	}
+
+	for opt, found := range expectedOpts {
+		if !found {
+			t.Errorf("expected mount option '%s' not found in %v", opt, call.options)
+		}
+	}
+}
+
+func TestStageFilesystem_Stage_AppliesVolumeMountGroup(t *testing.T) {
+	t.Parallel()
+	mockMnt := &mockMounter{}
+	mounter := &mount.SafeFormatAndMount{
+		Interface: mockMnt,
+	}
+
+	stagingPath := t.TempDir()
+	gid := os.Getgid()
+
+	volumeCapability := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{
+			Mount: &csi.VolumeCapability_MountVolume{
+				VolumeMountGroup: strconv.Itoa(gid),
+			},
+		},
+		AccessMode: &csi.VolumeCapability_AccessMode{
+			Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+		},
+	}
+
+	request := &csi.NodeStageVolumeRequest{
+		VolumeId:          "test-volume-id",
+		StagingTargetPath: stagingPath,
+		VolumeCapability:  volumeCapability,
+	}
+
+	stager := &fs.StageFilesystem{
+		Mounter:        mounter,
+		Request:        request,
+		DevicePath:     "nfs.example.com:/volumes/test-volume-id",
+		NFSRemotePorts: "2049-2050",
+		NFSEnabled:     true,
+	}
+
+	err := stager.Stage(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	fi, err := os.Stat(stagingPath)
+	if err != nil {
+		t.Fatalf("failed to stat staging path: %v", err)
+	}
+
+	if fi.Mode()&os.ModeSetgid == 0 || fi.Mode().Perm()&0o070 != 0o070 {
+		t.Errorf("expected group rwx and setgid on the share root, got %s", fi.Mode())
+	}
+
+	//nolint:forcetypeassert // Sys is always a Stat_t on linux
+	if stat := fi.Sys().(*syscall.Stat_t); int(stat.Gid) != gid {
+		t.Errorf("expected share root to be owned by group %d, got %d", gid, stat.Gid)
+	}
+}
+
+func TestStageFilesystem_Stage_NFSMountOptionPrecedence(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		volumeContext map[string]string
+		mountFlags    []string
+		mountOpts     []string
+		want          []string
+	}{
+		{
+			name: "volume parameters override defaults",
+			volumeContext: map[string]string{
+				common.ParameterNFSVersionKey:      "4.1",
+				common.ParameterNFSNconnectKey:     "8",
+				common.ParameterNFSRsizeKey:        "1048576",
+				common.ParameterNFSProtoKey:        "rdma",
+				common.ParameterNFSMountOptionsKey: "hard, noatime",
+			},
+			want: []string{
+				"vers=4.1", "nconnect=8", "remoteports=2049-2050", "rsize=1048576", "proto=rdma", "hard", "noatime",
+			},
+		},
+		{
+			name:          "mount flags override volume parameters",
+			volumeContext: map[string]string{common.ParameterNFSNconnectKey: "8"},
+			mountFlags:    []string{"nconnect=4", "nfsvers=4.1"},
+			want:          []string{"nfsvers=4.1", "nconnect=4", "remoteports=2049-2050"},
+		},
+		{
+			name:       "mount flags cannot make a read-only volume writable",
+			mountFlags: []string{"rw"},
+			mountOpts:  []string{"ro"},
+			want: []string{
+				"vers=3", "nconnect=16", "spread_reads", "spread_writes", "remoteports=2049-2050", "ro",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockMnt := &mockMounter{}
+			stager := &fs.StageFilesystem{
+				Mounter: &mount.SafeFormatAndMount{Interface: mockMnt},
+				Request: &csi.NodeStageVolumeRequest{
+					VolumeId:          "test-volume-id",
+					StagingTargetPath: t.TempDir(),
+					VolumeCapability: &csi.VolumeCapability{
+						AccessType: &csi.VolumeCapability_Mount{
+							Mount: &csi.VolumeCapability_MountVolume{MountFlags: tt.mountFlags},
+						},
+					},
+					VolumeContext: tt.volumeContext,
+				},
+				DevicePath:     "nfs.example.com:/volumes/test-volume-id",
+				NFSRemotePorts: "2049-2050",
+				MountOpts:      tt.mountOpts,
+				NFSEnabled:     true,
+			}
+
+			if err := stager.Stage(context.Background()); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+
+			if len(mockMnt.mountCalls) != 1 {
+				t.Fatalf("expected 1 mount call, got %d", len(mockMnt.mountCalls))
+			}
+
+			if got := mockMnt.mountCalls[0].options; !slices.Equal(got, tt.want) {
+				t.Errorf("expected mount options %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStageFilesystem_Stage_VolumeMountGroupFails(t *testing.T) {
+	t.Parallel()
+	// The share root disappearing after the mount stands in for a share whose group cannot be changed
+	mockMnt := &mockMounter{onMount: func(target string) { _ = os.Remove(target) }}
+	mounter := &mount.SafeFormatAndMount{
+		Interface: mockMnt,
+	}
+
+	request := &csi.NodeStageVolumeRequest{
+		VolumeId:          "test-volume-id",
+		StagingTargetPath: t.TempDir(),
+		VolumeCapability: &csi.VolumeCapability{
+			AccessType: &csi.VolumeCapability_Mount{
+				Mount: &csi.VolumeCapability_MountVolume{
+					VolumeMountGroup: strconv.Itoa(os.Getgid()),
+				},
+			},
+			AccessMode: &csi.VolumeCapability_AccessMode{
+				Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+			},
+		},
+	}
+
+	stager := &fs.StageFilesystem{
+		Mounter:        mounter,
+		Request:        request,
+		DevicePath:     "nfs.example.com:/volumes/test-volume-id",
+		NFSRemotePorts: "2049-2050",
+		NFSEnabled:     true,
+	}
+
+	err := stager.Stage(context.Background())
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the volume mount group failure to fail the stage, got: %v", err)
+	}
+}
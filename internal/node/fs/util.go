@@ -2,9 +2,9 @@ package fs
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 
-	"github.com/container-storage-interface/spec/lib/go/csi"
 	crusoeapi "github.com/crusoecloud/client-go/swagger/v1alpha5"
 	"github.com/crusoecloud/crusoe-csi-driver/internal/common"
 	"github.com/crusoecloud/crusoe-csi-driver/internal/node"
@@ -16,26 +16,87 @@ const (
 	virtioFilesystem = "virtiofs"
 )
 
-func getNFSMountOpts(nfsRemotePorts string) []string {
-	opts := []string{
-		"vers=3",
+// nfsMountOptionKeys maps options which set the same thing to one key, so that only the last of them is kept.
+//
+//nolint:gochecknoglobals // can't construct const map
+var nfsMountOptionKeys = map[string]string{
+	"nfsvers": "vers",
+	"rw":      "ro",
+	"soft":    "hard",
+	"noac":    "ac",
+}
+
+// getNFSMountOpts returns the options of an NFS mount. The driver's defaults are overridden by the volume's
+// parameters, which are overridden by userOpts, e.g. the PV's mountOptions, which are in turn overridden by
+// requiredOpts. Each option appears once, at the position of its first occurrence.
+func getNFSMountOpts(nfsRemotePorts string, volumeOpts, userOpts, requiredOpts []string) []string {
+	version := common.DefaultNFSVersion
+	for _, opt := range slices.Concat(volumeOpts, userOpts, requiredOpts) {
+		if name, value, _ := strings.Cut(opt, "="); nfsMountOptionKey(name) == "vers" {
+			version = value
+		}
+	}
+
+	defaults := []string{
+		"vers=" + version,
 		"nconnect=16",
-		"spread_reads",
-		"spread_writes",
+	}
+
+	// The VAST client only spreads reads and writes across connections with NFSv3
+	if version == common.NFSVersion3 {
+		defaults = append(defaults, "spread_reads", "spread_writes")
 	}
 
 	// Only add remoteports if specified
 	if nfsRemotePorts != "" {
-		opts = append(opts, fmt.Sprintf("remoteports=%s", nfsRemotePorts))
+		defaults = append(defaults, fmt.Sprintf("remoteports=%s", nfsRemotePorts))
+	}
+
+	return mergeMountOpts(defaults, volumeOpts, userOpts, requiredOpts)
+}
+
+func nfsMountOptionKey(name string) string {
+	if key, ok := nfsMountOptionKeys[name]; ok {
+		return key
+	}
+
+	return name
+}
+
+// mergeMountOpts merges layers of mount options, later layers override options with the same key in earlier ones.
+func mergeMountOpts(layers ...[]string) []string {
+	var keys []string
+	merged := make(map[string]string)
+
+	for _, layer := range layers {
+		for _, opt := range layer {
+			name, _, _ := strings.Cut(opt, "=")
+			key := nfsMountOptionKey(name)
+
+			if _, ok := merged[key]; !ok {
+				keys = append(keys, key)
+			}
+			merged[key] = opt
+		}
+	}
+
+	opts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		opts = append(opts, merged[key])
 	}
 
 	return opts
 }
 
+// nfsExportPath returns the path of a volume's export on the NFS server.
+func nfsExportPath(volumeID string) string {
+	return fmt.Sprintf("/volumes/%s", volumeID)
+}
+
 func supportsFS(instance *crusoeapi.InstanceV1Alpha5) bool {
 	typeSegments := strings.Split(instance.Type_, ".")
 	if len(typeSegments) != node.ExpectedTypeSegments {
-		klog.Infof("Unexpected instance type: %s", instance.Type_)
+		klog.InfoS("Unexpected instance type", "instanceType", instance.Type_)
 
 		return false
 	}
@@ -70,12 +131,11 @@ func supportsFS(instance *crusoeapi.InstanceV1Alpha5) bool {
 	return false
 }
 
-func getFSDevicePath(request *csi.NodePublishVolumeRequest, supportsNfs bool, nfsIP string) (string, error) {
+func getFSDevicePath(volumeID string, volumeContext map[string]string, supportsNfs bool, nfsIP string) (string, error) {
 	switch {
 	case supportsNfs:
-		return fmt.Sprintf("%s:/volumes/%s", nfsIP, request.GetVolumeId()), nil
+		return fmt.Sprintf("%s:%s", nfsIP, nfsExportPath(volumeID)), nil
 	default:
-		volumeContext := request.GetVolumeContext()
 		devicePath, ok := volumeContext[common.VolumeContextDiskNameKey]
 		if !ok {
 			return "", node.ErrVolumeMissingName
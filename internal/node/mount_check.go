@@ -1,9 +1,11 @@
 package node
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"k8s.io/klog/v2"
 
@@ -70,7 +72,10 @@ func verifyMountedVolumeWithUtilsHelper(mounter *mount.SafeFormatAndMount, targe
 		return fmt.Errorf("failed to get device name from mount: %w", err)
 	}
 
-	klog.Infof("actualDeviceFullPath: %s, deviceFullPath: %s", actualDeviceFullPath, deviceFullPath)
+	klog.V(4).InfoS("Found device mounted at target path",
+		"targetPath", targetPath,
+		"actualDevicePath", actualDeviceFullPath,
+		"expectedDevicePath", deviceFullPath)
 
 	if actualDeviceFullPath != deviceFullPath {
 		return fmt.Errorf("%w: expected %s, got %s", errDeviceMismatch, deviceFullPath, actualDeviceFullPath)
@@ -80,9 +85,14 @@ func verifyMountedVolumeWithUtilsHelper(mounter *mount.SafeFormatAndMount, targe
 }
 
 // VerifyMountedVolumeWithUtils checks if the desired volume is mounted at the target path.
-func VerifyMountedVolumeWithUtils(mounter *mount.SafeFormatAndMount, targetPath, deviceFullPath string) (bool, error) {
+// The check and the unmount of another device are bounded by timeout, as the target path may be a hung NFS mount.
+func VerifyMountedVolumeWithUtils(ctx context.Context, mounter *mount.SafeFormatAndMount,
+	targetPath, deviceFullPath string, timeout time.Duration,
+) (bool, error) {
 	// Idempotency check: exit early if the disk is already mounted to the target path
-	verifyErr := verifyMountedVolumeWithUtilsHelper(mounter, targetPath, deviceFullPath)
+	verifyErr := RunWithTimeout(ctx, timeout, "mount check", func() error {
+		return verifyMountedVolumeWithUtilsHelper(mounter, targetPath, deviceFullPath)
+	})
 
 	switch {
 	// Disk is already mounted to the target path, exit early
@@ -99,7 +109,9 @@ func VerifyMountedVolumeWithUtils(mounter *mount.SafeFormatAndMount, targetPath,
 
 	// Another disk is mounted at the target path, unmount the existing disk and continue mounting the disk
 	case errors.Is(verifyErr, errDeviceMismatch):
-		unmountErr := mounter.Unmount(targetPath)
+		unmountErr := RunWithTimeout(ctx, timeout, "unmount", func() error {
+			return mounter.Unmount(targetPath)
+		})
 		if unmountErr != nil {
 			return false, fmt.Errorf("%w at %s: %w", errMountCleanupFailed, targetPath, unmountErr)
 		}
@@ -111,3 +123,53 @@ func VerifyMountedVolumeWithUtils(mounter *mount.SafeFormatAndMount, targetPath,
 		return false, verifyErr
 	}
 }
+
+// IsMountPoint reports whether anything is mounted at path within timeout.
+// A path which does not exist is not a mount point.
+func IsMountPoint(ctx context.Context, mounter *mount.SafeFormatAndMount, path string, timeout time.Duration) (
+	bool,
+	error,
+) {
+	return runWithTimeoutResult(ctx, timeout, "mount point check", func() (bool, error) {
+		return checkMountPoint(mounter, path)
+	})
+}
+
+func checkMountPoint(mounter *mount.SafeFormatAndMount, path string) (bool, error) {
+	_, statErr := os.Stat(path)
+	if os.IsNotExist(statErr) {
+		return false, nil
+	} else if statErr != nil {
+		return false, fmt.Errorf("failed to check if %s exists: %w", path, statErr)
+	}
+
+	return isMountPointQuick(mounter, path)
+}
+
+// StagingPathRefs returns the other mount points sharing the mount at stagingPath within timeout,
+// i.e. the target paths the staged volume is still published to.
+func StagingPathRefs(ctx context.Context, mounter *mount.SafeFormatAndMount, stagingPath string,
+	timeout time.Duration,
+) ([]string, error) {
+	return runWithTimeoutResult(ctx, timeout, "mount reference check", func() ([]string, error) {
+		return stagingPathRefs(mounter, stagingPath)
+	})
+}
+
+func stagingPathRefs(mounter *mount.SafeFormatAndMount, stagingPath string) ([]string, error) {
+	isMountPoint, err := checkMountPoint(mounter, stagingPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isMountPoint {
+		return nil, nil
+	}
+
+	refs, err := mounter.GetMountRefs(stagingPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mount references of %s: %w", stagingPath, err)
+	}
+
+	return refs, nil
+}
@@ -0,0 +1,75 @@
+package node_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/crusoecloud/crusoe-csi-driver/internal/node"
+	"k8s.io/mount-utils"
+)
+
+func TestStagingPathRefs(t *testing.T) {
+	t.Parallel()
+
+	stagingPath := t.TempDir()
+	targetPath := t.TempDir()
+
+	fakeMounter := mount.NewFakeMounter(nil)
+	mounter := &mount.SafeFormatAndMount{Interface: fakeMounter}
+
+	refs, err := node.StagingPathRefs(context.Background(), mounter, stagingPath, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(refs) != 0 {
+		t.Errorf("expected no references to an unmounted staging path, got %v", refs)
+	}
+
+	if err = mounter.Mount("nfs.example.com:/volumes/vol-1", stagingPath, "nfs", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err = mounter.Mount(stagingPath, targetPath, "", []string{"bind"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	refs, err = node.StagingPathRefs(context.Background(), mounter, stagingPath, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !slices.Contains(refs, targetPath) {
+		t.Errorf("expected %s to reference the staging path, got %v", targetPath, refs)
+	}
+
+	if err = mounter.Unmount(targetPath); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	refs, err = node.StagingPathRefs(context.Background(), mounter, stagingPath, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(refs) != 0 {
+		t.Errorf("expected no references once unpublished, got %v", refs)
+	}
+}
+
+func TestIsMountPoint_MissingPath(t *testing.T) {
+	t.Parallel()
+
+	mounter := &mount.SafeFormatAndMount{Interface: mount.NewFakeMounter(nil)}
+
+	isMountPoint, err := node.IsMountPoint(context.Background(), mounter, "/nonexistent/path/for/test", time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if isMountPoint {
+		t.Error("expected missing path not to be a mount point")
+	}
+}
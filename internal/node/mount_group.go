@@ -0,0 +1,78 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+)
+
+// volumeMountGroupPerms grants the group full access to the volume root. setgid makes files
+// created in it inherit the group, matching what kubelet applies for fsGroup.
+const volumeMountGroupPerms = os.ModeSetgid | 0o070
+
+var ErrInvalidVolumeMountGroup = errors.New("volume mount group must be a numeric group ID")
+
+// VolumeMountGroup returns the volume mount group of a mount volume capability, if any.
+// kubelet only sets it when the node advertises VOLUME_MOUNT_GROUP, in place of applying fsGroup itself.
+func VolumeMountGroup(capability *csi.VolumeCapability) string {
+	return capability.GetMount().GetVolumeMountGroup()
+}
+
+// parseVolumeMountGroup returns the GID of group.
+func parseVolumeMountGroup(group string) (int, error) {
+	gid, err := strconv.ParseUint(group, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidVolumeMountGroup, group)
+	}
+
+	return int(gid), nil
+}
+
+// ApplyVolumeMountGroup gives group ownership of the root of the volume mounted at path, so pods
+// running with it as their fsGroup can write to the volume without kubelet recursively changing
+// ownership of every file. Files already on the volume keep their ownership. An empty group is a noop.
+func ApplyVolumeMountGroup(path, group string) error {
+	if group == "" {
+		return nil
+	}
+
+	gid, err := parseVolumeMountGroup(group)
+	if err != nil {
+		return err
+	}
+
+	if err = os.Lchown(path, -1, gid); err != nil {
+		return fmt.Errorf("failed to change group of %s to %d: %w", path, gid, err)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+
+	if err = os.Chmod(path, fi.Mode()|volumeMountGroupPerms); err != nil {
+		return fmt.Errorf("failed to change permissions of %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// ApplyPublishVolumeMountGroup applies the volume mount group of request to its staging target path, which
+// the target path is bind mounted from. A volume is staged once per node, so pods publishing it later with
+// a different fsGroup only get access by applying their group here. Read-only volumes and publishes are left
+// unchanged.
+func ApplyPublishVolumeMountGroup(request *csi.NodePublishVolumeRequest) error {
+	if request.GetReadonly() || IsReadOnlyAccessMode(request.GetVolumeCapability()) {
+		return nil
+	}
+
+	stagingPath := request.GetStagingTargetPath()
+	if err := ApplyVolumeMountGroup(stagingPath, VolumeMountGroup(request.GetVolumeCapability())); err != nil {
+		return fmt.Errorf("failed to apply volume mount group at staging target path %s: %w", stagingPath, err)
+	}
+
+	return nil
+}
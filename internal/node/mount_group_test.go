@@ -0,0 +1,109 @@
+package node_test
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/node"
+)
+
+func TestValidateStageRequest_VolumeMountGroup(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		group   string
+		wantErr bool
+	}{
+		{name: "unset", group: ""},
+		{name: "numeric", group: "1000"},
+		{name: "group name", group: "users", wantErr: true},
+		{name: "negative", group: "-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := node.ValidateStageRequest(&csi.NodeStageVolumeRequest{
+				VolumeId:          testVolumeID,
+				StagingTargetPath: "/staging",
+				VolumeCapability: &csi.VolumeCapability{
+					AccessType: &csi.VolumeCapability_Mount{
+						Mount: &csi.VolumeCapability_MountVolume{VolumeMountGroup: tt.group},
+					},
+				},
+			})
+
+			if tt.wantErr != errors.Is(err, node.ErrInvalidVolumeMountGroup) {
+				t.Errorf("unexpected error for volume mount group %q: %v", tt.group, err)
+			}
+		})
+	}
+}
+
+func TestApplyPublishVolumeMountGroup(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		mode      csi.VolumeCapability_AccessMode_Mode
+		readonly  bool
+		wantGroup bool
+	}{
+		{name: "read-write publish", mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER, wantGroup: true},
+		{name: "read-only publish", mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER, readonly: true},
+		{name: "read-only access mode", mode: csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			stagingPath := t.TempDir()
+			err := node.ApplyPublishVolumeMountGroup(&csi.NodePublishVolumeRequest{
+				VolumeId:          testVolumeID,
+				StagingTargetPath: stagingPath,
+				Readonly:          tt.readonly,
+				VolumeCapability: &csi.VolumeCapability{
+					AccessType: &csi.VolumeCapability_Mount{
+						Mount: &csi.VolumeCapability_MountVolume{VolumeMountGroup: strconv.Itoa(os.Getgid())},
+					},
+					AccessMode: &csi.VolumeCapability_AccessMode{Mode: tt.mode},
+				},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			fi, err := os.Stat(stagingPath)
+			if err != nil {
+				t.Fatalf("failed to stat staging path: %v", err)
+			}
+
+			if applied := fi.Mode()&os.ModeSetgid != 0; applied != tt.wantGroup {
+				t.Errorf("expected volume mount group applied to be %t, got mode %s", tt.wantGroup, fi.Mode())
+			}
+		})
+	}
+}
+
+func TestApplyPublishVolumeMountGroup_StagingPathMissing(t *testing.T) {
+	t.Parallel()
+
+	err := node.ApplyPublishVolumeMountGroup(&csi.NodePublishVolumeRequest{
+		VolumeId:          testVolumeID,
+		StagingTargetPath: t.TempDir() + "/missing",
+		VolumeCapability: &csi.VolumeCapability{
+			AccessType: &csi.VolumeCapability_Mount{
+				Mount: &csi.VolumeCapability_MountVolume{VolumeMountGroup: strconv.Itoa(os.Getgid())},
+			},
+		},
+	})
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the missing staging path to fail the publish, got %v", err)
+	}
+}
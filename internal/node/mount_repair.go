@@ -0,0 +1,85 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/crusoecloud/crusoe-csi-driver/internal/common"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/metrics"
+	"k8s.io/klog/v2"
+	"k8s.io/mount-utils"
+)
+
+// forceUnmountTimeout bounds a regular unmount of a corrupted mount point before retrying with -f.
+const forceUnmountTimeout = 10 * time.Second
+
+var errMountRepairFailed = errors.New("failed to unmount corrupted mount point")
+
+// RepairCorruptedMount unmounts path if its mount is corrupted, e.g. an NFS mount left with a stale
+// file handle after a server failover, so it can be mounted again. Healthy, empty or missing paths are left as is.
+// A mount which cannot be unmounted normally is force unmounted, then lazily detached as a last resort.
+// Checking path is bounded by timeout, a stat which hangs on an unreachable server returns ErrOperationTimeout.
+func RepairCorruptedMount(ctx context.Context, mounter *mount.SafeFormatAndMount, path string,
+	timeout time.Duration,
+) error {
+	return repairCorruptedMount(ctx, mounter, path, timeout, os.Stat)
+}
+
+// repairCorruptedMount is RepairCorruptedMount with the stat of path replaceable in tests.
+func repairCorruptedMount(ctx context.Context, mounter *mount.SafeFormatAndMount, path string,
+	timeout time.Duration, stat func(string) (os.FileInfo, error),
+) error {
+	statErr := RunWithTimeout(ctx, timeout, "stat", func() error {
+		_, err := stat(path)
+
+		//nolint:wrapcheck // inspected with mount.IsCorruptedMnt below
+		return err
+	})
+	if errors.Is(statErr, ErrOperationTimeout) {
+		return statErr
+	}
+
+	if !mount.IsCorruptedMnt(statErr) {
+		return nil
+	}
+
+	klog.ErrorS(statErr, "Found corrupted mount point, unmounting before remounting", common.LogKeyTargetPath, path)
+
+	if err := unmountCorrupted(ctx, mounter, path); err != nil {
+		klog.ErrorS(err, "Failed to repair corrupted mount point", common.LogKeyTargetPath, path)
+		metrics.RecordMountRepair(ctx, metrics.OutcomeFailed)
+
+		return fmt.Errorf("%w at %s: %w", errMountRepairFailed, path, err)
+	}
+
+	klog.InfoS("Unmounted corrupted mount point", common.LogKeyTargetPath, path)
+	metrics.RecordMountRepair(ctx, metrics.OutcomeRepaired)
+
+	return nil
+}
+
+func unmountCorrupted(ctx context.Context, mounter *mount.SafeFormatAndMount, path string) error {
+	var err error
+	if forceUnmounter, ok := mounter.Interface.(mount.MounterForceUnmounter); ok {
+		err = forceUnmounter.UnmountWithForce(path, forceUnmountTimeout)
+	} else {
+		err = RunWithTimeout(ctx, forceUnmountTimeout, "unmount", func() error {
+			return mounter.Unmount(path)
+		})
+	}
+
+	if err == nil {
+		return nil
+	}
+
+	klog.ErrorS(err, "Failed to unmount corrupted mount point, detaching lazily", common.LogKeyTargetPath, path)
+
+	if detachErr := detachMount(path); detachErr != nil {
+		return fmt.Errorf("%w, lazy unmount: %w", err, detachErr)
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package node_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/crusoecloud/crusoe-csi-driver/internal/metrics"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/node"
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"k8s.io/mount-utils"
+)
+
+func TestRepairCorruptedMount_HealthyMount(t *testing.T) {
+	t.Parallel()
+
+	targetPath := t.TempDir()
+
+	fakeMounter := mount.NewFakeMounter(nil)
+	mounter := &mount.SafeFormatAndMount{Interface: fakeMounter}
+
+	if err := node.RepairCorruptedMount(context.Background(), mounter, "/nonexistent/path/for/test", time.Second); err != nil {
+		t.Fatalf("unexpected error for missing path: %v", err)
+	}
+
+	if err := mounter.Mount("/dev/vdb", targetPath, "ext4", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := node.RepairCorruptedMount(context.Background(), mounter, targetPath, time.Second); err != nil {
+		t.Fatalf("unexpected error for healthy mount: %v", err)
+	}
+
+	isMountPoint, err := node.IsMountPoint(context.Background(), mounter, targetPath, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !isMountPoint {
+		t.Error("expected healthy mount to be left mounted")
+	}
+}
+
+// staleStat fails like a stat of an NFS mount left with a stale file handle.
+func staleStat(path string) (os.FileInfo, error) {
+	return nil, &os.PathError{Op: "stat", Path: path, Err: syscall.ESTALE}
+}
+
+// installReader installs a global meter provider whose measurements are collected by the returned reader.
+func installReader(t *testing.T) *sdkmetric.ManualReader {
+	t.Helper()
+
+	reader := sdkmetric.NewManualReader()
+	previous := otel.GetMeterProvider()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	t.Cleanup(func() { otel.SetMeterProvider(previous) })
+
+	return reader
+}
+
+// repairOutcomes returns the number of recorded mount repairs by outcome.
+func repairOutcomes(t *testing.T, reader *sdkmetric.ManualReader) map[string]int64 {
+	t.Helper()
+
+	var resourceMetrics metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &resourceMetrics); err != nil {
+		t.Fatalf("failed to collect metrics: %v", err)
+	}
+
+	outcomes := make(map[string]int64)
+	for _, scopeMetrics := range resourceMetrics.ScopeMetrics {
+		for _, m := range scopeMetrics.Metrics {
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if m.Name != "csi.node.mount_repairs" || !ok {
+				continue
+			}
+
+			for _, point := range sum.DataPoints {
+				outcome, _ := point.Attributes.Value(metrics.OutcomeKey)
+				outcomes[outcome.AsString()] += point.Value
+			}
+		}
+	}
+
+	return outcomes
+}
+
+//nolint:paralleltest // replaces the global meter provider
+func TestRepairCorruptedMount_CorruptedMount(t *testing.T) {
+	reader := installReader(t)
+	targetPath := t.TempDir()
+
+	fakeMounter := mount.NewFakeMounter(nil)
+	mounter := &mount.SafeFormatAndMount{Interface: fakeMounter}
+	if err := mounter.Mount("100.64.0.2:/volumes/vol-1", targetPath, "nfs", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err := node.RepairCorruptedMountWithStat(context.Background(), mounter, targetPath, time.Second, staleStat)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if mountPoints, _ := fakeMounter.List(); len(mountPoints) != 0 {
+		t.Errorf("expected the corrupted mount to be unmounted, got %v", mountPoints)
+	}
+
+	if outcomes := repairOutcomes(t, reader); outcomes[metrics.OutcomeRepaired] != 1 {
+		t.Errorf("expected the repair to be recorded, got %v", outcomes)
+	}
+}
+
+//nolint:paralleltest // replaces the global meter provider
+func TestRepairCorruptedMount_UnmountFails(t *testing.T) {
+	reader := installReader(t)
+	errUnmount := errors.New("device is busy")
+
+	// The lazy unmount of a path which does not exist fails as well
+	targetPath := filepath.Join(t.TempDir(), "missing")
+
+	fakeMounter := mount.NewFakeMounter(nil)
+	fakeMounter.UnmountFunc = func(string) error { return errUnmount }
+	mounter := &mount.SafeFormatAndMount{Interface: fakeMounter}
+	if err := mounter.Mount("100.64.0.2:/volumes/vol-1", targetPath, "nfs", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err := node.RepairCorruptedMountWithStat(context.Background(), mounter, targetPath, time.Second, staleStat)
+	if !errors.Is(err, errUnmount) {
+		t.Fatalf("expected error %v, got %v", errUnmount, err)
+	}
+
+	if outcomes := repairOutcomes(t, reader); outcomes[metrics.OutcomeFailed] != 1 {
+		t.Errorf("expected the failed repair to be recorded, got %v", outcomes)
+	}
+}
@@ -0,0 +1,168 @@
+package ssd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/crusoecloud/crusoe-csi-driver/internal/common"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// virtio-blk truncates serial numbers to this many characters.
+	virtioSerialMaxLen = 20
+	virtioByIDPrefix   = "virtio-"
+
+	DefaultDiskByIDPath  = "/dev/disk/by-id"
+	DefaultSysBlockPath  = "/sys/block"
+	DefaultDevPath       = "/dev"
+	DefaultDeviceTimeout = 30 * time.Second
+
+	defaultDevicePollInterval = 500 * time.Millisecond
+)
+
+var (
+	ErrDeviceNotFound  = errors.New("no attached device matches the disk serial number")
+	ErrDeviceAmbiguous = errors.New("multiple attached devices match the disk serial number")
+)
+
+// DeviceResolver finds the block device of an attached disk from its serial number.
+// udev's /dev/disk/by-id links are preferred, falling back to the serial numbers in sysfs
+// for node images without the udev rules. Zero values use the defaults.
+type DeviceResolver struct {
+	DiskByIDPath string
+	SysBlockPath string
+	DevPath      string
+	// Timeout bounds how long Resolve waits for a device to appear after attach.
+	Timeout      time.Duration
+	PollInterval time.Duration
+}
+
+// truncateSerial returns serialNumber as the kernel reports it for virtio-blk devices.
+func truncateSerial(serialNumber string) string {
+	if len(serialNumber) > virtioSerialMaxLen {
+		return serialNumber[:virtioSerialMaxLen]
+	}
+
+	return serialNumber
+}
+
+// Resolve waits until the device with serialNumber appears and returns its path.
+// Ambiguous matches fail immediately since waiting will not resolve them.
+func (r *DeviceResolver) Resolve(ctx context.Context, serialNumber string) (string, error) {
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = DefaultDeviceTimeout
+	}
+
+	pollInterval := r.PollInterval
+	if pollInterval == 0 {
+		pollInterval = defaultDevicePollInterval
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		devicePath, err := r.Find(serialNumber)
+		if err == nil || !errors.Is(err, ErrDeviceNotFound) {
+			return devicePath, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("%w after waiting %s: %s", ErrDeviceNotFound, timeout, serialNumber)
+		case <-ticker.C:
+		}
+	}
+}
+
+// Find returns the path of the device with serialNumber without waiting.
+func (r *DeviceResolver) Find(serialNumber string) (string, error) {
+	if devicePath, ok := r.findByID(serialNumber); ok {
+		return devicePath, nil
+	}
+
+	devicePath, err := r.findBySysfsSerial(serialNumber)
+	if err != nil {
+		return "", err
+	}
+
+	klog.V(4).InfoS("Resolved device from sysfs serial number, /dev/disk/by-id link is missing",
+		"serialNumber", serialNumber,
+		common.LogKeyDevicePath, devicePath)
+
+	return devicePath, nil
+}
+
+// findByID looks for the udev link of the full or truncated serial number.
+func (r *DeviceResolver) findByID(serialNumber string) (string, bool) {
+	diskByIDPath := r.DiskByIDPath
+	if diskByIDPath == "" {
+		diskByIDPath = DefaultDiskByIDPath
+	}
+
+	for _, serial := range []string{serialNumber, truncateSerial(serialNumber)} {
+		devicePath, err := filepath.EvalSymlinks(filepath.Join(diskByIDPath, virtioByIDPrefix+serial))
+		if err == nil {
+			return devicePath, true
+		}
+	}
+
+	return "", false
+}
+
+func (r *DeviceResolver) sysBlockPath() string {
+	if r.SysBlockPath == "" {
+		return DefaultSysBlockPath
+	}
+
+	return r.SysBlockPath
+}
+
+// findBySysfsSerial scans the serial numbers of all block devices for serialNumber.
+func (r *DeviceResolver) findBySysfsSerial(serialNumber string) (string, error) {
+	sysBlockPath := r.sysBlockPath()
+
+	devPath := r.DevPath
+	if devPath == "" {
+		devPath = DefaultDevPath
+	}
+
+	entries, err := os.ReadDir(sysBlockPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to list %s: %w", sysBlockPath, err)
+	}
+
+	want := truncateSerial(serialNumber)
+	var matches []string
+
+	for _, entry := range entries {
+		contents, readErr := os.ReadFile(filepath.Join(sysBlockPath, entry.Name(), "serial"))
+		if readErr != nil {
+			// Devices without a serial number, e.g. loop devices
+			continue
+		}
+
+		if truncateSerial(strings.TrimSpace(string(contents))) == want {
+			matches = append(matches, filepath.Join(devPath, entry.Name()))
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("%w: %s", ErrDeviceNotFound, serialNumber)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("%w: %s matches %s", ErrDeviceAmbiguous, serialNumber, strings.Join(matches, ", "))
+	}
+}
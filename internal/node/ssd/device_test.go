@@ -0,0 +1,244 @@
+package ssd_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/crusoecloud/crusoe-csi-driver/internal/node/ssd"
+)
+
+const testSerial = "1234567890abcdefghijklmnop"
+
+type deviceFixture struct {
+	resolver ssd.DeviceResolver
+}
+
+func newDeviceFixture(t *testing.T) *deviceFixture {
+	t.Helper()
+
+	root := t.TempDir()
+	f := &deviceFixture{
+		resolver: ssd.DeviceResolver{
+			DiskByIDPath: filepath.Join(root, "by-id"),
+			SysBlockPath: filepath.Join(root, "sys", "block"),
+			DevPath:      filepath.Join(root, "dev"),
+			Timeout:      200 * time.Millisecond,
+			PollInterval: 10 * time.Millisecond,
+		},
+	}
+
+	for _, dir := range []string{f.resolver.DiskByIDPath, f.resolver.SysBlockPath, f.resolver.DevPath} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("failed to create %s: %s", dir, err)
+		}
+	}
+
+	return f
+}
+
+func (f *deviceFixture) addDevice(t *testing.T, name, serial string) string {
+	t.Helper()
+
+	devicePath := filepath.Join(f.resolver.DevPath, name)
+	if err := os.WriteFile(devicePath, nil, 0o600); err != nil {
+		t.Fatalf("failed to create device: %s", err)
+	}
+
+	sysfsPath := filepath.Join(f.resolver.SysBlockPath, name)
+	if err := os.MkdirAll(sysfsPath, 0o755); err != nil {
+		t.Fatalf("failed to create sysfs dir: %s", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(sysfsPath, "serial"), []byte(serial+"\n"), 0o600); err != nil {
+		t.Fatalf("failed to write serial: %s", err)
+	}
+
+	return devicePath
+}
+
+// setSize writes the size of device name in sectors, as sysfs reports it.
+func (f *deviceFixture) setSize(t *testing.T, name string, sizeBytes int64) {
+	t.Helper()
+
+	sizePath := filepath.Join(f.resolver.SysBlockPath, name, "size")
+	if err := os.WriteFile(sizePath, []byte(strconv.FormatInt(sizeBytes/512, 10)+"\n"), 0o600); err != nil {
+		t.Fatalf("failed to write size: %s", err)
+	}
+}
+
+// addRescanTrigger gives device name the rescan trigger of a SCSI device and returns its path.
+func (f *deviceFixture) addRescanTrigger(t *testing.T, name string) string {
+	t.Helper()
+
+	rescanPath := filepath.Join(f.resolver.SysBlockPath, name, "device", "rescan")
+	if err := os.MkdirAll(filepath.Dir(rescanPath), 0o755); err != nil {
+		t.Fatalf("failed to create sysfs dir: %s", err)
+	}
+
+	if err := os.WriteFile(rescanPath, nil, 0o600); err != nil {
+		t.Fatalf("failed to create rescan trigger: %s", err)
+	}
+
+	return rescanPath
+}
+
+func TestDeviceResolver_ByIDLink(t *testing.T) {
+	t.Parallel()
+
+	f := newDeviceFixture(t)
+	devicePath := f.addDevice(t, "vdb", "unrelated")
+
+	if err := os.Symlink(devicePath, filepath.Join(f.resolver.DiskByIDPath, "virtio-"+testSerial[:20])); err != nil {
+		t.Fatalf("failed to create link: %s", err)
+	}
+
+	got, err := f.resolver.Resolve(context.Background(), testSerial)
+	if err != nil {
+		t.Fatalf("expected device to resolve, got %s", err)
+	}
+
+	if got != devicePath {
+		t.Errorf("expected %s, got %s", devicePath, got)
+	}
+}
+
+func TestDeviceResolver_SysfsFallback(t *testing.T) {
+	t.Parallel()
+
+	f := newDeviceFixture(t)
+	f.addDevice(t, "vda", "boot-disk")
+	devicePath := f.addDevice(t, "vdb", testSerial[:20])
+
+	got, err := f.resolver.Resolve(context.Background(), testSerial)
+	if err != nil {
+		t.Fatalf("expected device to resolve, got %s", err)
+	}
+
+	if got != devicePath {
+		t.Errorf("expected %s, got %s", devicePath, got)
+	}
+}
+
+func TestDeviceResolver_WaitsForDevice(t *testing.T) {
+	t.Parallel()
+
+	f := newDeviceFixture(t)
+	f.resolver.Timeout = 5 * time.Second
+	devicePath := f.addDevice(t, "vdc", "unrelated")
+
+	// udev creates the link some time after the device is attached
+	linkErr := make(chan error, 1)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		linkErr <- os.Symlink(devicePath, filepath.Join(f.resolver.DiskByIDPath, "virtio-"+testSerial))
+	}()
+
+	got, err := f.resolver.Resolve(context.Background(), testSerial)
+	if symlinkErr := <-linkErr; symlinkErr != nil {
+		t.Fatalf("failed to create link: %s", symlinkErr)
+	}
+
+	if err != nil {
+		t.Fatalf("expected device to resolve, got %s", err)
+	}
+
+	if got != devicePath {
+		t.Errorf("expected %s, got %s", devicePath, got)
+	}
+}
+
+func TestDeviceResolver_Ambiguous(t *testing.T) {
+	t.Parallel()
+
+	f := newDeviceFixture(t)
+	f.addDevice(t, "vdb", testSerial[:20])
+	f.addDevice(t, "vdc", testSerial[:20]+"qrstuv")
+
+	_, err := f.resolver.Resolve(context.Background(), testSerial)
+	if !errors.Is(err, ssd.ErrDeviceAmbiguous) {
+		t.Errorf("expected ErrDeviceAmbiguous, got %v", err)
+	}
+}
+
+func TestDeviceResolver_Timeout(t *testing.T) {
+	t.Parallel()
+
+	f := newDeviceFixture(t)
+	f.addDevice(t, "vda", "boot-disk")
+
+	_, err := f.resolver.Resolve(context.Background(), testSerial)
+	if !errors.Is(err, ssd.ErrDeviceNotFound) {
+		t.Errorf("expected ErrDeviceNotFound, got %v", err)
+	}
+}
+
+func TestDeviceResolver_Rescan(t *testing.T) {
+	t.Parallel()
+
+	const sizeBytes = 10 * 1024 * 1024 * 1024
+
+	tests := []struct {
+		name string
+		// scsi devices expose a rescan trigger, virtio-blk devices do not
+		scsi    bool
+		size    bool
+		wantErr bool
+	}{
+		{name: "virtio-blk device", size: true},
+		{name: "SCSI device", scsi: true, size: true},
+		{name: "size missing", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			f := newDeviceFixture(t)
+			devicePath := f.addDevice(t, "vdb", testSerial)
+
+			if tt.size {
+				f.setSize(t, "vdb", sizeBytes)
+			}
+
+			var rescanPath string
+			if tt.scsi {
+				rescanPath = f.addRescanTrigger(t, "vdb")
+			}
+
+			// The device is usually found through its /dev/disk/by-id link
+			linkPath := filepath.Join(f.resolver.DiskByIDPath, "virtio-"+testSerial)
+			if err := os.Symlink(devicePath, linkPath); err != nil {
+				t.Fatalf("failed to create link: %s", err)
+			}
+
+			got, err := f.resolver.Rescan(linkPath)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if got != sizeBytes {
+				t.Errorf("expected %d bytes, got %d", sizeBytes, got)
+			}
+
+			if rescanPath != "" {
+				contents, readErr := os.ReadFile(rescanPath)
+				if readErr != nil || string(contents) != "1" {
+					t.Errorf("expected the device to be rescanned, got %q (%v)", contents, readErr)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,6 @@
+package ssd
+
+// RememberVolume lets tests provide the volume context which NodeExpandVolume and NodeGetVolumeStats lack.
+func (d *Node) RememberVolume(volumeID string, volumeContext map[string]string) {
+	d.rememberVolume(volumeID, volumeContext)
+}
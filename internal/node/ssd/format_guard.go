@@ -0,0 +1,162 @@
+package ssd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/crusoecloud/crusoe-csi-driver/internal/common"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/node"
+	"k8s.io/utils/exec"
+)
+
+const (
+	blkidCmd = "blkid"
+	// blkid exits with this status if it finds no signature on the device.
+	blkidExitNoSignature = 2
+
+	blkidUsageFilesystem = "filesystem"
+
+	// unwrittenCheckBytes is how much of the start and of the end of a device must be zeros for it to be unwritten.
+	unwrittenCheckBytes = 1024 * 1024
+)
+
+// deviceSignature is what blkid found on a device.
+type deviceSignature struct {
+	// fsType is the type of the filesystem, or of other data such as crypto_LUKS or swap
+	fsType             string
+	partitionTableType string
+	// usage is filesystem, raid, crypto or other
+	usage string
+}
+
+// probeDevice returns the signature on devicePath, or ok false if the device is blank.
+// Unlike the probe in SafeFormatAndMount it also reports what kind of data a signature belongs to.
+func probeDevice(executor exec.Interface, devicePath string) (signature deviceSignature, ok bool, err error) {
+	output, err := executor.Command(blkidCmd,
+		"-p", "-s", "TYPE", "-s", "PTTYPE", "-s", "USAGE", "-o", "export", devicePath).CombinedOutput()
+	if err != nil {
+		var exitErr exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitStatus() == blkidExitNoSignature {
+			return deviceSignature{}, false, nil
+		}
+
+		return deviceSignature{}, false, fmt.Errorf("failed to probe %s: %w: %s",
+			devicePath, err, strings.TrimSpace(string(output)))
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+
+		switch key {
+		case "TYPE":
+			signature.fsType = value
+		case "PTTYPE":
+			signature.partitionTableType = value
+		case "USAGE":
+			signature.usage = value
+		default:
+			// blkid always prints DEVNAME as well
+		}
+	}
+
+	return signature, true, nil
+}
+
+// VolumeData locates the data of a volume on its disk. Encrypted volumes keep it after their LUKS header.
+type VolumeData struct {
+	DevicePath string
+	Offset     int64
+}
+
+// checkFormat refuses to stage devices which SafeFormatAndMount would format or mount unexpectedly,
+// and returns the type of the filesystem on devicePath, or "" if the device is blank.
+// Blank devices are only formatted as policy allows, see checkBlankFormat.
+// Devices holding a partition table or anything other than a filesystem are never formatted.
+func checkFormat(executor exec.Interface, devicePath string, data VolumeData, policy common.FormatPolicy) (
+	string,
+	error,
+) {
+	signature, ok, err := probeDevice(executor, devicePath)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case !ok:
+		return "", checkBlankFormat(devicePath, data, policy)
+	case signature.partitionTableType != "":
+		return "", fmt.Errorf("%w: %s holds a %s partition table", node.ErrFormatRefused,
+			devicePath, signature.partitionTableType)
+	case signature.usage != "" && signature.usage != blkidUsageFilesystem:
+		return "", fmt.Errorf("%w: %s holds %s data (%s)", node.ErrFormatRefused,
+			devicePath, signature.fsType, signature.usage)
+	default:
+		return signature.fsType, nil
+	}
+}
+
+// checkBlankFormat returns an error unless policy allows formatting devicePath, which has no signature.
+// A device without a signature may still hold data, e.g. a raw block volume written by a database, a device whose
+// signatures were wiped or the wrong device, so the devices of provisioned volumes are only formatted if their data
+// was never written to. That state is kept on the disk itself, so it holds on every node the volume is staged on.
+func checkBlankFormat(devicePath string, data VolumeData, policy common.FormatPolicy) error {
+	switch policy {
+	case common.FormatForce:
+		return nil
+	case common.FormatUnwritten:
+		unwritten, err := isUnwritten(data)
+		if err != nil {
+			return err
+		}
+
+		if !unwritten {
+			return fmt.Errorf("%w: %s has no signature but was written to before, set %s to format it",
+				node.ErrFormatRefused, devicePath, common.ParameterForceFormatKey)
+		}
+
+		return nil
+	case common.FormatNever:
+		return fmt.Errorf("%w: %s has no signature and the volume was not provisioned by the driver, "+
+			"set %s to format it", node.ErrFormatRefused, devicePath, common.ParameterForceFormatKey)
+	default:
+		// Switch is intended to be exhaustive, reaching this case is a bug
+		panic(fmt.Sprintf("Switch is intended to be exhaustive, %d is not a valid switch case", policy))
+	}
+}
+
+// isUnwritten reports whether the start and the end of data read as zeros, as on a newly created disk.
+// Partition tables, filesystems and LUKS keep their metadata there, as do most applications writing raw devices.
+// Data written through an encrypted mapping is never zeros on the disk.
+func isUnwritten(data VolumeData) (bool, error) {
+	device, err := os.Open(data.DevicePath)
+	if err != nil {
+		return false, fmt.Errorf("failed to open %s: %w", data.DevicePath, err)
+	}
+	defer device.Close()
+
+	size, err := device.Seek(0, io.SeekEnd)
+	if err != nil {
+		return false, fmt.Errorf("failed to read the size of %s: %w", data.DevicePath, err)
+	}
+
+	buf := make([]byte, unwrittenCheckBytes)
+	for _, offset := range []int64{data.Offset, max(size-unwrittenCheckBytes, data.Offset)} {
+		n, readErr := device.ReadAt(buf, offset)
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return false, fmt.Errorf("failed to read %s: %w", data.DevicePath, readErr)
+		}
+
+		if slices.ContainsFunc(buf[:n], func(b byte) bool { return b != 0 }) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,171 @@
+package ssd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/crusoecloud/crusoe-csi-driver/internal/common"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/node"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/exec"
+)
+
+const (
+	e2fsckCmd    = "e2fsck"
+	xfsRepairCmd = "xfs_repair"
+
+	// e2fsck exit statuses are a bit mask.
+	e2fsckErrorsCorrected       = 1
+	e2fsckErrorsCorrectedReboot = 2
+	e2fsckErrorsUncorrected     = 4
+	e2fsckOperationalError      = 8
+
+	// xfs_repair -n exits with this status if it found corruption.
+	xfsRepairErrorsFound = 1
+	// xfs_repair exits with this status if the log is dirty, mounting the filesystem replays it.
+	xfsRepairDirtyLog = 2
+)
+
+// FsckOutcome is the result of checking a filesystem before it is mounted.
+type FsckOutcome string
+
+const (
+	// FsckSkipped means no check was run by the driver.
+	FsckSkipped FsckOutcome = ""
+	FsckClean   FsckOutcome = "clean"
+	// FsckRepaired means errors were found and repaired.
+	FsckRepaired FsckOutcome = "repaired"
+	// FsckErrorsFound means errors were found but not repaired, as the policy only checks the filesystem.
+	FsckErrorsFound FsckOutcome = "errors found"
+)
+
+// FilesystemCheck runs the check or repair tool of a filesystem according to a fsck policy.
+type FilesystemCheck struct {
+	Exec       exec.Interface
+	DevicePath string
+	// FsType is the type of the filesystem on the device, as reported by blkid
+	FsType string
+	Policy common.FsckPolicy
+	// Timeout bounds the whole check, zero leaves only the RPC deadline.
+	// The tool is killed when it expires, e2fsck and xfs_repair can be safely rerun after being interrupted.
+	Timeout time.Duration
+}
+
+// Run checks the filesystem. Errors which the policy does not repair are reported as FsckErrorsFound,
+// errors which could not be repaired are returned as node.ErrFilesystemErrors.
+func (c *FilesystemCheck) Run(ctx context.Context) (FsckOutcome, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	var outcome FsckOutcome
+	var err error
+
+	switch {
+	case c.Policy == common.FsckPolicyDefault || c.Policy == common.FsckPolicyNone:
+		return FsckSkipped, nil
+	case strings.HasPrefix(c.FsType, "ext"):
+		outcome, err = c.runE2fsck(ctx)
+	case c.FsType == common.FSTypeXFS:
+		outcome, err = c.runXFSRepair(ctx)
+	default:
+		klog.InfoS("Skipping filesystem check, no check tool for filesystem",
+			common.LogKeyDevicePath, c.DevicePath, "fsType", c.FsType)
+
+		return FsckSkipped, nil
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return outcome, fmt.Errorf("%w: filesystem check of %s after %s", node.ErrOperationTimeout, c.DevicePath, c.Timeout)
+	}
+
+	return outcome, err
+}
+
+func (c *FilesystemCheck) runE2fsck(ctx context.Context) (FsckOutcome, error) {
+	// -n answers no to every question, -p repairs everything which is safe to repair without a human
+	mode := "-n"
+	if c.Policy == common.FsckPolicyRepair {
+		mode = "-p"
+	}
+
+	output, status, err := c.run(ctx, e2fsckCmd, mode, c.DevicePath)
+	if err != nil {
+		return FsckSkipped, err
+	}
+
+	switch {
+	case status == 0:
+		return FsckClean, nil
+	case status&e2fsckOperationalError != 0:
+		return FsckSkipped, fmt.Errorf("e2fsck failed on %s with status %d: %s", c.DevicePath, status, output)
+	case status&e2fsckErrorsUncorrected != 0 && c.Policy == common.FsckPolicyCheck:
+		return FsckErrorsFound, nil
+	case status&e2fsckErrorsUncorrected != 0:
+		return FsckSkipped, fmt.Errorf("%w: e2fsck on %s: %s", node.ErrFilesystemErrors, c.DevicePath, output)
+	case status&(e2fsckErrorsCorrected|e2fsckErrorsCorrectedReboot) != 0:
+		return FsckRepaired, nil
+	default:
+		return FsckSkipped, fmt.Errorf("e2fsck exited with unexpected status %d on %s: %s", status, c.DevicePath, output)
+	}
+}
+
+func (c *FilesystemCheck) runXFSRepair(ctx context.Context) (FsckOutcome, error) {
+	// xfs_repair exits 0 whether or not it repaired anything, so it is only run if the read-only check finds errors
+	output, status, err := c.run(ctx, xfsRepairCmd, "-n", c.DevicePath)
+	if err != nil {
+		return FsckSkipped, err
+	}
+
+	switch status {
+	case 0:
+		return FsckClean, nil
+	case xfsRepairDirtyLog:
+		klog.InfoS("Skipping filesystem check, the log is replayed when the filesystem is mounted",
+			common.LogKeyDevicePath, c.DevicePath)
+
+		return FsckSkipped, nil
+	case xfsRepairErrorsFound:
+		if c.Policy == common.FsckPolicyCheck {
+			return FsckErrorsFound, nil
+		}
+	default:
+		return FsckSkipped, fmt.Errorf("xfs_repair failed on %s with status %d: %s", c.DevicePath, status, output)
+	}
+
+	output, status, err = c.run(ctx, xfsRepairCmd, c.DevicePath)
+	if err != nil {
+		return FsckSkipped, err
+	}
+
+	if status != 0 {
+		return FsckSkipped, fmt.Errorf("%w: xfs_repair on %s exited with status %d: %s",
+			node.ErrFilesystemErrors, c.DevicePath, status, output)
+	}
+
+	return FsckRepaired, nil
+}
+
+// run runs a check tool and returns its output and exit status.
+// Errors are only returned if the tool could not be run at all.
+func (c *FilesystemCheck) run(ctx context.Context, cmd string, args ...string) (string, int, error) {
+	klog.InfoS("Checking filesystem", common.LogKeyDevicePath, c.DevicePath, "command", cmd, "args", args)
+
+	output, err := c.Exec.CommandContext(ctx, cmd, args...).CombinedOutput()
+	trimmed := strings.TrimSpace(string(output))
+	if err == nil {
+		return trimmed, 0, nil
+	}
+
+	var exitErr exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.Exited() {
+		return trimmed, exitErr.ExitStatus(), nil
+	}
+
+	return trimmed, 0, fmt.Errorf("failed to run %s on %s: %w", cmd, c.DevicePath, err)
+}
@@ -0,0 +1,118 @@
+package ssd_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/crusoecloud/crusoe-csi-driver/internal/common"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/node"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/node/ssd"
+	testingexec "k8s.io/utils/exec/testing"
+)
+
+func TestFilesystemCheck_Run(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		fsType      string
+		policy      common.FsckPolicy
+		exitErrs    []error
+		wantArgv    [][]string
+		wantOutcome ssd.FsckOutcome
+		wantErr     error
+	}{
+		{
+			name:   "none",
+			fsType: "ext4",
+			policy: common.FsckPolicyNone,
+		},
+		{
+			name:        "ext4 check clean",
+			fsType:      "ext4",
+			policy:      common.FsckPolicyCheck,
+			exitErrs:    []error{nil},
+			wantArgv:    [][]string{{"e2fsck", "-n", testDevicePath}},
+			wantOutcome: ssd.FsckClean,
+		},
+		{
+			name:        "ext4 check finds errors",
+			fsType:      "ext4",
+			policy:      common.FsckPolicyCheck,
+			exitErrs:    []error{testingexec.FakeExitError{Status: 4}},
+			wantArgv:    [][]string{{"e2fsck", "-n", testDevicePath}},
+			wantOutcome: ssd.FsckErrorsFound,
+		},
+		{
+			name:        "ext4 repair corrects errors",
+			fsType:      "ext4",
+			policy:      common.FsckPolicyRepair,
+			exitErrs:    []error{testingexec.FakeExitError{Status: 1}},
+			wantArgv:    [][]string{{"e2fsck", "-p", testDevicePath}},
+			wantOutcome: ssd.FsckRepaired,
+		},
+		{
+			name:     "ext4 repair leaves errors",
+			fsType:   "ext4",
+			policy:   common.FsckPolicyRepair,
+			exitErrs: []error{testingexec.FakeExitError{Status: 4}},
+			wantArgv: [][]string{{"e2fsck", "-p", testDevicePath}},
+			wantErr:  node.ErrFilesystemErrors,
+		},
+		{
+			name:        "xfs repair only runs after errors are found",
+			fsType:      "xfs",
+			policy:      common.FsckPolicyRepair,
+			exitErrs:    []error{testingexec.FakeExitError{Status: 1}, nil},
+			wantArgv:    [][]string{{"xfs_repair", "-n", testDevicePath}, {"xfs_repair", testDevicePath}},
+			wantOutcome: ssd.FsckRepaired,
+		},
+		{
+			name:        "xfs clean is not repaired",
+			fsType:      "xfs",
+			policy:      common.FsckPolicyRepair,
+			exitErrs:    []error{nil},
+			wantArgv:    [][]string{{"xfs_repair", "-n", testDevicePath}},
+			wantOutcome: ssd.FsckClean,
+		},
+		{
+			name:     "xfs dirty log is left to mount",
+			fsType:   "xfs",
+			policy:   common.FsckPolicyCheck,
+			exitErrs: []error{testingexec.FakeExitError{Status: 2}},
+			wantArgv: [][]string{{"xfs_repair", "-n", testDevicePath}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var argv [][]string
+			script := make([]testingexec.FakeCommandAction, 0, len(tt.exitErrs))
+			for _, exitErr := range tt.exitErrs {
+				script = append(script, fakeCommand("", exitErr, &argv))
+			}
+
+			outcome, err := (&ssd.FilesystemCheck{
+				Exec:       &testingexec.FakeExec{CommandScript: script},
+				DevicePath: testDevicePath,
+				FsType:     tt.fsType,
+				Policy:     tt.policy,
+			}).Run(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if outcome != tt.wantOutcome {
+				t.Errorf("expected outcome %q, got %q", tt.wantOutcome, outcome)
+			}
+
+			if !slices.EqualFunc(argv, tt.wantArgv, slices.Equal) {
+				t.Errorf("expected commands %v, got %v", tt.wantArgv, argv)
+			}
+		})
+	}
+}
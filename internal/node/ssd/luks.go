@@ -0,0 +1,286 @@
+package ssd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/crusoecloud/crusoe-csi-driver/internal/common"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/exec"
+)
+
+const (
+	DefaultMapperPath = "/dev/mapper"
+
+	cryptsetupCmd    = "cryptsetup"
+	luksMapperPrefix = "luks-"
+
+	// luksDataOffsetSectors is where the data of an encrypted volume starts on its disk, in 512 byte sectors.
+	// It is the LUKS2 default, pinned so that the data can be found without reading the header.
+	luksDataOffsetSectors = 32768
+	// LUKSDataOffset is luksDataOffsetSectors in bytes.
+	LUKSDataOffset = luksDataOffsetSectors * 512
+
+	// cryptsetup exits with this status if no key slot accepts the passphrase.
+	cryptsetupExitBadPassphrase = 2
+)
+
+var (
+	ErrPassphraseMissing      = errors.New("encrypted volume requires a passphrase in the node stage secret")
+	ErrPassphraseRejected     = errors.New("passphrase does not unlock the encrypted volume")
+	ErrUnencryptedData        = errors.New("device holds unencrypted data, refusing to encrypt it")
+	ErrEncryptedVolumeNotOpen = errors.New("encrypted volume is not open, it must be staged first")
+	ErrKeyFileDirUnset        = errors.New("rotating the passphrase needs a key file directory on a tmpfs")
+)
+
+// LUKS manages the dm-crypt mappings of encrypted volumes with cryptsetup.
+// The mapping of a volume is named after its volume ID, so it can be found again
+// by requests which only carry the volume ID. Zero values use the defaults.
+type LUKS struct {
+	Exec       exec.Interface
+	MapperPath string
+	// KeyFileDir holds the new passphrase while a key is rotated, it must be a tmpfs so the passphrase never
+	// reaches a disk. Passphrases are not rotated without it.
+	KeyFileDir string
+}
+
+func (l *LUKS) exec() exec.Interface {
+	if l.Exec == nil {
+		return exec.New()
+	}
+
+	return l.Exec
+}
+
+func luksMapperName(volumeID string) string {
+	return luksMapperPrefix + volumeID
+}
+
+// MappedDevicePath returns the path of the decrypted device of volumeID.
+func (l *LUKS) MappedDevicePath(volumeID string) string {
+	mapperPath := l.MapperPath
+	if mapperPath == "" {
+		mapperPath = DefaultMapperPath
+	}
+
+	return filepath.Join(mapperPath, luksMapperName(volumeID))
+}
+
+// IsOpen reports whether the mapping of volumeID exists.
+func (l *LUKS) IsOpen(volumeID string) bool {
+	_, err := os.Stat(l.MappedDevicePath(volumeID))
+
+	return err == nil
+}
+
+// Open unlocks the encrypted device of volumeID with the passphrase in secrets and returns the mapped device.
+// A blank device is formatted with LUKS first if policy allows it, see checkBlankFormat. If secrets also hold
+// the previous passphrase and it still unlocks the device, the previous passphrase is replaced by the current one.
+func (l *LUKS) Open(
+	ctx context.Context,
+	devicePath string,
+	volumeID string,
+	secrets map[string]string,
+	policy common.FormatPolicy,
+) (string, error) {
+	passphrase := secrets[common.SecretPassphraseKey]
+	if passphrase == "" {
+		return "", ErrPassphraseMissing
+	}
+
+	mappedPath := l.MappedDevicePath(volumeID)
+	if l.IsOpen(volumeID) {
+		return mappedPath, nil
+	}
+
+	isLUKS, err := l.isLUKS(ctx, devicePath)
+	if err != nil {
+		return "", err
+	}
+
+	if !isLUKS {
+		if formatErr := l.format(ctx, devicePath, passphrase, policy); formatErr != nil {
+			return "", formatErr
+		}
+	} else if rotateErr := l.rotateKey(ctx, devicePath, secrets); rotateErr != nil {
+		return "", rotateErr
+	}
+
+	_, err = l.run(ctx, passphrase, "open", "--type", "luks", "--key-file", "-", devicePath, luksMapperName(volumeID))
+	if err != nil {
+		return "", fmt.Errorf("failed to open encrypted device %s: %w", devicePath, err)
+	}
+
+	klog.InfoS("Opened encrypted volume",
+		common.LogKeyVolumeID, volumeID,
+		common.LogKeyDevicePath, mappedPath)
+
+	return mappedPath, nil
+}
+
+// Close removes the mapping of volumeID, it is a noop if the volume is not open.
+func (l *LUKS) Close(ctx context.Context, volumeID string) error {
+	if !l.IsOpen(volumeID) {
+		return nil
+	}
+
+	if _, err := l.run(ctx, "", "close", luksMapperName(volumeID)); err != nil {
+		return fmt.Errorf("failed to close encrypted volume %s: %w", volumeID, err)
+	}
+
+	klog.InfoS("Closed encrypted volume", common.LogKeyVolumeID, volumeID)
+
+	return nil
+}
+
+// Resize grows the mapping of volumeID to span its device after the device was expanded.
+// LUKS2 devices whose volume key is kept in the kernel keyring need the passphrase.
+func (l *LUKS) Resize(ctx context.Context, volumeID, passphrase string) error {
+	args := []string{"resize", luksMapperName(volumeID)}
+	if passphrase != "" {
+		args = append(args, "--key-file", "-")
+	}
+
+	if _, err := l.run(ctx, passphrase, args...); err != nil {
+		return fmt.Errorf("failed to resize encrypted volume %s: %w", volumeID, err)
+	}
+
+	return nil
+}
+
+func (l *LUKS) isLUKS(ctx context.Context, devicePath string) (bool, error) {
+	_, err := l.run(ctx, "", "isLuks", devicePath)
+	if err == nil {
+		return true, nil
+	}
+
+	var exitErr exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitStatus() == 1 {
+		return false, nil
+	}
+
+	return false, fmt.Errorf("failed to check if %s is encrypted: %w", devicePath, err)
+}
+
+// format initializes LUKS on a blank device if policy allows it. Devices holding any signature,
+// e.g. a filesystem or partition table, are never formatted, as that would destroy their data.
+func (l *LUKS) format(ctx context.Context, devicePath, passphrase string, policy common.FormatPolicy) error {
+	signature, ok, err := probeDevice(l.exec(), devicePath)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case ok && signature.partitionTableType != "":
+		return fmt.Errorf("%w: %s holds a %s partition table", ErrUnencryptedData,
+			devicePath, signature.partitionTableType)
+	case ok:
+		return fmt.Errorf("%w: %s holds %s", ErrUnencryptedData, devicePath, signature.fsType)
+	}
+
+	if err = checkBlankFormat(devicePath, VolumeData{DevicePath: devicePath}, policy); err != nil {
+		return err
+	}
+
+	klog.InfoS("Formatting device with LUKS", common.LogKeyDevicePath, devicePath)
+
+	_, err = l.run(ctx, passphrase, "luksFormat", "--batch-mode", "--type", "luks2",
+		"--offset", strconv.Itoa(luksDataOffsetSectors), "--key-file", "-", devicePath)
+	if err != nil {
+		return fmt.Errorf("failed to format encrypted device %s: %w", devicePath, err)
+	}
+
+	return nil
+}
+
+// rotateKey replaces the previous passphrase with the current one if the current one does not unlock the device yet.
+func (l *LUKS) rotateKey(ctx context.Context, devicePath string, secrets map[string]string) error {
+	passphrase := secrets[common.SecretPassphraseKey]
+	previousPassphrase := secrets[common.SecretPreviousPassphraseKey]
+
+	unlocks, err := l.testPassphrase(ctx, devicePath, passphrase)
+	if err != nil || unlocks {
+		return err
+	}
+
+	if previousPassphrase == "" {
+		return fmt.Errorf("%w: %s", ErrPassphraseRejected, devicePath)
+	}
+
+	previousUnlocks, err := l.testPassphrase(ctx, devicePath, previousPassphrase)
+	if err != nil {
+		return err
+	}
+
+	if !previousUnlocks {
+		return fmt.Errorf("%w: neither the current nor the previous passphrase unlocks %s",
+			ErrPassphraseRejected, devicePath)
+	}
+
+	if l.KeyFileDir == "" {
+		return fmt.Errorf("%w: %s", ErrKeyFileDirUnset, devicePath)
+	}
+
+	klog.InfoS("Rotating encrypted volume passphrase", common.LogKeyDevicePath, devicePath)
+
+	// cryptsetup reads only one key from stdin, the new key is passed in a file only readable by the driver
+	keyFile, err := os.CreateTemp(l.KeyFileDir, "luks-key-")
+	if err != nil {
+		return fmt.Errorf("failed to create key file: %w", err)
+	}
+	defer os.Remove(keyFile.Name())
+
+	_, writeErr := keyFile.WriteString(passphrase)
+	closeErr := keyFile.Close()
+	if err = errors.Join(writeErr, closeErr); err != nil {
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+
+	_, err = l.run(ctx, previousPassphrase, "luksAddKey", "--key-file", "-", devicePath, keyFile.Name())
+	if err != nil {
+		return fmt.Errorf("failed to add passphrase to %s: %w", devicePath, err)
+	}
+
+	// The previous key slot is removed last, so an interrupted rotation never leaves the volume locked
+	_, err = l.run(ctx, previousPassphrase, "luksRemoveKey", "--key-file", "-", devicePath)
+	if err != nil {
+		return fmt.Errorf("failed to remove previous passphrase from %s: %w", devicePath, err)
+	}
+
+	return nil
+}
+
+func (l *LUKS) testPassphrase(ctx context.Context, devicePath, passphrase string) (bool, error) {
+	_, err := l.run(ctx, passphrase, "open", "--test-passphrase", "--type", "luks", "--key-file", "-", devicePath)
+	if err == nil {
+		return true, nil
+	}
+
+	var exitErr exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitStatus() == cryptsetupExitBadPassphrase {
+		return false, nil
+	}
+
+	return false, fmt.Errorf("failed to test passphrase of %s: %w", devicePath, err)
+}
+
+// run runs cryptsetup with args, passing stdin if it is not empty.
+// The passphrase is never part of the arguments, so errors are safe to log.
+func (l *LUKS) run(ctx context.Context, stdin string, args ...string) ([]byte, error) {
+	cmd := l.exec().CommandContext(ctx, cryptsetupCmd, args...)
+	if stdin != "" {
+		cmd.SetStdin(strings.NewReader(stdin))
+	}
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return output, fmt.Errorf("cryptsetup %s: %w: %s", args[0], err, strings.TrimSpace(string(output)))
+	}
+
+	return output, nil
+}
@@ -0,0 +1,306 @@
+package ssd_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/crusoecloud/crusoe-csi-driver/internal/common"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/node"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/node/ssd"
+	"k8s.io/utils/exec"
+	testingexec "k8s.io/utils/exec/testing"
+)
+
+const (
+	testVolumeID   = "test-volume-id"
+	testDevicePath = "/dev/vdb"
+)
+
+// recordedCommand is a command run by a fakeRecordedCommand action.
+type recordedCommand struct {
+	cmd *testingexec.FakeCmd
+}
+
+func (r recordedCommand) stdin(t *testing.T) string {
+	t.Helper()
+
+	if r.cmd.Stdin == nil {
+		return ""
+	}
+
+	contents, err := io.ReadAll(r.cmd.Stdin)
+	if err != nil {
+		t.Fatalf("failed to read stdin: %v", err)
+	}
+
+	return string(contents)
+}
+
+// fakeRecordedCommand returns an exec action which exits with err and appends the command to commands.
+func fakeRecordedCommand(err error, commands *[]recordedCommand) testingexec.FakeCommandAction {
+	return func(cmd string, args ...string) exec.Cmd {
+		fakeCmd := &testingexec.FakeCmd{
+			CombinedOutputScript: []testingexec.FakeAction{
+				func() ([]byte, []byte, error) { return nil, nil, err },
+			},
+		}
+		*commands = append(*commands, recordedCommand{cmd: fakeCmd})
+
+		return testingexec.InitFakeCmd(fakeCmd, cmd, args...)
+	}
+}
+
+func newLUKS(t *testing.T, script []testingexec.FakeCommandAction) *ssd.LUKS {
+	t.Helper()
+
+	fakeExec := &testingexec.FakeExec{CommandScript: script}
+
+	return &ssd.LUKS{Exec: fakeExec, MapperPath: t.TempDir(), KeyFileDir: t.TempDir()}
+}
+
+func TestLUKS_Open_FormatsBlankDevice(t *testing.T) {
+	t.Parallel()
+
+	var commands []recordedCommand
+	luks := newLUKS(t, []testingexec.FakeCommandAction{
+		fakeRecordedCommand(testingexec.FakeExitError{Status: 1}, &commands),
+		fakeRecordedCommand(testingexec.FakeExitError{Status: 2}, &commands),
+		fakeRecordedCommand(nil, &commands),
+		fakeRecordedCommand(nil, &commands),
+	})
+
+	mappedPath, err := luks.Open(context.Background(), testDevicePath, testVolumeID,
+		map[string]string{common.SecretPassphraseKey: "secret"}, common.FormatForce)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := luks.MappedDevicePath(testVolumeID); mappedPath != want {
+		t.Errorf("expected mapped device %s, got %s", want, mappedPath)
+	}
+
+	wantArgv := [][]string{
+		{"cryptsetup", "isLuks", testDevicePath},
+		{"blkid", "-p", "-s", "TYPE", "-s", "PTTYPE", "-s", "USAGE", "-o", "export", testDevicePath},
+		{
+			"cryptsetup", "luksFormat", "--batch-mode", "--type", "luks2", "--offset", "32768",
+			"--key-file", "-", testDevicePath,
+		},
+		{"cryptsetup", "open", "--type", "luks", "--key-file", "-", testDevicePath, "luks-" + testVolumeID},
+	}
+	for i, want := range wantArgv {
+		if !slices.Equal(commands[i].cmd.Argv, want) {
+			t.Errorf("command %d: expected %v, got %v", i, want, commands[i].cmd.Argv)
+		}
+	}
+
+	for _, i := range []int{2, 3} {
+		if stdin := commands[i].stdin(t); stdin != "secret" {
+			t.Errorf("command %d: expected passphrase on stdin, got %q", i, stdin)
+		}
+	}
+}
+
+func TestLUKS_Open_RotatesKey(t *testing.T) {
+	t.Parallel()
+
+	var commands []recordedCommand
+	luks := newLUKS(t, []testingexec.FakeCommandAction{
+		fakeRecordedCommand(nil, &commands),
+		fakeRecordedCommand(testingexec.FakeExitError{Status: 2}, &commands),
+		fakeRecordedCommand(nil, &commands),
+		fakeRecordedCommand(nil, &commands),
+		fakeRecordedCommand(nil, &commands),
+		fakeRecordedCommand(nil, &commands),
+	})
+
+	_, err := luks.Open(context.Background(), testDevicePath, testVolumeID, map[string]string{
+		common.SecretPassphraseKey:         "new",
+		common.SecretPreviousPassphraseKey: "old",
+	}, common.FormatNever)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wantVerbs := []string{"isLuks", "open", "open", "luksAddKey", "luksRemoveKey", "open"}
+	wantStdin := []string{"", "new", "old", "old", "old", "new"}
+	for i, verb := range wantVerbs {
+		if commands[i].cmd.Argv[1] != verb {
+			t.Errorf("command %d: expected cryptsetup %s, got %v", i, verb, commands[i].cmd.Argv)
+		}
+
+		if stdin := commands[i].stdin(t); stdin != wantStdin[i] {
+			t.Errorf("command %d: expected %q on stdin, got %q", i, wantStdin[i], stdin)
+		}
+	}
+
+	// The key file holding the new passphrase is removed once it was added
+	keyFiles, err := filepath.Glob(filepath.Join(luks.KeyFileDir, "*"))
+	if err != nil || len(keyFiles) != 0 {
+		t.Errorf("expected key file to be removed, found %v (%v)", keyFiles, err)
+	}
+}
+
+func TestLUKS_Open_RefusesRotationWithoutKeyFileDir(t *testing.T) {
+	t.Parallel()
+
+	var commands []recordedCommand
+	luks := newLUKS(t, []testingexec.FakeCommandAction{
+		fakeRecordedCommand(nil, &commands),
+		fakeRecordedCommand(testingexec.FakeExitError{Status: 2}, &commands),
+		fakeRecordedCommand(nil, &commands),
+	})
+	luks.KeyFileDir = ""
+
+	_, err := luks.Open(context.Background(), testDevicePath, testVolumeID, map[string]string{
+		common.SecretPassphraseKey:         "new",
+		common.SecretPreviousPassphraseKey: "old",
+	}, common.FormatNever)
+	if !errors.Is(err, ssd.ErrKeyFileDirUnset) {
+		t.Fatalf("expected error %v, got %v", ssd.ErrKeyFileDirUnset, err)
+	}
+
+	for _, command := range commands {
+		if slices.Contains(command.cmd.Argv, "luksAddKey") {
+			t.Errorf("expected no key to be added, ran %v", command.cmd.Argv)
+		}
+	}
+}
+
+func TestLUKS_Open_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		secrets map[string]string
+		policy  common.FormatPolicy
+		script  func(commands *[]recordedCommand) []testingexec.FakeCommandAction
+		wantErr error
+	}{
+		{
+			name:    "missing passphrase",
+			secrets: map[string]string{},
+			script: func(_ *[]recordedCommand) []testingexec.FakeCommandAction {
+				return nil
+			},
+			wantErr: ssd.ErrPassphraseMissing,
+		},
+		{
+			name:    "device holds a filesystem",
+			secrets: map[string]string{common.SecretPassphraseKey: "secret"},
+			policy:  common.FormatForce,
+			script: func(commands *[]recordedCommand) []testingexec.FakeCommandAction {
+				return []testingexec.FakeCommandAction{
+					fakeRecordedCommand(testingexec.FakeExitError{Status: 1}, commands),
+					fakeCommand(blkidExt4ProbeOutput, nil, nil),
+				}
+			},
+			wantErr: ssd.ErrUnencryptedData,
+		},
+		{
+			name:    "device holds a partition table",
+			secrets: map[string]string{common.SecretPassphraseKey: "secret"},
+			policy:  common.FormatForce,
+			script: func(commands *[]recordedCommand) []testingexec.FakeCommandAction {
+				return []testingexec.FakeCommandAction{
+					fakeRecordedCommand(testingexec.FakeExitError{Status: 1}, commands),
+					fakeCommand("DEVNAME=/dev/vdb\nPTTYPE=gpt\n", nil, nil),
+				}
+			},
+			wantErr: ssd.ErrUnencryptedData,
+		},
+		{
+			name:    "blank device of a volume not provisioned by the driver",
+			secrets: map[string]string{common.SecretPassphraseKey: "secret"},
+			script: func(commands *[]recordedCommand) []testingexec.FakeCommandAction {
+				return []testingexec.FakeCommandAction{
+					fakeRecordedCommand(testingexec.FakeExitError{Status: 1}, commands),
+					fakeCommand("", testingexec.FakeExitError{Status: 2}, nil),
+				}
+			},
+			wantErr: node.ErrFormatRefused,
+		},
+		{
+			name:    "wrong passphrase without a previous passphrase",
+			secrets: map[string]string{common.SecretPassphraseKey: "wrong"},
+			script: func(commands *[]recordedCommand) []testingexec.FakeCommandAction {
+				return []testingexec.FakeCommandAction{
+					fakeRecordedCommand(nil, commands),
+					fakeRecordedCommand(testingexec.FakeExitError{Status: 2}, commands),
+				}
+			},
+			wantErr: ssd.ErrPassphraseRejected,
+		},
+		{
+			name: "neither passphrase unlocks the device",
+			secrets: map[string]string{
+				common.SecretPassphraseKey:         "wrong",
+				common.SecretPreviousPassphraseKey: "also-wrong",
+			},
+			script: func(commands *[]recordedCommand) []testingexec.FakeCommandAction {
+				return []testingexec.FakeCommandAction{
+					fakeRecordedCommand(nil, commands),
+					fakeRecordedCommand(testingexec.FakeExitError{Status: 2}, commands),
+					fakeRecordedCommand(testingexec.FakeExitError{Status: 2}, commands),
+				}
+			},
+			wantErr: ssd.ErrPassphraseRejected,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var commands []recordedCommand
+			luks := newLUKS(t, tt.script(&commands))
+
+			_, err := luks.Open(context.Background(), testDevicePath, testVolumeID, tt.secrets, tt.policy)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			for _, command := range commands {
+				if slices.Contains(command.cmd.Argv, "luksFormat") {
+					t.Errorf("expected device not to be formatted, ran %v", command.cmd.Argv)
+				}
+			}
+		})
+	}
+}
+
+func TestLUKS_Close(t *testing.T) {
+	t.Parallel()
+
+	var commands []recordedCommand
+	luks := newLUKS(t, []testingexec.FakeCommandAction{
+		fakeRecordedCommand(nil, &commands),
+	})
+
+	// Closing a volume which is not open is a noop
+	if err := luks.Close(context.Background(), testVolumeID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(commands) != 0 {
+		t.Fatalf("expected no commands for a closed volume, ran %d", len(commands))
+	}
+
+	if err := os.WriteFile(luks.MappedDevicePath(testVolumeID), nil, 0o600); err != nil {
+		t.Fatalf("failed to create mapped device: %v", err)
+	}
+
+	if err := luks.Close(context.Background(), testVolumeID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"cryptsetup", "close", "luks-" + testVolumeID}
+	if len(commands) != 1 || !slices.Equal(commands[0].cmd.Argv, want) {
+		t.Errorf("expected %v, got %v", want, commands)
+	}
+}
@@ -1,7 +1,9 @@
 package ssd
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/crusoecloud/crusoe-csi-driver/internal/common"
@@ -10,9 +12,12 @@ import (
 )
 
 func nodePublishVolume(
+	ctx context.Context,
 	mounter *mount.SafeFormatAndMount,
-	resizer *mount.ResizeFs,
+	resolver *DeviceResolver,
+	luks *LUKS,
 	mountOpts []string,
+	timeout time.Duration,
 	request *csi.NodePublishVolumeRequest,
 ) error {
 	volumeContext := request.GetVolumeContext()
@@ -21,9 +26,17 @@ func nodePublishVolume(
 		return node.ErrVolumeMissingSerialNumber
 	}
 
-	devicePath := getSSDDevicePath(serialNumber)
+	devicePath, err := publishedDevicePath(ctx, resolver, luks, request.GetVolumeId(), serialNumber, volumeContext)
+	if err != nil {
+		return err
+	}
+
+	if repairErr := node.RepairCorruptedMount(ctx, mounter, request.GetTargetPath(), timeout); repairErr != nil {
+		return repairErr
+	}
 
-	alreadyMounted, checkErr := node.VerifyMountedVolumeWithUtils(mounter, request.GetTargetPath(), devicePath)
+	alreadyMounted, checkErr := node.VerifyMountedVolumeWithUtils(ctx, mounter, request.GetTargetPath(), devicePath,
+		timeout)
 	if checkErr != nil {
 		return fmt.Errorf("failed to verify if volume is already mounted: %w", checkErr)
 	}
@@ -39,16 +52,46 @@ func nodePublishVolume(
 			Mounter:    mounter,
 			MountOpts:  mountOpts,
 			Request:    request,
-		}.Publish()
+			Timeout:    timeout,
+		}.Publish(ctx)
 	case request.GetVolumeCapability().GetMount() != nil:
+		if request.GetStagingTargetPath() == "" {
+			return node.ErrStagingPathEmpty
+		}
+
 		return (&PublishFilesystem{
-			DevicePath: devicePath,
-			Mounter:    mounter,
-			Resizer:    resizer,
-			MountOpts:  mountOpts,
-			Request:    request,
-		}).Publish()
+			Mounter:   mounter,
+			MountOpts: mountOpts,
+			Request:   request,
+			Timeout:   timeout,
+		}).Publish(ctx)
 	default:
 		return fmt.Errorf("%w: %s", node.ErrUnexpectedVolumeCapability, request.GetVolumeCapability())
 	}
 }
+
+// publishedDevicePath returns the device which is mounted for the volume, which is the mapped device of encrypted volumes.
+func publishedDevicePath(
+	ctx context.Context,
+	resolver *DeviceResolver,
+	luks *LUKS,
+	volumeID string,
+	serialNumber string,
+	volumeContext map[string]string,
+) (string, error) {
+	encrypted, err := common.IsEncrypted(volumeContext)
+	if err != nil {
+		return "", err
+	}
+
+	if !encrypted {
+		return resolver.Resolve(ctx, serialNumber)
+	}
+
+	// The passphrase is only passed when staging, publishing cannot open the volume itself
+	if !luks.IsOpen(volumeID) {
+		return "", fmt.Errorf("%w: %s", ErrEncryptedVolumeNotOpen, volumeID)
+	}
+
+	return luks.MappedDevicePath(volumeID), nil
+}
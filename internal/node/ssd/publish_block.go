@@ -1,13 +1,16 @@
 package ssd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/crusoecloud/crusoe-csi-driver/internal/node"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/tracing"
 	"k8s.io/mount-utils"
 )
 
@@ -16,9 +19,11 @@ type PublishBlock struct {
 	Request    *csi.NodePublishVolumeRequest
 	DevicePath string
 	MountOpts  []string
+	// Timeout bounds the bind mount, zero leaves only the RPC deadline
+	Timeout time.Duration
 }
 
-func (p PublishBlock) Publish() error {
+func (p PublishBlock) Publish(ctx context.Context) error {
 	dirPath := filepath.Dir(p.Request.GetTargetPath())
 
 	// Make parent directory for target path
@@ -45,9 +50,15 @@ func (p PublishBlock) Publish() error {
 
 	p.MountOpts = append(p.MountOpts, "bind")
 
-	err = p.Mounter.Mount(p.DevicePath, p.Request.GetTargetPath(), "", p.MountOpts)
+	_, span := tracing.Start(ctx, "BindMount",
+		tracing.DevicePathKey.String(p.DevicePath),
+		tracing.TargetPathKey.String(p.Request.GetTargetPath()))
+	err = node.RunWithTimeout(ctx, p.Timeout, "bind mount", func() error {
+		return p.Mounter.Mount(p.DevicePath, p.Request.GetTargetPath(), "", p.MountOpts)
+	})
+	tracing.End(span, err)
 	if err != nil {
-		return fmt.Errorf("%w at target path %s: %s", node.ErrFailedMount, p.Request.GetTargetPath(), err.Error())
+		return fmt.Errorf("%w at target path %s: %w", node.ErrFailedMount, p.Request.GetTargetPath(), err)
 	}
 
 	return nil
@@ -1,23 +1,28 @@
 package ssd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/crusoecloud/crusoe-csi-driver/internal/node"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/tracing"
 	"k8s.io/mount-utils"
 )
 
+// PublishFilesystem bind mounts a staged filesystem volume to a pod's target path.
+// The device is formatted, mounted and resized once per node by NodeStageVolume.
 type PublishFilesystem struct {
-	Mounter    *mount.SafeFormatAndMount
-	Resizer    *mount.ResizeFs
-	Request    *csi.NodePublishVolumeRequest
-	DevicePath string
-	MountOpts  []string
+	Mounter   *mount.SafeFormatAndMount
+	Request   *csi.NodePublishVolumeRequest
+	MountOpts []string
+	// Timeout bounds the bind mount, zero leaves only the RPC deadline
+	Timeout time.Duration
 }
 
-func (p *PublishFilesystem) Publish() error {
+func (p *PublishFilesystem) Publish(ctx context.Context) error {
 	// Make parent directory for target path
 	// os.MkdirAll will be a noop if the directory already exists
 	mkDirErr := os.MkdirAll(p.Request.GetTargetPath(), node.NewDirPerms)
@@ -25,25 +30,21 @@ func (p *PublishFilesystem) Publish() error {
 		return fmt.Errorf("failed to make directory for target path: %w", mkDirErr)
 	}
 
-	p.MountOpts = append(p.MountOpts, p.Request.GetVolumeCapability().GetMount().GetMountFlags()...)
-
-	err := p.Mounter.FormatAndMount(p.DevicePath,
-		p.Request.GetTargetPath(),
-		p.Request.GetVolumeCapability().GetMount().GetFsType(),
-		p.MountOpts)
-	if err != nil {
-		return fmt.Errorf("%w at target path %s: %s", node.ErrFailedMount, p.Request.GetTargetPath(), err.Error())
+	if err := node.ApplyPublishVolumeMountGroup(p.Request); err != nil {
+		return err
 	}
 
-	// Resize the filesystem to span the entire disk
-	// The size of the underlying disk may have changed due to volume expansion (offline)
-	ok, err := p.Resizer.Resize(p.DevicePath, p.Request.GetTargetPath())
-	if err != nil {
-		return fmt.Errorf("%w at target path %s: %w", node.ErrFailedResize, p.Request.GetTargetPath(), err)
-	}
+	p.MountOpts = append(p.MountOpts, "bind")
 
-	if !ok {
-		return fmt.Errorf("%w: %s", node.ErrFailedResize, p.Request.GetTargetPath())
+	_, span := tracing.Start(ctx, "BindMount",
+		tracing.DevicePathKey.String(p.Request.GetStagingTargetPath()),
+		tracing.TargetPathKey.String(p.Request.GetTargetPath()))
+	err := node.RunWithTimeout(ctx, p.Timeout, "bind mount", func() error {
+		return p.Mounter.Mount(p.Request.GetStagingTargetPath(), p.Request.GetTargetPath(), "", p.MountOpts)
+	})
+	tracing.End(span, err)
+	if err != nil {
+		return fmt.Errorf("%w at target path %s: %w", node.ErrFailedMount, p.Request.GetTargetPath(), err)
 	}
 
 	return nil
@@ -0,0 +1,125 @@
+package ssd_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/node"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/node/ssd"
+	"k8s.io/mount-utils"
+)
+
+func TestPublishFilesystem_Publish_BindMountsFromStagingPath(t *testing.T) {
+	t.Parallel()
+
+	fakeMounter := mount.NewFakeMounter(nil)
+	mounter := &mount.SafeFormatAndMount{Interface: fakeMounter}
+
+	stagingPath := t.TempDir()
+	targetPath := t.TempDir()
+
+	request := &csi.NodePublishVolumeRequest{
+		VolumeId:          "test-volume-id",
+		StagingTargetPath: stagingPath,
+		TargetPath:        targetPath,
+		Readonly:          true,
+		VolumeCapability: &csi.VolumeCapability{
+			AccessType: &csi.VolumeCapability_Mount{
+				Mount: &csi.VolumeCapability_MountVolume{FsType: "ext4"},
+			},
+		},
+	}
+
+	publisher := &ssd.PublishFilesystem{
+		Mounter:   mounter,
+		Request:   request,
+		MountOpts: []string{node.ReadOnlyMountOption},
+	}
+
+	if err := publisher.Publish(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mountPoints, err := fakeMounter.List()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(mountPoints) != 1 {
+		t.Fatalf("expected 1 mount, got %d", len(mountPoints))
+	}
+
+	mountPoint := mountPoints[0]
+	if mountPoint.Device != stagingPath || mountPoint.Path != targetPath {
+		t.Errorf("expected bind mount %s -> %s, got %s -> %s",
+			stagingPath, targetPath, mountPoint.Device, mountPoint.Path)
+	}
+
+	if !slices.Contains(mountPoint.Opts, "bind") || !slices.Contains(mountPoint.Opts, node.ReadOnlyMountOption) {
+		t.Errorf("expected read-only bind mount, got options %v", mountPoint.Opts)
+	}
+}
+
+func TestPublishFilesystem_Publish_VolumeMountGroup(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		group     string
+		wantGroup bool
+		wantErr   error
+	}{
+		{name: "group of a later pod", group: strconv.Itoa(os.Getgid()), wantGroup: true},
+		{name: "no group"},
+		{name: "invalid group", group: "users", wantErr: node.ErrInvalidVolumeMountGroup},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fakeMounter := mount.NewFakeMounter(nil)
+			stagingPath := t.TempDir()
+
+			publisher := &ssd.PublishFilesystem{
+				Mounter: &mount.SafeFormatAndMount{Interface: fakeMounter},
+				Request: &csi.NodePublishVolumeRequest{
+					VolumeId:          "test-volume-id",
+					StagingTargetPath: stagingPath,
+					TargetPath:        t.TempDir(),
+					VolumeCapability: &csi.VolumeCapability{
+						AccessType: &csi.VolumeCapability_Mount{
+							Mount: &csi.VolumeCapability_MountVolume{FsType: "ext4", VolumeMountGroup: tt.group},
+						},
+						AccessMode: &csi.VolumeCapability_AccessMode{
+							Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_MULTI_WRITER,
+						},
+					},
+				},
+			}
+
+			err := publisher.Publish(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if mounted := len(fakeMounter.GetLog()) == 1; mounted != (tt.wantErr == nil) {
+				t.Errorf("expected the bind mount only without an error, got %v", fakeMounter.GetLog())
+			}
+
+			fi, err := os.Stat(stagingPath)
+			if err != nil {
+				t.Fatalf("failed to stat staging path: %v", err)
+			}
+
+			if applied := fi.Mode()&os.ModeSetgid != 0; applied != tt.wantGroup {
+				t.Errorf("expected volume mount group applied to be %t, got mode %s", tt.wantGroup, fi.Mode())
+			}
+		})
+	}
+}
@@ -2,14 +2,22 @@ package ssd
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	crusoeapi "github.com/crusoecloud/client-go/swagger/v1alpha5"
 	"github.com/crusoecloud/crusoe-csi-driver/internal/common"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/crusoe"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/events"
 	"github.com/crusoecloud/crusoe-csi-driver/internal/node"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/tracing"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/klog/v2"
 	"k8s.io/mount-utils"
 )
@@ -19,6 +27,7 @@ type Node struct {
 	CrusoeClient      *crusoeapi.APIClient
 	CrusoeHTTPClient  *http.Client
 	HostInstance      *crusoeapi.InstanceV1Alpha5
+	Events            events.Recorder
 	Mounter           *mount.SafeFormatAndMount
 	Resizer           *mount.ResizeFs
 	CrusoeAPIEndpoint string
@@ -27,90 +36,354 @@ type Node struct {
 	PluginVersion     string
 	Capabilities      []*csi.NodeServiceCapability
 	MaxVolumesPerNode int64
+	// OnlineExpansion enables NodeExpandVolume, which grows the filesystem of an attached volume.
+	OnlineExpansion bool
+	VolumeHealth    node.VolumeHealthChecker
+	DeviceResolver  DeviceResolver
+	// LUKS opens and closes the mappings of encrypted volumes
+	LUKS LUKS
+	// OperationTimeout bounds each mount, format, resize and unmount, zero leaves only the RPC deadline
+	OperationTimeout time.Duration
+
+	// locks rejects overlapping operations on the same volume and path
+	locks node.VolumeLocks
+	// operations keeps a format or resize which timed out from overlapping a retry until it returns
+	operations node.DeviceOperations
+	// volumes caches volumeInfo by volume ID, as NodeGetVolumeStats and NodeExpandVolume have no volume context
+	volumes sync.Map
+	// fsckOutcomes holds the FsckOutcome of the last stage by volume ID, it is reported in the volume condition
+	fsckOutcomes sync.Map
+}
+
+// volumeInfo is the part of a volume's context needed after the volume is published.
+type volumeInfo struct {
+	serialNumber string
+	sizeBytes    int64
+}
+
+// rememberVolume caches the volume info from a stage or publish request's volume context.
+func (d *Node) rememberVolume(volumeID string, volumeContext map[string]string) {
+	serialNumber, ok := volumeContext[common.VolumeContextDiskSerialNumberKey]
+	if !ok {
+		return
+	}
+
+	// Volumes created before the size was recorded have no size, which skips the size check
+	sizeBytes, _ := strconv.ParseInt(volumeContext[common.VolumeContextDiskSizeBytesKey], 10, 64)
+
+	d.volumes.Store(volumeID, volumeInfo{serialNumber: serialNumber, sizeBytes: sizeBytes})
+}
+
+// lookupVolume returns the volume info of volumeID, fetching it from the API
+// if the volume was staged before the driver last restarted.
+func (d *Node) lookupVolume(ctx context.Context, volumeID string) (volumeInfo, error) {
+	if info, ok := d.volumes.Load(volumeID); ok {
+		//nolint:forcetypeassert // only volumeInfo is stored
+		return info.(volumeInfo), nil
+	}
+
+	disk, err := crusoe.FindDiskByIDFallible(ctx, d.CrusoeClient, d.HostInstance.ProjectId, volumeID)
+	if err != nil {
+		return volumeInfo{}, fmt.Errorf("failed to find disk %s: %w", volumeID, err)
+	}
+
+	sizeGiB, err := crusoe.NormalizeDiskSizeToGiB(disk)
+	if err != nil {
+		return volumeInfo{}, fmt.Errorf("failed to get size of disk %s: %w", volumeID, err)
+	}
+
+	info := volumeInfo{serialNumber: disk.SerialNumber, sizeBytes: int64(sizeGiB) * common.NumBytesInGiB}
+	d.volumes.Store(volumeID, info)
+
+	return info, nil
 }
 
-func (d *Node) NodeStageVolume(_ context.Context, _ *csi.NodeStageVolumeRequest) (
+func (d *Node) NodeStageVolume(ctx context.Context, request *csi.NodeStageVolumeRequest) (
 	*csi.NodeStageVolumeResponse,
 	error,
 ) {
-	klog.Errorf("%s: NodeStageVolume", common.ErrNotImplemented)
+	if err := node.ValidateStageRequest(request); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid stage request: %s", err)
+	}
 
-	return nil, status.Errorf(codes.Unimplemented, "%s: NodeStageVolume", common.ErrNotImplemented)
+	release, err := d.locks.Lock(request.GetVolumeId(), request.GetStagingTargetPath())
+	if err != nil {
+		//nolint:wrapcheck // error is already a gRPC status; wrapping would lose the status code
+		return nil, err
+	}
+	defer release()
+
+	d.rememberVolume(request.GetVolumeId(), request.GetVolumeContext())
+
+	outcome, err := nodeStageVolume(ctx, d.Mounter, d.Resizer, &d.DeviceResolver, &d.LUKS, &d.operations,
+		d.OperationTimeout, request)
+	d.recordFsckOutcome(request.GetVolumeId(), outcome)
+	if err != nil {
+		klog.ErrorS(err, "Failed to stage volume",
+			common.LogKeyVolumeID, request.GetVolumeId(),
+			common.LogKeyTargetPath, request.GetStagingTargetPath())
+		reason := node.PublishFailureReason(err)
+		d.Events.VolumeEventf(request.GetVolumeId(), corev1.EventTypeWarning, reason,
+			"failed to stage volume on node %s: %s", d.HostInstance.Name, err)
+		d.Events.NodeEventf(corev1.EventTypeWarning, reason,
+			"failed to stage volume %s: %s", request.GetVolumeId(), err)
+
+		return nil, status.Errorf(nodeErrorCode(err), "failed to stage volume %s: %s", request.GetVolumeId(), err.Error())
+	}
+
+	klog.InfoS("Successfully staged volume",
+		common.LogKeyVolumeID, request.GetVolumeId(),
+		common.LogKeyTargetPath, request.GetStagingTargetPath())
+
+	return &csi.NodeStageVolumeResponse{}, nil
+}
+
+// recordFsckOutcome emits an event for filesystem checks which found errors and remembers the outcome
+// for the volume condition. Skipped checks keep the previous outcome, as an already staged volume is not checked again.
+func (d *Node) recordFsckOutcome(volumeID string, outcome FsckOutcome) {
+	switch outcome {
+	case FsckSkipped:
+		return
+	case FsckClean:
+		d.fsckOutcomes.Delete(volumeID)
+
+		return
+	case FsckRepaired:
+		d.Events.VolumeEventf(volumeID, corev1.EventTypeNormal, events.ReasonFilesystemRepaired,
+			"repaired filesystem errors while staging volume on node %s", d.HostInstance.Name)
+	case FsckErrorsFound:
+		d.Events.VolumeEventf(volumeID, corev1.EventTypeWarning, events.ReasonFilesystemErrors,
+			"filesystem check found errors while staging volume on node %s, the volume was mounted without repair",
+			d.HostInstance.Name)
+	default:
+		// Switch is intended to be exhaustive, reaching this case is a bug
+		panic(fmt.Sprintf("Switch is intended to be exhaustive, %s is not a valid switch case", outcome))
+	}
+
+	d.fsckOutcomes.Store(volumeID, outcome)
+}
+
+// applyFsckOutcome adds the outcome of the filesystem check to an otherwise normal volume condition.
+func (d *Node) applyFsckOutcome(volumeID string, resp *csi.NodeGetVolumeStatsResponse) {
+	outcome, ok := d.fsckOutcomes.Load(volumeID)
+	if !ok || resp.GetVolumeCondition().GetAbnormal() {
+		return
+	}
+
+	switch outcome {
+	case FsckRepaired:
+		resp.VolumeCondition = &csi.VolumeCondition{
+			Message: "volume is healthy, filesystem errors were repaired when it was staged",
+		}
+	case FsckErrorsFound:
+		resp.VolumeCondition = &csi.VolumeCondition{
+			Abnormal: true,
+			Message:  "filesystem check found errors when the volume was staged, they were not repaired",
+		}
+	default:
+		// Clean and skipped checks are not stored
+	}
 }
 
-func (d *Node) NodeUnstageVolume(_ context.Context, _ *csi.NodeUnstageVolumeRequest) (
+func (d *Node) NodeUnstageVolume(ctx context.Context, request *csi.NodeUnstageVolumeRequest) (
 	*csi.NodeUnstageVolumeResponse,
 	error,
 ) {
-	klog.Errorf("%s: NodeUnstageVolume", common.ErrNotImplemented)
+	if request.GetVolumeId() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid unstage request: %s", node.ErrVolumeIDEmpty)
+	}
 
-	return nil, status.Errorf(codes.Unimplemented, "%s: NodeUnstageVolume", common.ErrNotImplemented)
+	stagingPath := request.GetStagingTargetPath()
+	if stagingPath == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid unstage request: %s", node.ErrStagingPathEmpty)
+	}
+
+	release, err := d.locks.Lock(request.GetVolumeId(), stagingPath)
+	if err != nil {
+		//nolint:wrapcheck // error is already a gRPC status; wrapping would lose the status code
+		return nil, err
+	}
+	defer release()
+
+	// Block volumes are never staged, in which case the staging target path does not exist and this is a noop
+	_, span := tracing.Start(ctx, "CleanupMountPoint", tracing.TargetPathKey.String(stagingPath))
+	err = node.CleanupMountPoint(ctx, d.Mounter, stagingPath, d.OperationTimeout)
+	tracing.End(span, err)
+	if err != nil {
+		klog.ErrorS(err, "Failed to cleanup staging mount point",
+			common.LogKeyVolumeID, request.GetVolumeId(),
+			common.LogKeyTargetPath, stagingPath)
+		d.Events.VolumeEventf(request.GetVolumeId(), corev1.EventTypeWarning, events.ReasonUnmountFailed,
+			"failed to unstage volume on node %s: %s", d.HostInstance.Name, err)
+		d.Events.NodeEventf(corev1.EventTypeWarning, events.ReasonUnmountFailed,
+			"failed to unstage volume %s: %s", request.GetVolumeId(), err)
+
+		return nil, status.Errorf(node.OperationErrorCode(err), "failed to cleanup staging mount point for volume %s: %s",
+			request.GetVolumeId(), err.Error())
+	}
+
+	// Encrypted volumes are closed once nothing is mounted from their mapped device
+	_, closeSpan := tracing.Start(ctx, "CloseEncryptedDevice")
+	err = d.LUKS.Close(ctx, request.GetVolumeId())
+	tracing.End(closeSpan, err)
+	if err != nil {
+		klog.ErrorS(err, "Failed to close encrypted volume", common.LogKeyVolumeID, request.GetVolumeId())
+		d.Events.VolumeEventf(request.GetVolumeId(), corev1.EventTypeWarning, events.ReasonUnmountFailed,
+			"failed to unstage volume on node %s: %s", d.HostInstance.Name, err)
+		d.Events.NodeEventf(corev1.EventTypeWarning, events.ReasonUnmountFailed,
+			"failed to unstage volume %s: %s", request.GetVolumeId(), err)
+
+		return nil, status.Errorf(codes.Internal, "failed to close encrypted volume %s: %s",
+			request.GetVolumeId(), err.Error())
+	}
+
+	d.fsckOutcomes.Delete(request.GetVolumeId())
+
+	klog.InfoS("Successfully unstaged volume",
+		common.LogKeyVolumeID, request.GetVolumeId(),
+		common.LogKeyTargetPath, stagingPath)
+
+	return &csi.NodeUnstageVolumeResponse{}, nil
 }
 
-func (d *Node) NodePublishVolume(_ context.Context, request *csi.NodePublishVolumeRequest) (
+func (d *Node) NodePublishVolume(ctx context.Context, request *csi.NodePublishVolumeRequest) (
 	*csi.NodePublishVolumeResponse,
 	error,
 ) {
-	klog.Infof("Received request to publish volume: %+v", request)
+	if err := node.ValidateVolumeMountGroup(request.GetVolumeCapability()); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid publish request: %s", err)
+	}
+
+	release, err := d.locks.Lock(request.GetVolumeId(), request.GetTargetPath())
+	if err != nil {
+		//nolint:wrapcheck // error is already a gRPC status; wrapping would lose the status code
+		return nil, err
+	}
+	defer release()
 
 	var mountOpts []string
 
 	if request.GetReadonly() {
-		// Read-only volumes cannot be written to in any way
-		// We should not attempt to replay the journal
-		mountOpts = append(mountOpts, node.ReadOnlyMountOption, node.NoLoadMountOption)
+		// The journal is replayed when staging, so the bind mount only needs to be read-only
+		mountOpts = append(mountOpts, node.ReadOnlyMountOption)
 	}
 
-	err := nodePublishVolume(d.Mounter, d.Resizer, mountOpts, request)
+	d.rememberVolume(request.GetVolumeId(), request.GetVolumeContext())
+
+	err = nodePublishVolume(ctx, d.Mounter, &d.DeviceResolver, &d.LUKS, mountOpts, d.OperationTimeout, request)
 	if err != nil {
-		klog.Errorf("failed to publish volume %s: %s", request.GetVolumeId(), err.Error())
+		klog.ErrorS(err, "Failed to publish volume",
+			common.LogKeyVolumeID, request.GetVolumeId(),
+			common.LogKeyTargetPath, request.GetTargetPath())
+		reason := node.PublishFailureReason(err)
+		d.Events.VolumeEventf(request.GetVolumeId(), corev1.EventTypeWarning, reason,
+			"failed to publish volume on node %s: %s", d.HostInstance.Name, err)
+		d.Events.NodeEventf(corev1.EventTypeWarning, reason,
+			"failed to publish volume %s: %s", request.GetVolumeId(), err)
 
-		return nil, status.Errorf(codes.Internal, "failed to publish volume %s: %s", request.GetVolumeId(), err.Error())
+		return nil, status.Errorf(nodeErrorCode(err), "failed to publish volume %s: %s",
+			request.GetVolumeId(), err.Error())
 	}
 
-	klog.Infof("Successfully published volume: %s", request.GetVolumeId())
+	klog.InfoS("Successfully published volume",
+		common.LogKeyVolumeID, request.GetVolumeId(),
+		common.LogKeyTargetPath, request.GetTargetPath())
 
 	return &csi.NodePublishVolumeResponse{}, nil
 }
 
-func (d *Node) NodeUnpublishVolume(_ context.Context, request *csi.NodeUnpublishVolumeRequest) (
+func (d *Node) NodeUnpublishVolume(ctx context.Context, request *csi.NodeUnpublishVolumeRequest) (
 	*csi.NodeUnpublishVolumeResponse,
 	error,
 ) {
-	klog.Infof("Received request to unpublish volume: %+v", request)
+	release, err := d.locks.Lock(request.GetVolumeId(), request.GetTargetPath())
+	if err != nil {
+		//nolint:wrapcheck // error is already a gRPC status; wrapping would lose the status code
+		return nil, err
+	}
+	defer release()
 
 	targetPath := request.GetTargetPath()
-	err := mount.CleanupMountPoint(targetPath, d.Mounter, false)
+	_, span := tracing.Start(ctx, "CleanupMountPoint", tracing.TargetPathKey.String(targetPath))
+	err = node.CleanupMountPoint(ctx, d.Mounter, targetPath, d.OperationTimeout)
+	tracing.End(span, err)
 	if err != nil {
-		klog.Errorf("failed to cleanup mount point for volume %s: %s", request.GetVolumeId(), err.Error())
+		klog.ErrorS(err, "Failed to cleanup mount point",
+			common.LogKeyVolumeID, request.GetVolumeId(),
+			common.LogKeyTargetPath, targetPath)
+		d.Events.VolumeEventf(request.GetVolumeId(), corev1.EventTypeWarning, events.ReasonUnmountFailed,
+			"failed to unpublish volume on node %s: %s", d.HostInstance.Name, err)
+		d.Events.NodeEventf(corev1.EventTypeWarning, events.ReasonUnmountFailed,
+			"failed to unpublish volume %s: %s", request.GetVolumeId(), err)
 
-		return nil, status.Errorf(codes.Internal, "failed to cleanup mount point for volume %s: %s",
+		return nil, status.Errorf(node.OperationErrorCode(err), "failed to cleanup mount point for volume %s: %s",
 			request.GetVolumeId(), err.Error())
 	}
 
-	klog.Infof("Successfully unpublished volume: %s", request.GetVolumeId())
+	klog.InfoS("Successfully unpublished volume",
+		common.LogKeyVolumeID, request.GetVolumeId(),
+		common.LogKeyTargetPath, targetPath)
 
 	return &csi.NodeUnpublishVolumeResponse{}, nil
 }
 
-func (d *Node) NodeGetVolumeStats(_ context.Context, req *csi.NodeGetVolumeStatsRequest) (
+func (d *Node) NodeGetVolumeStats(ctx context.Context, req *csi.NodeGetVolumeStatsRequest) (
 	*csi.NodeGetVolumeStatsResponse,
 	error,
 ) {
-	//nolint:wrapcheck // error is already a gRPC status; wrapping would lose the status code
-	return node.GetVolumeStats(req)
+	var expected node.ExpectedVolume
+
+	info, err := d.lookupVolume(ctx, req.GetVolumeId())
+	if err != nil {
+		// The remaining checks do not depend on the disk, an API outage should not mark volumes abnormal
+		klog.V(4).InfoS("Skipping device checks, failed to look up volume",
+			common.LogKeyVolumeID, req.GetVolumeId(), "err", err)
+	} else {
+		// Nodes without the udev rules have no by-id link, check the device found in sysfs instead
+		expected.DevicePath = getSSDDevicePath(info.serialNumber)
+		if devicePath, findErr := d.DeviceResolver.Find(info.serialNumber); findErr == nil {
+			expected.DevicePath = devicePath
+		}
+		expected.SizeBytes = info.sizeBytes
+	}
+
+	// Encrypted volumes are mounted from their mapped device, which is smaller than the disk by the LUKS header
+	if d.LUKS.IsOpen(req.GetVolumeId()) {
+		expected.DevicePath = d.LUKS.MappedDevicePath(req.GetVolumeId())
+		expected.SizeBytes = 0
+	}
+
+	resp, err := d.VolumeHealth.GetVolumeStatsWithCondition(req, expected)
+	if err != nil {
+		//nolint:wrapcheck // error is already a gRPC status; wrapping would lose the status code
+		return nil, err
+	}
+
+	d.applyFsckOutcome(req.GetVolumeId(), resp)
+
+	return resp, nil
 }
 
-// NodeExpandVolume This function is currently unused.
-// common.DiskTypeFS disks do not require expansion on the node.
-// common.DiskTypeSSD disks would require expansion on the node if they supported online expansion.
-func (d *Node) NodeExpandVolume(_ context.Context, _ *csi.NodeExpandVolumeRequest) (
+// NodeExpandVolume grows the filesystem of a volume which was expanded while attached.
+// It is only called if online expansion is enabled, otherwise the filesystem is grown when the volume is staged.
+func (d *Node) NodeExpandVolume(ctx context.Context, request *csi.NodeExpandVolumeRequest) (
 	*csi.NodeExpandVolumeResponse,
 	error,
 ) {
-	klog.Errorf("%s: NodeExpandVolume", common.ErrNotImplemented)
+	if !d.OnlineExpansion {
+		klog.ErrorS(common.ErrNotImplemented, "NodeExpandVolume")
+
+		return nil, status.Errorf(codes.Unimplemented, "%s: NodeExpandVolume", common.ErrNotImplemented)
+	}
+
+	release, err := d.locks.Lock(request.GetVolumeId(), request.GetVolumePath())
+	if err != nil {
+		//nolint:wrapcheck // error is already a gRPC status; wrapping would lose the status code
+		return nil, err
+	}
+	defer release()
 
-	return nil, status.Errorf(codes.Unimplemented, "%s: NodeExpandVolume", common.ErrNotImplemented)
+	return nodeExpandVolume(ctx, d, request)
 }
 
 func (d *Node) NodeGetCapabilities(_ context.Context, _ *csi.NodeGetCapabilitiesRequest) (
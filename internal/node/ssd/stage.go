@@ -0,0 +1,281 @@
+package ssd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/common"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/node"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/tracing"
+	"k8s.io/mount-utils"
+)
+
+// nodeStageVolume mounts the device once per node at the staging target path.
+// Every pod's target path is later bind mounted from the staging target path.
+// Encrypted devices are opened first and their mapped device is mounted instead.
+// It returns the outcome of the filesystem check, if one was run.
+//
+//nolint:cyclop // sequential checks, splitting them up would not make this clearer
+func nodeStageVolume(
+	ctx context.Context,
+	mounter *mount.SafeFormatAndMount,
+	resizer *mount.ResizeFs,
+	resolver *DeviceResolver,
+	luks *LUKS,
+	operations *node.DeviceOperations,
+	timeout time.Duration,
+	request *csi.NodeStageVolumeRequest,
+) (FsckOutcome, error) {
+	encrypted, err := common.IsEncrypted(request.GetVolumeContext())
+	if err != nil {
+		return FsckSkipped, err
+	}
+
+	// Block volumes are bind mounted directly from the device, there is nothing to stage unless they are encrypted
+	if request.GetVolumeCapability().GetBlock() != nil && !encrypted {
+		return FsckSkipped, nil
+	}
+
+	if request.GetVolumeCapability().GetBlock() == nil && request.GetVolumeCapability().GetMount() == nil {
+		return FsckSkipped, fmt.Errorf("%w: %s", node.ErrUnexpectedVolumeCapability, request.GetVolumeCapability())
+	}
+
+	serialNumber, ok := request.GetVolumeContext()[common.VolumeContextDiskSerialNumberKey]
+	if !ok {
+		return FsckSkipped, node.ErrVolumeMissingSerialNumber
+	}
+
+	devicePath, err := resolver.Resolve(ctx, serialNumber)
+	if err != nil {
+		return FsckSkipped, err
+	}
+
+	formatPolicy, err := common.VolumeFormatPolicy(request.GetVolumeContext())
+	if err != nil {
+		return FsckSkipped, err
+	}
+
+	// The data of an encrypted volume follows the LUKS header on the disk, it tells whether the volume was written to
+	data := VolumeData{DevicePath: devicePath}
+	if encrypted {
+		data.Offset = LUKSDataOffset
+
+		_, span := tracing.Start(ctx, "OpenEncryptedDevice", tracing.DevicePathKey.String(devicePath))
+		devicePath, err = luks.Open(ctx, devicePath, request.GetVolumeId(), request.GetSecrets(), formatPolicy)
+		tracing.End(span, err)
+		if err != nil {
+			return FsckSkipped, err
+		}
+	}
+
+	// Encrypted block volumes are published from the mapped device
+	if request.GetVolumeCapability().GetBlock() != nil {
+		return FsckSkipped, nil
+	}
+
+	if repairErr := node.RepairCorruptedMount(ctx, mounter, request.GetStagingTargetPath(), timeout); repairErr != nil {
+		return FsckSkipped, repairErr
+	}
+
+	alreadyMounted, checkErr := node.VerifyMountedVolumeWithUtils(ctx, mounter, request.GetStagingTargetPath(),
+		devicePath, timeout)
+	if checkErr != nil {
+		return FsckSkipped, fmt.Errorf("failed to verify if volume is already staged: %w", checkErr)
+	}
+
+	if alreadyMounted {
+		return FsckSkipped, nil
+	}
+
+	formatOpts, err := common.MkfsOptions(request.GetVolumeCapability().GetMount().GetFsType(),
+		request.GetVolumeContext())
+	if err != nil {
+		return FsckSkipped, err
+	}
+
+	fsckPolicy, err := common.ParseFsckPolicy(request.GetVolumeContext())
+	if err != nil {
+		return FsckSkipped, err
+	}
+
+	var mountOpts []string
+	if node.IsReadOnlyAccessMode(request.GetVolumeCapability()) {
+		// Read-only volumes cannot be written to in any way
+		// We should not attempt to replay the journal
+		mountOpts = append(mountOpts, node.ReadOnlyMountOption, node.NoLoadMountOption)
+	}
+
+	outcome, err := (&StageFilesystem{
+		DevicePath:    devicePath,
+		Mounter:       mounter,
+		Resizer:       resizer,
+		MountOpts:     mountOpts,
+		FormatOptions: formatOpts,
+		Data:          data,
+		FormatPolicy:  formatPolicy,
+		FsckPolicy:    fsckPolicy,
+		Request:       request,
+		Operations:    operations,
+		Timeout:       timeout,
+	}).Stage(ctx)
+
+	return outcome, err
+}
+
+type StageFilesystem struct {
+	Mounter    *mount.SafeFormatAndMount
+	Resizer    *mount.ResizeFs
+	Request    *csi.NodeStageVolumeRequest
+	DevicePath string
+	MountOpts  []string
+	// FormatOptions are passed to mkfs if the device has no filesystem yet
+	FormatOptions []string
+	// FormatPolicy selects whether a blank device is formatted, see checkBlankFormat.
+	// Devices holding anything but a filesystem are never formatted.
+	FormatPolicy common.FormatPolicy
+	// Data locates the data of the volume on its disk, it defaults to DevicePath
+	Data VolumeData
+	// FsckPolicy selects how an existing filesystem is checked before it is mounted
+	FsckPolicy common.FsckPolicy
+	// Operations keeps a format, mount or resize which timed out from overlapping the retry of the stage
+	Operations *node.DeviceOperations
+	// Timeout bounds each of the format, mount and resize, zero leaves only the RPC deadline
+	Timeout time.Duration
+}
+
+// Stage mounts the device at the staging target path, formatting it if it is blank,
+// and returns the outcome of the filesystem check.
+//
+//nolint:cyclop // sequential steps, each with its own error
+func (s *StageFilesystem) Stage(ctx context.Context) (FsckOutcome, error) {
+	stagingPath := s.Request.GetStagingTargetPath()
+
+	// os.MkdirAll will be a noop if the directory already exists
+	mkDirErr := os.MkdirAll(stagingPath, node.NewDirPerms)
+	if mkDirErr != nil {
+		return FsckSkipped, fmt.Errorf("failed to make directory for staging target path: %w", mkDirErr)
+	}
+
+	s.MountOpts = append(s.MountOpts, s.Request.GetVolumeCapability().GetMount().GetMountFlags()...)
+
+	data := s.Data
+	if data.DevicePath == "" {
+		data.DevicePath = s.DevicePath
+	}
+
+	existingFsType, err := checkFormat(s.Mounter.Exec, s.DevicePath, data, s.FormatPolicy)
+	if err != nil {
+		return FsckSkipped, err
+	}
+
+	outcome := FsckSkipped
+	if existingFsType != "" && s.FsckPolicy != common.FsckPolicyDefault {
+		// The mounter runs fsck -a on existing filesystems, which would override the policy
+		outcome, err = s.checkFilesystem(ctx, existingFsType)
+		if err != nil {
+			return outcome, err
+		}
+
+		err = s.mount(ctx, stagingPath)
+	} else {
+		err = s.formatAndMount(ctx, stagingPath)
+	}
+
+	if err != nil {
+		return outcome, fmt.Errorf("%w at staging target path %s: %w", node.ErrFailedMount, stagingPath, err)
+	}
+
+	// Resize the filesystem to span the entire disk
+	// The size of the underlying disk may have changed due to volume expansion (offline)
+	_, resizeSpan := tracing.Start(ctx, "ResizeFs",
+		tracing.DevicePathKey.String(s.DevicePath),
+		tracing.TargetPathKey.String(stagingPath))
+	var ok bool
+	err = s.Operations.Run(ctx, s.Timeout, s.DevicePath, "resize", func() error {
+		var resizeErr error
+		ok, resizeErr = s.Resizer.Resize(s.DevicePath, stagingPath)
+
+		return resizeErr
+	})
+	tracing.End(resizeSpan, err)
+	if err != nil {
+		return outcome, fmt.Errorf("%w at staging target path %s: %w", node.ErrFailedResize, stagingPath, err)
+	}
+
+	if !ok {
+		return outcome, fmt.Errorf("%w: %s", node.ErrFailedResize, stagingPath)
+	}
+
+	// The filesystem root is owned by root after formatting, hand it to the pod's fsGroup
+	if !node.IsReadOnlyAccessMode(s.Request.GetVolumeCapability()) {
+		group := node.VolumeMountGroup(s.Request.GetVolumeCapability())
+		if err = node.ApplyVolumeMountGroup(stagingPath, group); err != nil {
+			return outcome, fmt.Errorf("failed to apply volume mount group at staging target path %s: %w", stagingPath, err)
+		}
+	}
+
+	return outcome, nil
+}
+
+// checkFilesystem runs the check of the fsck policy on the existing filesystem of type fsType.
+// Read-only volumes are only checked, as repairing them would write to the device.
+func (s *StageFilesystem) checkFilesystem(ctx context.Context, fsType string) (FsckOutcome, error) {
+	policy := s.FsckPolicy
+	if policy == common.FsckPolicyRepair && node.IsReadOnlyAccessMode(s.Request.GetVolumeCapability()) {
+		policy = common.FsckPolicyCheck
+	}
+
+	_, span := tracing.Start(ctx, "CheckFilesystem",
+		tracing.DevicePathKey.String(s.DevicePath),
+		tracing.FilesystemKey.String(fsType))
+	outcome, err := (&FilesystemCheck{
+		Exec:       s.Mounter.Exec,
+		DevicePath: s.DevicePath,
+		FsType:     fsType,
+		Policy:     policy,
+		Timeout:    s.Timeout,
+	}).Run(ctx)
+	tracing.End(span, err)
+
+	return outcome, err
+}
+
+// mount mounts the existing filesystem without the checks of the mounter.
+func (s *StageFilesystem) mount(ctx context.Context, stagingPath string) error {
+	_, span := tracing.Start(ctx, "Mount",
+		tracing.DevicePathKey.String(s.DevicePath),
+		tracing.TargetPathKey.String(stagingPath),
+		tracing.FilesystemKey.String(s.Request.GetVolumeCapability().GetMount().GetFsType()))
+	err := s.Operations.Run(ctx, s.Timeout, s.DevicePath, "mount", func() error {
+		return s.Mounter.MountSensitive(s.DevicePath,
+			stagingPath,
+			s.Request.GetVolumeCapability().GetMount().GetFsType(),
+			s.MountOpts,
+			nil)
+	})
+	tracing.End(span, err)
+
+	return err
+}
+
+// formatAndMount formats the device if it is blank and mounts it.
+func (s *StageFilesystem) formatAndMount(ctx context.Context, stagingPath string) error {
+	_, span := tracing.Start(ctx, "FormatAndMount",
+		tracing.DevicePathKey.String(s.DevicePath),
+		tracing.TargetPathKey.String(stagingPath),
+		tracing.FilesystemKey.String(s.Request.GetVolumeCapability().GetMount().GetFsType()))
+	err := s.Operations.Run(ctx, s.Timeout, s.DevicePath, "format and mount", func() error {
+		return s.Mounter.FormatAndMountSensitiveWithFormatOptions(s.DevicePath,
+			stagingPath,
+			s.Request.GetVolumeCapability().GetMount().GetFsType(),
+			s.MountOpts,
+			nil,
+			s.FormatOptions)
+	})
+	tracing.End(span, err)
+
+	return err
+}
@@ -0,0 +1,426 @@
+package ssd_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/common"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/node"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/node/ssd"
+	"k8s.io/mount-utils"
+	"k8s.io/utils/exec"
+	testingexec "k8s.io/utils/exec/testing"
+)
+
+const (
+	blkidExt4Output      = "DEVNAME=/dev/vdb\nTYPE=ext4\n"
+	blkidExt4ProbeOutput = "DEVNAME=/dev/vdb\nTYPE=ext4\nUSAGE=filesystem\n"
+)
+
+// fakeCommand returns an exec action which prints output and exits with err.
+// The command line is appended to argv, if set.
+func fakeCommand(output string, err error, argv *[][]string) testingexec.FakeCommandAction {
+	return func(cmd string, args ...string) exec.Cmd {
+		if argv != nil {
+			*argv = append(*argv, append([]string{cmd}, args...))
+		}
+
+		fakeCmd := &testingexec.FakeCmd{
+			CombinedOutputScript: []testingexec.FakeAction{
+				func() ([]byte, []byte, error) { return []byte(output), nil, err },
+			},
+		}
+
+		return testingexec.InitFakeCmd(fakeCmd, cmd, args...)
+	}
+}
+
+// existingExt4Script probes the device, runs blkid and fsck before mounting an ext4 filesystem,
+// then blkid and resize2fs to grow it.
+func existingExt4Script() []testingexec.FakeCommandAction {
+	return []testingexec.FakeCommandAction{
+		fakeCommand(blkidExt4ProbeOutput, nil, nil),
+		fakeCommand(blkidExt4Output, nil, nil),
+		fakeCommand("", nil, nil),
+		fakeCommand(blkidExt4Output, nil, nil),
+		fakeCommand("", nil, nil),
+	}
+}
+
+// newStageFilesystem returns a StageFilesystem whose mkfs, fsck and resize commands run script.
+func newStageFilesystem(t *testing.T,
+	capability *csi.VolumeCapability,
+	script []testingexec.FakeCommandAction,
+) (*ssd.StageFilesystem, *mount.FakeMounter) {
+	t.Helper()
+
+	fakeMounter := mount.NewFakeMounter(nil)
+	fakeExec := &testingexec.FakeExec{CommandScript: script}
+
+	return &ssd.StageFilesystem{
+		Mounter: &mount.SafeFormatAndMount{Interface: fakeMounter, Exec: fakeExec},
+		Resizer: mount.NewResizeFs(fakeExec),
+		Request: &csi.NodeStageVolumeRequest{
+			VolumeId:          "test-volume-id",
+			StagingTargetPath: t.TempDir(),
+			VolumeCapability:  capability,
+		},
+		DevicePath: "/dev/vdb",
+		Operations: &node.DeviceOperations{},
+	}, fakeMounter
+}
+
+func TestStageFilesystem_Stage_AppliesVolumeMountGroup(t *testing.T) {
+	t.Parallel()
+
+	gid := os.Getgid()
+	stager, fakeMounter := newStageFilesystem(t, &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{
+			Mount: &csi.VolumeCapability_MountVolume{FsType: "ext4", VolumeMountGroup: strconv.Itoa(gid)},
+		},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+	}, existingExt4Script())
+
+	if _, err := stager.Stage(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(fakeMounter.GetLog()) != 1 {
+		t.Fatalf("expected the device to be mounted once, got %v", fakeMounter.GetLog())
+	}
+
+	fi, err := os.Stat(stager.Request.GetStagingTargetPath())
+	if err != nil {
+		t.Fatalf("failed to stat staging path: %v", err)
+	}
+
+	if fi.Mode()&os.ModeSetgid == 0 || fi.Mode().Perm()&0o070 != 0o070 {
+		t.Errorf("expected group rwx and setgid on the volume root, got %s", fi.Mode())
+	}
+
+	//nolint:forcetypeassert // Sys is always a Stat_t on linux
+	if stat := fi.Sys().(*syscall.Stat_t); int(stat.Gid) != gid {
+		t.Errorf("expected volume root to be owned by group %d, got %d", gid, stat.Gid)
+	}
+}
+
+func TestStageFilesystem_Stage_NoVolumeMountGroup(t *testing.T) {
+	t.Parallel()
+
+	stager, _ := newStageFilesystem(t, &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{
+			Mount: &csi.VolumeCapability_MountVolume{FsType: "ext4"},
+		},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+	}, existingExt4Script())
+
+	if _, err := stager.Stage(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fi, err := os.Stat(stager.Request.GetStagingTargetPath())
+	if err != nil {
+		t.Fatalf("failed to stat staging path: %v", err)
+	}
+
+	if fi.Mode()&os.ModeSetgid != 0 {
+		t.Errorf("expected permissions to be unchanged without a volume mount group, got %s", fi.Mode())
+	}
+}
+
+func TestStageFilesystem_Stage_FormatOptions(t *testing.T) {
+	t.Parallel()
+
+	// blkid exits 2 for a blank device, which is then formatted before mounting
+	var argv [][]string
+	stager, _ := newStageFilesystem(t, &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{
+			Mount: &csi.VolumeCapability_MountVolume{FsType: "xfs"},
+		},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+	}, []testingexec.FakeCommandAction{
+		fakeCommand("", testingexec.FakeExitError{Status: 2}, &argv),
+		fakeCommand("", testingexec.FakeExitError{Status: 2}, &argv),
+		fakeCommand("", nil, &argv),
+		fakeCommand("DEVNAME=/dev/vdb\nTYPE=xfs\n", nil, &argv),
+		fakeCommand("", nil, &argv),
+	})
+	stager.FormatOptions = []string{"-m", "reflink=1", "-L", "scratch"}
+	stager.FormatPolicy = common.FormatForce
+
+	if _, err := stager.Stage(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"mkfs.xfs", "-m", "reflink=1", "-L", "scratch", "-f", "/dev/vdb"}
+	if len(argv) < 3 || !slices.Equal(argv[2], want) {
+		t.Errorf("expected mkfs command %v, got %v", want, argv)
+	}
+}
+
+// writeDevice creates a blank device of size bytes, with a byte of data at each of offsets.
+func writeDevice(t *testing.T, size int64, offsets ...int64) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "device")
+	device, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("failed to create device: %v", err)
+	}
+	defer device.Close()
+
+	if err = device.Truncate(size); err != nil {
+		t.Fatalf("failed to size device: %v", err)
+	}
+
+	for _, offset := range offsets {
+		if _, err = device.WriteAt([]byte{1}, offset); err != nil {
+			t.Fatalf("failed to write device: %v", err)
+		}
+	}
+
+	return path
+}
+
+func TestStageFilesystem_Stage_FormatsUnwrittenDevice(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		data func(t *testing.T) ssd.VolumeData
+	}{
+		{
+			name: "blank disk",
+			data: func(t *testing.T) ssd.VolumeData {
+				t.Helper()
+
+				return ssd.VolumeData{DevicePath: writeDevice(t, 3*1024*1024)}
+			},
+		},
+		{
+			name: "disk smaller than the checked range",
+			data: func(t *testing.T) ssd.VolumeData {
+				t.Helper()
+
+				return ssd.VolumeData{DevicePath: writeDevice(t, 4096)}
+			},
+		},
+		{
+			name: "encrypted disk with only its LUKS header written",
+			data: func(t *testing.T) ssd.VolumeData {
+				t.Helper()
+
+				return ssd.VolumeData{
+					DevicePath: writeDevice(t, ssd.LUKSDataOffset+3*1024*1024, 0, ssd.LUKSDataOffset-1),
+					Offset:     ssd.LUKSDataOffset,
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var argv [][]string
+			stager, fakeMounter := newStageFilesystem(t, &csi.VolumeCapability{
+				AccessType: &csi.VolumeCapability_Mount{
+					Mount: &csi.VolumeCapability_MountVolume{FsType: "ext4"},
+				},
+				AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+			}, []testingexec.FakeCommandAction{
+				fakeCommand("", testingexec.FakeExitError{Status: 2}, &argv),
+				fakeCommand("", testingexec.FakeExitError{Status: 2}, &argv),
+				fakeCommand("", nil, &argv),
+				fakeCommand(blkidExt4Output, nil, &argv),
+				fakeCommand("", nil, &argv),
+			})
+			stager.FormatPolicy = common.FormatUnwritten
+			stager.Data = tt.data(t)
+
+			if _, err := stager.Stage(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(argv) < 3 || argv[2][0] != "mkfs.ext4" {
+				t.Errorf("expected the device to be formatted, ran %v", argv)
+			}
+
+			if log := fakeMounter.GetLog(); len(log) != 1 {
+				t.Errorf("expected the device to be mounted once, got %v", log)
+			}
+		})
+	}
+}
+
+func TestStageFilesystem_Stage_TimedOutFormatStillRunning(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{})
+	hung := make(chan struct{})
+	hungMkfs := func(cmd string, args ...string) exec.Cmd {
+		fakeCmd := &testingexec.FakeCmd{
+			CombinedOutputScript: []testingexec.FakeAction{
+				func() ([]byte, []byte, error) {
+					close(started)
+					<-hung
+
+					return nil, nil, nil
+				},
+			},
+		}
+
+		return testingexec.InitFakeCmd(fakeCmd, cmd, args...)
+	}
+
+	// The second stage only gets as far as probing the device
+	var argv [][]string
+	stager, fakeMounter := newStageFilesystem(t, &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{
+			Mount: &csi.VolumeCapability_MountVolume{FsType: "ext4"},
+		},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+	}, []testingexec.FakeCommandAction{
+		fakeCommand("", testingexec.FakeExitError{Status: 2}, &argv),
+		fakeCommand("", testingexec.FakeExitError{Status: 2}, &argv),
+		hungMkfs,
+		fakeCommand("", testingexec.FakeExitError{Status: 2}, &argv),
+	})
+	stager.FormatPolicy = common.FormatForce
+	stager.Timeout = 10 * time.Millisecond
+
+	_, err := stager.Stage(context.Background())
+	if !errors.Is(err, node.ErrOperationTimeout) {
+		t.Fatalf("expected error %v, got %v", node.ErrOperationTimeout, err)
+	}
+
+	<-started
+
+	_, err = stager.Stage(context.Background())
+	if !errors.Is(err, node.ErrOperationInFlight) {
+		t.Fatalf("expected error %v while mkfs is still running, got %v", node.ErrOperationInFlight, err)
+	}
+
+	if len(argv) != 3 {
+		t.Errorf("expected the retry to stop after probing the device, ran %v", argv)
+	}
+
+	close(hung)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(fakeMounter.GetLog()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if log := fakeMounter.GetLog(); len(log) != 1 {
+		t.Errorf("expected the timed out format to mount once it returned, got %v", log)
+	}
+}
+
+func TestStageFilesystem_Stage_RefusesFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		probeOutput  string
+		probeErr     error
+		formatPolicy common.FormatPolicy
+	}{
+		{
+			name:     "blank device of a volume not provisioned by the driver",
+			probeErr: testingexec.FakeExitError{Status: 2},
+		},
+		{
+			name:         "blank device which was written to before",
+			probeErr:     testingexec.FakeExitError{Status: 2},
+			formatPolicy: common.FormatUnwritten,
+		},
+		{
+			name:         "partition table",
+			probeOutput:  "DEVNAME=/dev/vdb\nPTTYPE=gpt\n",
+			formatPolicy: common.FormatForce,
+		},
+		{
+			name:         "LUKS header",
+			probeOutput:  "DEVNAME=/dev/vdb\nTYPE=crypto_LUKS\nUSAGE=crypto\n",
+			formatPolicy: common.FormatForce,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var argv [][]string
+			stager, fakeMounter := newStageFilesystem(t, &csi.VolumeCapability{
+				AccessType: &csi.VolumeCapability_Mount{
+					Mount: &csi.VolumeCapability_MountVolume{FsType: "ext4"},
+				},
+				AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+			}, []testingexec.FakeCommandAction{
+				fakeCommand(tt.probeOutput, tt.probeErr, &argv),
+			})
+			stager.FormatPolicy = tt.formatPolicy
+			stager.Data = ssd.VolumeData{DevicePath: writeDevice(t, 3*1024*1024, 2*1024*1024)}
+
+			_, err := stager.Stage(context.Background())
+			if !errors.Is(err, node.ErrFormatRefused) {
+				t.Fatalf("expected error %v, got %v", node.ErrFormatRefused, err)
+			}
+
+			if len(argv) != 1 {
+				t.Errorf("expected only the probe to run, ran %v", argv)
+			}
+
+			if log := fakeMounter.GetLog(); len(log) != 0 {
+				t.Errorf("expected nothing to be mounted, got %v", log)
+			}
+		})
+	}
+}
+
+func TestStageFilesystem_Stage_FsckPolicyCheck(t *testing.T) {
+	t.Parallel()
+
+	// The mounter's fsck -a would repair the filesystem, so it is mounted without it
+	var argv [][]string
+	stager, fakeMounter := newStageFilesystem(t, &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{
+			Mount: &csi.VolumeCapability_MountVolume{FsType: "ext4"},
+		},
+		AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER},
+	}, []testingexec.FakeCommandAction{
+		fakeCommand(blkidExt4ProbeOutput, nil, &argv),
+		fakeCommand("", testingexec.FakeExitError{Status: 4}, &argv),
+		fakeCommand(blkidExt4Output, nil, &argv),
+		fakeCommand("", nil, &argv),
+	})
+	stager.FsckPolicy = common.FsckPolicyCheck
+
+	outcome, err := stager.Stage(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if outcome != ssd.FsckErrorsFound {
+		t.Errorf("expected outcome %q, got %q", ssd.FsckErrorsFound, outcome)
+	}
+
+	for _, command := range argv {
+		if command[0] == "fsck" {
+			t.Errorf("expected the mounter not to run fsck, ran %v", command)
+		}
+	}
+
+	if log := fakeMounter.GetLog(); len(log) != 1 || log[0].Action != mount.FakeActionMount {
+		t.Errorf("expected the volume to be mounted, got %v", log)
+	}
+}
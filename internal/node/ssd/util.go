@@ -2,57 +2,189 @@ package ssd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
-	"github.com/crusoecloud/crusoe-csi-driver/internal/crusoe"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/common"
 	"github.com/crusoecloud/crusoe-csi-driver/internal/node"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/tracing"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"k8s.io/klog/v2"
 )
 
+const rescanPerms = 0o200 // this represents: -w-------
+
+var errDeviceNotGrown = errors.New("device has not grown to the requested size yet")
+
+// nodeErrorCode returns codes.Unavailable for devices which have not appeared or cannot be
+// told apart, so the CO retries instead of mounting a non-existent path, codes.InvalidArgument
+// for filesystem parameters which cannot be applied and passphrases which are missing or wrong,
+// codes.FailedPrecondition for devices in an unexpected state, and node.OperationErrorCode otherwise.
+func nodeErrorCode(err error) codes.Code {
+	if errors.Is(err, ErrDeviceNotFound) || errors.Is(err, ErrDeviceAmbiguous) {
+		return codes.Unavailable
+	}
+
+	if errors.Is(err, common.ErrUnsupportedMkfsParameter) || errors.Is(err, common.ErrInvalidMkfsParameter) {
+		return codes.InvalidArgument
+	}
+
+	if errors.Is(err, ErrPassphraseMissing) || errors.Is(err, ErrPassphraseRejected) ||
+		errors.Is(err, common.ErrInvalidEncryptionParameter) {
+		return codes.InvalidArgument
+	}
+
+	if errors.Is(err, ErrUnencryptedData) || errors.Is(err, ErrEncryptedVolumeNotOpen) ||
+		errors.Is(err, node.ErrFormatRefused) {
+		return codes.FailedPrecondition
+	}
+
+	return node.OperationErrorCode(err)
+}
+
 func getSSDDevicePath(serialNumber string) string {
 	// symlink: /dev/disk/by-id/virtio-<serial-number>
 	return fmt.Sprintf("/dev/disk/by-id/virtio-%s", serialNumber)
 }
 
-// NodeExpandVolume This function is currently unused.
-// common.DiskTypeFS disks do not require expansion on the node.
-// common.DiskTypeSSD disks would require expansion on the node if they supported online expansion.
-//nolint
+// Rescan asks the kernel to re-read the capacity of the device behind devicePath and returns its size in bytes.
+// virtio-blk devices pick up capacity changes on their own, SCSI devices expose a rescan trigger.
+func (r *DeviceResolver) Rescan(devicePath string) (int64, error) {
+	resolvedPath, err := filepath.EvalSymlinks(devicePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve device path %s: %w", devicePath, err)
+	}
+
+	deviceSysfsPath := filepath.Join(r.sysBlockPath(), filepath.Base(resolvedPath))
+
+	rescanPath := filepath.Join(deviceSysfsPath, "device", "rescan")
+	if _, statErr := os.Stat(rescanPath); statErr == nil {
+		if writeErr := os.WriteFile(rescanPath, []byte("1"), rescanPerms); writeErr != nil {
+			return 0, fmt.Errorf("failed to rescan device %s: %w", resolvedPath, writeErr)
+		}
+	}
+
+	sizeContents, err := os.ReadFile(filepath.Join(deviceSysfsPath, "size"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read size of device %s: %w", resolvedPath, err)
+	}
+
+	sectors, err := strconv.ParseInt(strings.TrimSpace(string(sizeContents)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse size of device %s: %w", resolvedPath, err)
+	}
+
+	return sectors * node.SysfsSectorSize, nil
+}
+
+//nolint:cyclop // function is already fairly clean
 func nodeExpandVolume(ctx context.Context, d *Node, request *csi.NodeExpandVolumeRequest) (
 	*csi.NodeExpandVolumeResponse,
 	error,
 ) {
-	// Note that this function will only be called for diskType == common.DiskTypeSSD
-	// because FS disks do not require expansion on the node
+	if request.GetVolumeId() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid expand request: %s", node.ErrVolumeIDEmpty)
+	}
 
-	// Block devices do not require expansion on the node
-	if request.GetVolumeCapability().GetBlock() != nil {
-		return &csi.NodeExpandVolumeResponse{}, nil
+	if request.GetVolumePath() == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid expand request: %s", node.ErrVolumePathEmpty)
 	}
 
-	// Fetch disk's serial number because NodeExpandVolumeRequest does not include the volume context :(
-	disk, err := crusoe.FindDiskByIDFallible(ctx, d.CrusoeClient, d.HostInstance.ProjectId, request.GetVolumeId())
+	// NodeExpandVolumeRequest does not include the volume context :(
+	info, err := d.lookupVolume(ctx, request.GetVolumeId())
 	if err != nil {
-		klog.Errorf("failed to find disk %s: %s", request.GetVolumeId(), err)
+		klog.ErrorS(err, "Failed to find disk", common.LogKeyVolumeID, request.GetVolumeId())
 
 		return nil, status.Errorf(codes.NotFound, "failed to find disk %s: %s", request.GetVolumeId(), err)
 	}
-	devicePath := getSSDDevicePath(disk.SerialNumber)
 
-	ok, err := d.Resizer.Resize(devicePath, request.GetVolumePath())
+	devicePath, err := d.DeviceResolver.Resolve(ctx, info.serialNumber)
 	if err != nil {
-		return nil, fmt.Errorf("failed to resize %s: %w", request.GetVolumePath(), err)
+		klog.ErrorS(err, "Failed to resolve device", common.LogKeyVolumeID, request.GetVolumeId())
+
+		return nil, status.Errorf(nodeErrorCode(err), "failed to resolve device for volume %s: %s",
+			request.GetVolumeId(), err)
+	}
+
+	deviceSizeBytes, err := d.DeviceResolver.Rescan(devicePath)
+	if err != nil {
+		klog.ErrorS(err, "Failed to rescan device",
+			common.LogKeyVolumeID, request.GetVolumeId(),
+			common.LogKeyDevicePath, devicePath)
+
+		return nil, status.Errorf(codes.Internal, "failed to rescan device for volume %s: %s",
+			request.GetVolumeId(), err)
+	}
+
+	// The hypervisor may not have propagated the new size yet, let the CO retry
+	requiredBytes := request.GetCapacityRange().GetRequiredBytes()
+	if deviceSizeBytes < requiredBytes {
+		klog.InfoS("Device has not grown to the requested size yet",
+			common.LogKeyVolumeID, request.GetVolumeId(),
+			"deviceSizeBytes", deviceSizeBytes,
+			"requiredBytes", requiredBytes)
+
+		return nil, status.Errorf(codes.Unavailable, "%s: volume %s is %d bytes, requested %d bytes",
+			errDeviceNotGrown, request.GetVolumeId(), deviceSizeBytes, requiredBytes)
+	}
+
+	// The mapping of an encrypted volume must grow before the filesystem on it can
+	if d.LUKS.IsOpen(request.GetVolumeId()) {
+		err = d.LUKS.Resize(ctx, request.GetVolumeId(), request.GetSecrets()[common.SecretPassphraseKey])
+		if err != nil {
+			klog.ErrorS(err, "Failed to resize encrypted volume", common.LogKeyVolumeID, request.GetVolumeId())
+
+			return nil, status.Errorf(codes.Internal, "%s for volume %s: %s",
+				node.ErrFailedResize, request.GetVolumeId(), err)
+		}
+
+		devicePath = d.LUKS.MappedDevicePath(request.GetVolumeId())
+	}
+
+	// Block devices do not require expansion on the node
+	if request.GetVolumeCapability().GetBlock() != nil {
+		return &csi.NodeExpandVolumeResponse{CapacityBytes: deviceSizeBytes}, nil
+	}
+
+	_, span := tracing.Start(ctx, "ResizeFs",
+		tracing.DevicePathKey.String(devicePath),
+		tracing.TargetPathKey.String(request.GetVolumePath()))
+	var ok bool
+	err = d.operations.Run(ctx, d.OperationTimeout, devicePath, "resize", func() error {
+		var resizeErr error
+		ok, resizeErr = d.Resizer.Resize(devicePath, request.GetVolumePath())
+
+		return resizeErr
+	})
+	tracing.End(span, err)
+	if err != nil {
+		klog.ErrorS(err, "Failed to resize filesystem",
+			common.LogKeyVolumeID, request.GetVolumeId(),
+			common.LogKeyTargetPath, request.GetVolumePath())
+
+		return nil, status.Errorf(node.OperationErrorCode(err), "%s for volume %s: %s",
+			node.ErrFailedResize, request.GetVolumeId(), err)
 	}
 
 	if !ok {
-		klog.Errorf("%s for volume %s: %s", node.ErrFailedResize, request.GetVolumePath(), request.GetVolumeId())
+		klog.ErrorS(node.ErrFailedResize, "Failed to resize filesystem",
+			common.LogKeyVolumeID, request.GetVolumeId(),
+			common.LogKeyTargetPath, request.GetVolumePath())
 
 		return nil, status.Errorf(codes.Internal, "%s for volume %s: %s",
 			node.ErrFailedResize, request.GetVolumePath(), request.GetVolumeId())
 	}
 
-	return &csi.NodeExpandVolumeResponse{}, nil
+	klog.InfoS("Expanded volume",
+		common.LogKeyVolumeID, request.GetVolumeId(),
+		common.LogKeyTargetPath, request.GetVolumePath(),
+		"sizeBytes", deviceSizeBytes)
+
+	return &csi.NodeExpandVolumeResponse{CapacityBytes: deviceSizeBytes}, nil
 }
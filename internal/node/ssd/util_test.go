@@ -0,0 +1,184 @@
+package ssd_test
+
+import (
+	"context"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/common"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/node/ssd"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/mount-utils"
+	testingexec "k8s.io/utils/exec/testing"
+)
+
+func TestNode_NodeExpandVolume(t *testing.T) {
+	t.Parallel()
+
+	const (
+		gib           = 1024 * 1024 * 1024
+		requiredBytes = 10 * gib
+	)
+
+	mountCapability := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}},
+	}
+	blockCapability := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Block{Block: &csi.VolumeCapability_BlockVolume{}},
+	}
+
+	// paths are the device, the mapped device of an encrypted volume and the volume path of a test case
+	type paths struct {
+		device, mapped, volume string
+	}
+
+	tests := []struct {
+		name       string
+		capability *csi.VolumeCapability
+		deviceSize int64
+		encrypted  bool
+		script     func(argv *[][]string) []testingexec.FakeCommandAction
+		wantArgv   func(p paths) [][]string
+		wantCode   codes.Code
+	}{
+		{
+			name:       "ext4 filesystem",
+			capability: mountCapability,
+			deviceSize: requiredBytes,
+			script: func(argv *[][]string) []testingexec.FakeCommandAction {
+				return []testingexec.FakeCommandAction{
+					fakeCommand(blkidExt4Output, nil, argv),
+					fakeCommand("", nil, argv),
+				}
+			},
+			wantArgv: func(p paths) [][]string {
+				return [][]string{
+					{"blkid", "-p", "-s", "TYPE", "-s", "PTTYPE", "-o", "export", p.device},
+					{"resize2fs", p.device},
+				}
+			},
+		},
+		{
+			name:       "xfs filesystem",
+			capability: mountCapability,
+			deviceSize: 2 * requiredBytes,
+			script: func(argv *[][]string) []testingexec.FakeCommandAction {
+				return []testingexec.FakeCommandAction{
+					fakeCommand("DEVNAME=/dev/vdb\nTYPE=xfs\n", nil, argv),
+					fakeCommand("", nil, argv),
+				}
+			},
+			wantArgv: func(p paths) [][]string {
+				return [][]string{
+					{"blkid", "-p", "-s", "TYPE", "-s", "PTTYPE", "-o", "export", p.device},
+					{"xfs_growfs", "-d", p.volume},
+				}
+			},
+		},
+		{
+			name:       "encrypted filesystem",
+			capability: mountCapability,
+			deviceSize: requiredBytes,
+			encrypted:  true,
+			script: func(argv *[][]string) []testingexec.FakeCommandAction {
+				return []testingexec.FakeCommandAction{
+					fakeCommand("", nil, argv),
+					fakeCommand(blkidExt4Output, nil, argv),
+					fakeCommand("", nil, argv),
+				}
+			},
+			wantArgv: func(p paths) [][]string {
+				return [][]string{
+					{"cryptsetup", "resize", "luks-" + testVolumeID, "--key-file", "-"},
+					{"blkid", "-p", "-s", "TYPE", "-s", "PTTYPE", "-o", "export", p.mapped},
+					{"resize2fs", p.mapped},
+				}
+			},
+		},
+		{
+			name:       "block volume",
+			capability: blockCapability,
+			deviceSize: requiredBytes,
+			wantArgv:   func(paths) [][]string { return nil },
+		},
+		{
+			name:       "device has not grown yet",
+			capability: mountCapability,
+			deviceSize: requiredBytes - gib,
+			wantArgv:   func(paths) [][]string { return nil },
+			wantCode:   codes.Unavailable,
+		},
+		{
+			name:       "resize fails",
+			capability: mountCapability,
+			deviceSize: requiredBytes,
+			script: func(argv *[][]string) []testingexec.FakeCommandAction {
+				return []testingexec.FakeCommandAction{
+					fakeCommand(blkidExt4Output, nil, argv),
+					fakeCommand("", testingexec.FakeExitError{Status: 1}, argv),
+				}
+			},
+			wantArgv: func(p paths) [][]string {
+				return [][]string{
+					{"blkid", "-p", "-s", "TYPE", "-s", "PTTYPE", "-o", "export", p.device},
+					{"resize2fs", p.device},
+				}
+			},
+			wantCode: codes.Internal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			f := newDeviceFixture(t)
+			devicePath := f.addDevice(t, "vdb", testSerial[:20])
+			f.setSize(t, "vdb", tt.deviceSize)
+
+			var argv [][]string
+			var script []testingexec.FakeCommandAction
+			if tt.script != nil {
+				script = tt.script(&argv)
+			}
+
+			fakeExec := &testingexec.FakeExec{CommandScript: script}
+			d := &ssd.Node{
+				OnlineExpansion: true,
+				DeviceResolver:  f.resolver,
+				Resizer:         mount.NewResizeFs(fakeExec),
+				LUKS:            ssd.LUKS{Exec: fakeExec, MapperPath: t.TempDir()},
+			}
+			d.RememberVolume(testVolumeID, map[string]string{common.VolumeContextDiskSerialNumberKey: testSerial})
+
+			p := paths{device: devicePath, mapped: d.LUKS.MappedDevicePath(testVolumeID), volume: t.TempDir()}
+			if tt.encrypted {
+				if err := os.WriteFile(p.mapped, nil, 0o600); err != nil {
+					t.Fatalf("failed to create mapped device: %s", err)
+				}
+			}
+
+			resp, err := d.NodeExpandVolume(context.Background(), &csi.NodeExpandVolumeRequest{
+				VolumeId:         testVolumeID,
+				VolumePath:       p.volume,
+				CapacityRange:    &csi.CapacityRange{RequiredBytes: requiredBytes},
+				VolumeCapability: tt.capability,
+				Secrets:          map[string]string{common.SecretPassphraseKey: "secret"},
+			})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("expected code %s, got %s: %v", tt.wantCode, code, err)
+			}
+
+			if want := tt.wantArgv(p); !slices.EqualFunc(argv, want, slices.Equal) {
+				t.Errorf("expected commands %v, got %v", want, argv)
+			}
+
+			if err == nil && resp.GetCapacityBytes() != tt.deviceSize {
+				t.Errorf("expected capacity %d, got %d", tt.deviceSize, resp.GetCapacityBytes())
+			}
+		})
+	}
+}
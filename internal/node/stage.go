@@ -0,0 +1,40 @@
+package node
+
+import "github.com/container-storage-interface/spec/lib/go/csi"
+
+// ValidateStageRequest checks the fields NodeStageVolume requires are present.
+func ValidateStageRequest(request *csi.NodeStageVolumeRequest) error {
+	switch {
+	case request.GetVolumeId() == "":
+		return ErrVolumeIDEmpty
+	case request.GetStagingTargetPath() == "":
+		return ErrStagingPathEmpty
+	case request.GetVolumeCapability() == nil:
+		return ErrCapabilityEmpty
+	default:
+		return ValidateVolumeMountGroup(request.GetVolumeCapability())
+	}
+}
+
+// ValidateVolumeMountGroup checks the volume mount group, if any, is a numeric group ID.
+func ValidateVolumeMountGroup(capability *csi.VolumeCapability) error {
+	group := VolumeMountGroup(capability)
+	if group == "" {
+		return nil
+	}
+
+	_, err := parseVolumeMountGroup(group)
+
+	return err
+}
+
+// IsReadOnlyAccessMode reports whether the volume may only ever be read from.
+func IsReadOnlyAccessMode(capability *csi.VolumeCapability) bool {
+	switch capability.GetAccessMode().GetMode() {
+	case csi.VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY,
+		csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,152 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/crusoecloud/crusoe-csi-driver/internal/common"
+	"golang.org/x/sys/unix"
+	"google.golang.org/grpc/codes"
+	"k8s.io/klog/v2"
+	"k8s.io/mount-utils"
+)
+
+var ErrOperationTimeout = errors.New("operation did not finish in time")
+
+// RunWithTimeout runs op until it returns, ctx is done or timeout elapses, whichever happens first.
+// A zero timeout leaves only ctx. mount-utils cannot cancel a mount or unmount once started, so
+// an op which times out keeps running in the background; callers must be idempotent on retry.
+func RunWithTimeout(ctx context.Context, timeout time.Duration, name string, op func() error) error {
+	_, err := runWithTimeoutResult(ctx, timeout, name, func() (struct{}, error) {
+		return struct{}{}, op()
+	})
+
+	return err
+}
+
+// runWithTimeoutResult is RunWithTimeout for operations returning a value, such as a stat of a path.
+func runWithTimeoutResult[T any](ctx context.Context, timeout time.Duration, name string, op func() (T, error),
+) (T, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	type result struct {
+		value T
+		err   error
+	}
+
+	results := make(chan result, 1)
+
+	go func() {
+		value, err := op()
+		results <- result{value: value, err: err}
+	}()
+
+	select {
+	case r := <-results:
+		return r.value, r.err
+	case <-ctx.Done():
+		var zero T
+
+		return zero, fmt.Errorf("%w: %s: %w", ErrOperationTimeout, name, ctx.Err())
+	}
+}
+
+// DeviceOperations runs operations on a device which must not overlap, such as format and resize.
+// An operation which times out keeps the device busy until it actually returns, so a retry fails with
+// ErrOperationInFlight rather than starting a second mkfs or resize2fs on the same device.
+// The zero value is ready to use.
+type DeviceOperations struct {
+	mu      sync.Mutex
+	running map[string]string
+}
+
+// Run runs op on device with RunWithTimeout, unless an earlier operation on device is still running.
+func (o *DeviceOperations) Run(ctx context.Context, timeout time.Duration, device, name string, op func() error,
+) error {
+	o.mu.Lock()
+	if running, ok := o.running[device]; ok {
+		o.mu.Unlock()
+
+		return fmt.Errorf("%w: %s of %s has not returned yet", ErrOperationInFlight, running, device)
+	}
+
+	if o.running == nil {
+		o.running = make(map[string]string)
+	}
+
+	o.running[device] = name
+	o.mu.Unlock()
+
+	return RunWithTimeout(ctx, timeout, name, func() error {
+		defer o.done(device)
+
+		return op()
+	})
+}
+
+func (o *DeviceOperations) done(device string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	delete(o.running, device)
+}
+
+// OperationErrorCode returns codes.DeadlineExceeded for operations which timed out,
+// so the CO retries them, codes.Aborted for operations which overlap one still running,
+// and codes.Internal otherwise.
+func OperationErrorCode(err error) codes.Code {
+	if errors.Is(err, ErrOperationTimeout) {
+		return codes.DeadlineExceeded
+	}
+
+	if errors.Is(err, ErrOperationInFlight) {
+		return codes.Aborted
+	}
+
+	return codes.Internal
+}
+
+// CleanupMountPoint unmounts and removes path within timeout. A mount which does not unmount in time,
+// typically an NFS mount of an unreachable server, is force unmounted and lazily detached instead.
+func CleanupMountPoint(ctx context.Context, mounter *mount.SafeFormatAndMount, path string,
+	timeout time.Duration,
+) error {
+	err := RunWithTimeout(ctx, timeout, "unmount", func() error {
+		return mount.CleanupMountPoint(path, mounter, false)
+	})
+	if !errors.Is(err, ErrOperationTimeout) {
+		//nolint:wrapcheck // mount-utils errors already describe the path
+		return err
+	}
+
+	klog.ErrorS(err, "Unmount timed out, detaching lazily", common.LogKeyTargetPath, path)
+
+	if detachErr := detachMount(path); detachErr != nil {
+		return fmt.Errorf("%w, lazy unmount: %w", err, detachErr)
+	}
+
+	if removeErr := os.Remove(path); removeErr != nil && !os.IsNotExist(removeErr) {
+		return fmt.Errorf("failed to remove %s after lazy unmount: %w", path, removeErr)
+	}
+
+	return nil
+}
+
+// detachMount aborts in-flight requests of a network filesystem and detaches path from the mount tree.
+// The filesystem itself is cleaned up by the kernel once it is no longer busy.
+func detachMount(path string) error {
+	// EINVAL means path is no longer a mount point
+	if err := unix.Unmount(path, unix.MNT_FORCE|unix.MNT_DETACH); err != nil && !errors.Is(err, unix.EINVAL) {
+		return fmt.Errorf("failed to detach %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,177 @@
+package node_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/crusoecloud/crusoe-csi-driver/internal/node"
+	"google.golang.org/grpc/codes"
+	"k8s.io/mount-utils"
+)
+
+// hungMounter blocks mount point checks and unmounts until release is closed, like a mount of an unreachable
+// NFS server.
+type hungMounter struct {
+	*mount.FakeMounter
+	release chan struct{}
+}
+
+func (m *hungMounter) IsLikelyNotMountPoint(file string) (bool, error) {
+	<-m.release
+
+	//nolint:wrapcheck // test double
+	return m.FakeMounter.IsLikelyNotMountPoint(file)
+}
+
+func (m *hungMounter) Unmount(target string) error {
+	<-m.release
+
+	//nolint:wrapcheck // test double
+	return m.FakeMounter.Unmount(target)
+}
+
+func newHungMounter(t *testing.T) *mount.SafeFormatAndMount {
+	t.Helper()
+
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+
+	return &mount.SafeFormatAndMount{
+		Interface: &hungMounter{FakeMounter: mount.NewFakeMounter(nil), release: release},
+	}
+}
+
+func TestRunWithTimeout(t *testing.T) {
+	t.Parallel()
+
+	errMount := errors.New("mount failed")
+
+	err := node.RunWithTimeout(context.Background(), time.Second, "mount", func() error { return errMount })
+	if !errors.Is(err, errMount) {
+		t.Errorf("expected the operation's error, got %v", err)
+	}
+
+	if code := node.OperationErrorCode(err); code != codes.Internal {
+		t.Errorf("expected Internal for a failed operation, got %s", code)
+	}
+
+	hung := make(chan struct{})
+	defer close(hung)
+
+	err = node.RunWithTimeout(context.Background(), 10*time.Millisecond, "mount", func() error {
+		<-hung
+
+		return nil
+	})
+	if !errors.Is(err, node.ErrOperationTimeout) {
+		t.Errorf("expected ErrOperationTimeout, got %v", err)
+	}
+
+	if code := node.OperationErrorCode(err); code != codes.DeadlineExceeded {
+		t.Errorf("expected DeadlineExceeded for a hung operation, got %s", code)
+	}
+}
+
+func TestRunWithTimeout_HonorsContext(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	hung := make(chan struct{})
+	defer close(hung)
+
+	// No timeout of its own, the RPC deadline still applies
+	err := node.RunWithTimeout(ctx, 0, "unmount", func() error {
+		<-hung
+
+		return nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the context deadline to apply, got %v", err)
+	}
+}
+
+func TestCleanupMountPoint(t *testing.T) {
+	t.Parallel()
+
+	targetPath := filepath.Join(t.TempDir(), "target")
+	if err := os.Mkdir(targetPath, 0o750); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fakeMounter := mount.NewFakeMounter(nil)
+	mounter := &mount.SafeFormatAndMount{Interface: fakeMounter}
+	if err := mounter.Mount("nfs.example.com:/volumes/vol-1", targetPath, "nfs", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := node.CleanupMountPoint(context.Background(), mounter, targetPath, time.Second); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if mountPoints, _ := fakeMounter.List(); len(mountPoints) != 0 {
+		t.Errorf("expected the target path to be unmounted, got %v", mountPoints)
+	}
+
+	if _, err := os.Stat(targetPath); !os.IsNotExist(err) {
+		t.Errorf("expected the target path to be removed, got %v", err)
+	}
+}
+
+func TestCleanupMountPoint_TimeoutDetachesLazily(t *testing.T) {
+	t.Parallel()
+
+	// The lazy unmount is a real umount2 call, which needs CAP_SYS_ADMIN
+	if os.Geteuid() != 0 {
+		t.Skip("lazy unmount needs root")
+	}
+
+	targetPath := filepath.Join(t.TempDir(), "target")
+	if err := os.Mkdir(targetPath, 0o750); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mounter := newHungMounter(t)
+
+	start := time.Now()
+	if err := node.CleanupMountPoint(context.Background(), mounter, targetPath, 10*time.Millisecond); err != nil {
+		t.Fatalf("expected the hung unmount to be detached lazily, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the cleanup to return once the unmount timed out, took %s", elapsed)
+	}
+
+	if _, err := os.Stat(targetPath); !os.IsNotExist(err) {
+		t.Errorf("expected the target path to be removed after the lazy unmount, got %v", err)
+	}
+}
+
+func TestMountProbes_Timeout(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	timeout := 10 * time.Millisecond
+	mounter := newHungMounter(t)
+	path := t.TempDir()
+
+	_, err := node.IsMountPoint(ctx, mounter, path, timeout)
+	if !errors.Is(err, node.ErrOperationTimeout) {
+		t.Errorf("expected the mount point check to time out, got %v", err)
+	}
+
+	_, err = node.StagingPathRefs(ctx, mounter, path, timeout)
+	if !errors.Is(err, node.ErrOperationTimeout) {
+		t.Errorf("expected the mount reference check to time out, got %v", err)
+	}
+
+	_, err = node.VerifyMountedVolumeWithUtils(ctx, mounter, path, "/dev/vdb", timeout)
+	if !errors.Is(err, node.ErrOperationTimeout) {
+		t.Errorf("expected the mount check to time out, got %v", err)
+	}
+}
@@ -0,0 +1,318 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"golang.org/x/sys/unix"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/mount-utils"
+)
+
+const (
+	ProcMountInfoPath    = "/proc/self/mountinfo"
+	DefaultStatfsTimeout = 5 * time.Second
+	DefaultStatsCacheTTL = 30 * time.Second
+
+	volumeHealthyMessage = "volume is healthy"
+	nfsFsTypePrefix      = "nfs"
+)
+
+var (
+	errNotMounted       = errors.New("volume path is not mounted")
+	errSourceMismatch   = errors.New("unexpected volume mounted at volume path")
+	errDeviceLinkAbsent = errors.New("backing device link no longer exists")
+	errRemountedRO      = errors.New("filesystem was remounted read-only after errors")
+	errStatfsTimeout    = errors.New("volume did not respond to statfs in time")
+	errStatfsBlocked    = errors.New("a previous statfs of the volume is still blocked")
+	errStaleHandle      = errors.New("filesystem returned a stale file handle")
+	errDeviceTooSmall   = errors.New("block device is smaller than the recorded disk size")
+)
+
+// ExpectedVolume describes what should be mounted at a volume path.
+// Empty fields skip the corresponding check.
+type ExpectedVolume struct {
+	// DevicePath is the device, or a link to the device, of a block device backed volume.
+	DevicePath string
+	// ExportSuffix must match the end of the mount source of an NFS volume, e.g. ":/volumes/<volume ID>".
+	ExportSuffix string
+	// SizeBytes is the disk size recorded in the volume context. Raw block volumes must be at least this large.
+	SizeBytes int64
+}
+
+// VolumeHealthChecker determines the condition of published volumes from the mount table.
+// Usage is collected with a timeout and cached per volume path, so a hung export
+// cannot block stats of other volumes and large exports are not queried on every call.
+type VolumeHealthChecker struct {
+	// MountInfoPath defaults to ProcMountInfoPath if empty.
+	MountInfoPath string
+	// StatfsTimeout bounds how long a volume may take to answer statfs, defaults to DefaultStatfsTimeout.
+	StatfsTimeout time.Duration
+	// StatsCacheTTL is how long usage is reused for, defaults to DefaultStatsCacheTTL.
+	StatsCacheTTL time.Duration
+
+	mu sync.Mutex
+	// cache holds the last usage of each volume path
+	cache map[string]cachedStats
+	// inFlight holds the volume paths with a statfs which has not returned yet
+	inFlight map[string]struct{}
+}
+
+type cachedStats struct {
+	resp    *csi.NodeGetVolumeStatsResponse
+	expires time.Time
+}
+
+type statsResult struct {
+	resp *csi.NodeGetVolumeStatsResponse
+	err  error
+}
+
+// GetVolumeStatsWithCondition returns usage stats for a volume along with its health condition.
+// The mount table is checked before the volume path is touched, so a hung NFS server cannot block
+// the stats call. Abnormal volumes are reported without usage.
+func (c *VolumeHealthChecker) GetVolumeStatsWithCondition(
+	req *csi.NodeGetVolumeStatsRequest,
+	expected ExpectedVolume,
+) (*csi.NodeGetVolumeStatsResponse, error) {
+	if err := ValidateVolumeStatsRequest(req); err != nil {
+		return nil, err
+	}
+
+	healthErr, err := c.check(req.GetVolumePath(), expected)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to check volume condition: %s", err)
+	}
+
+	if healthErr != nil {
+		if errors.Is(healthErr, errNotMounted) {
+			// Preserve NotFound for volume paths which were removed entirely
+			if _, statErr := os.Stat(req.GetVolumePath()); os.IsNotExist(statErr) {
+				return nil, status.Errorf(codes.NotFound, "%s: %s", ErrVolumePathStat, statErr)
+			}
+		}
+
+		return abnormalResponse(healthErr), nil
+	}
+
+	resp, err := c.volumeStats(req.GetVolumePath())
+	if err != nil {
+		if healthErr = statsHealthErr(err); healthErr != nil {
+			return abnormalResponse(healthErr), nil
+		}
+
+		return nil, volumeStatsStatus(err)
+	}
+
+	return resp, nil
+}
+
+func abnormalResponse(healthErr error) *csi.NodeGetVolumeStatsResponse {
+	return &csi.NodeGetVolumeStatsResponse{
+		VolumeCondition: &csi.VolumeCondition{
+			Abnormal: true,
+			Message:  healthErr.Error(),
+		},
+	}
+}
+
+// statsHealthErr returns the volume condition for errors collecting stats which indicate an unhealthy volume,
+// or nil if the error should be returned to the CO.
+func statsHealthErr(err error) error {
+	switch {
+	case errors.Is(err, unix.ESTALE):
+		return errStaleHandle
+	case errors.Is(err, errStatfsTimeout), errors.Is(err, errStatfsBlocked), errors.Is(err, ErrStatfs):
+		return err
+	default:
+		return nil
+	}
+}
+
+// volumeStats returns the cached stats of volumePath, collecting them again once they expire.
+func (c *VolumeHealthChecker) volumeStats(volumePath string) (*csi.NodeGetVolumeStatsResponse, error) {
+	ttl := c.StatsCacheTTL
+	if ttl == 0 {
+		ttl = DefaultStatsCacheTTL
+	}
+
+	now := time.Now()
+
+	c.mu.Lock()
+	cached, ok := c.cache[volumePath]
+	c.mu.Unlock()
+
+	if ok && now.Before(cached.expires) {
+		return cached.resp, nil
+	}
+
+	resp, err := c.collectStats(volumePath)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cache == nil {
+		c.cache = make(map[string]cachedStats)
+	}
+
+	// Drop expired entries so unpublished volume paths do not accumulate
+	for path, entry := range c.cache {
+		if !now.Before(entry.expires) {
+			delete(c.cache, path)
+		}
+	}
+
+	c.cache[volumePath] = cachedStats{resp: resp, expires: now.Add(ttl)}
+
+	return resp, nil
+}
+
+// collectStats calls statfs on volumePath with a timeout.
+// A statfs which never returns leaves its goroutine blocked until the server recovers,
+// as syscalls cannot be cancelled. No further goroutines are started for the volume path until it does.
+func (c *VolumeHealthChecker) collectStats(volumePath string) (*csi.NodeGetVolumeStatsResponse, error) {
+	timeout := c.StatfsTimeout
+	if timeout == 0 {
+		timeout = DefaultStatfsTimeout
+	}
+
+	c.mu.Lock()
+	if _, ok := c.inFlight[volumePath]; ok {
+		c.mu.Unlock()
+
+		return nil, errStatfsBlocked
+	}
+
+	if c.inFlight == nil {
+		c.inFlight = make(map[string]struct{})
+	}
+
+	c.inFlight[volumePath] = struct{}{}
+	c.mu.Unlock()
+
+	result := make(chan statsResult, 1)
+
+	go func() {
+		resp, err := volumeStats(volumePath)
+
+		c.mu.Lock()
+		delete(c.inFlight, volumePath)
+		c.mu.Unlock()
+
+		result <- statsResult{resp: resp, err: err}
+	}()
+
+	select {
+	case r := <-result:
+		return r.resp, r.err
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("%w after %s", errStatfsTimeout, timeout)
+	}
+}
+
+// check returns a non-nil healthErr describing why the volume is abnormal,
+// or a non-nil err if the condition could not be determined.
+func (c *VolumeHealthChecker) check(volumePath string, expected ExpectedVolume) (healthErr, err error) {
+	mountInfoPath := c.MountInfoPath
+	if mountInfoPath == "" {
+		mountInfoPath = ProcMountInfoPath
+	}
+
+	mountInfos, err := mount.ParseMountInfo(mountInfoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", mountInfoPath, err)
+	}
+
+	info, ok := findMountInfo(mountInfos, volumePath)
+	if !ok {
+		return errNotMounted, nil
+	}
+
+	if expected.DevicePath != "" {
+		if healthErr = checkDevice(info, expected.DevicePath); healthErr != nil {
+			return healthErr, nil
+		}
+	}
+
+	if expected.SizeBytes > 0 {
+		// Only raw block volumes are checked, filesystems are always smaller than their device
+		sizeBytes, sizeErr := BlockDeviceSize(volumePath)
+		if sizeErr == nil && sizeBytes < expected.SizeBytes {
+			return fmt.Errorf("%w: device is %d bytes, disk is %d bytes",
+				errDeviceTooSmall, sizeBytes, expected.SizeBytes), nil
+		}
+	}
+
+	// Errors cause ext4/xfs to mark the superblock read-only while the mount itself stays read-write
+	if slices.Contains(info.SuperOptions, ReadOnlyMountOption) &&
+		!slices.Contains(info.MountOptions, ReadOnlyMountOption) &&
+		!stagedReadOnly(mountInfos, info) {
+		return errRemountedRO, nil
+	}
+
+	// Responsiveness is checked when collecting usage, which is bounded by StatfsTimeout
+	if strings.HasPrefix(info.FsType, nfsFsTypePrefix) && expected.ExportSuffix != "" &&
+		!strings.HasSuffix(info.Source, expected.ExportSuffix) {
+		return fmt.Errorf("%w: %s", errSourceMismatch, info.Source), nil
+	}
+
+	return nil, nil
+}
+
+// stagedReadOnly reports whether the filesystem mounted at info was staged read-only, for a read-only access mode.
+// Its superblock is then read-only by design, even at target paths published without the readonly flag.
+// The staging mount is the first mount of the filesystem in the mount table, bind mounts only follow it.
+func stagedReadOnly(mountInfos []mount.MountInfo, info mount.MountInfo) bool {
+	for _, mountInfo := range mountInfos {
+		if mountInfo.Major == info.Major && mountInfo.Minor == info.Minor {
+			return slices.Contains(mountInfo.MountOptions, ReadOnlyMountOption)
+		}
+	}
+
+	return false
+}
+
+// findMountInfo returns the topmost mount at mountPoint.
+func findMountInfo(mountInfos []mount.MountInfo, mountPoint string) (mount.MountInfo, bool) {
+	for i := len(mountInfos) - 1; i >= 0; i-- {
+		if mountInfos[i].MountPoint == mountPoint {
+			return mountInfos[i], true
+		}
+	}
+
+	return mount.MountInfo{}, false
+}
+
+func checkDevice(info mount.MountInfo, devicePath string) error {
+	resolvedDevice, err := filepath.EvalSymlinks(devicePath)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errDeviceLinkAbsent, devicePath)
+	}
+
+	// Block volumes are bind mounts of the device node from devtmpfs, their source is not the device
+	if !strings.HasPrefix(info.Source, "/dev/") {
+		return nil
+	}
+
+	resolvedSource, err := filepath.EvalSymlinks(info.Source)
+	if err != nil {
+		resolvedSource = info.Source
+	}
+
+	if resolvedSource != resolvedDevice {
+		return fmt.Errorf("%w: expected %s, got %s", errSourceMismatch, resolvedDevice, resolvedSource)
+	}
+
+	return nil
+}
@@ -0,0 +1,224 @@
+package node_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/node"
+)
+
+const testVolumeID = "vol-123"
+
+// writeMountInfo writes a mountinfo file with a single mount of source at mountPoint.
+func writeMountInfo(t *testing.T, mountPoint, fsType, source, mountOpts, superOpts string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "mountinfo")
+	line := fmt.Sprintf("36 35 98:0 / %s %s master:1 - %s %s %s\n", mountPoint, mountOpts, fsType, source, superOpts)
+
+	if err := os.WriteFile(path, []byte(line), 0o600); err != nil {
+		t.Fatalf("failed to write mountinfo: %v", err)
+	}
+
+	return path
+}
+
+func getCondition(t *testing.T, checker *node.VolumeHealthChecker,
+	volumePath string,
+	expected node.ExpectedVolume,
+) *csi.VolumeCondition {
+	t.Helper()
+
+	resp, err := checker.GetVolumeStatsWithCondition(&csi.NodeGetVolumeStatsRequest{
+		VolumeId:   testVolumeID,
+		VolumePath: volumePath,
+	}, expected)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if resp.GetVolumeCondition() == nil {
+		t.Fatal("expected VolumeCondition to be set")
+	}
+
+	return resp.GetVolumeCondition()
+}
+
+func TestVolumeHealth_Healthy(t *testing.T) {
+	t.Parallel()
+
+	volumePath := t.TempDir()
+	checker := &node.VolumeHealthChecker{
+		MountInfoPath: writeMountInfo(t, volumePath, "ext4", "/dev/vdb", "rw,relatime", "rw"),
+	}
+
+	condition := getCondition(t, checker, volumePath, node.ExpectedVolume{})
+	if condition.GetAbnormal() {
+		t.Errorf("expected healthy volume, got %q", condition.GetMessage())
+	}
+}
+
+func TestVolumeHealth_Abnormal(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		fsType    string
+		source    string
+		mountOpts string
+		superOpts string
+		expected  node.ExpectedVolume
+		mounted   bool
+	}{
+		{
+			name:    "not mounted",
+			mounted: false,
+		},
+		{
+			name:      "remounted read-only",
+			fsType:    "ext4",
+			source:    "/dev/vdb",
+			mountOpts: "rw,relatime",
+			superOpts: "ro,errors=remount-ro",
+			mounted:   true,
+		},
+		{
+			name:      "device link missing",
+			fsType:    "ext4",
+			source:    "/dev/vdb",
+			mountOpts: "rw",
+			superOpts: "rw",
+			expected:  node.ExpectedVolume{DevicePath: "/dev/disk/by-id/virtio-does-not-exist"},
+			mounted:   true,
+		},
+		{
+			name:      "unexpected NFS export",
+			fsType:    "nfs",
+			source:    "100.64.0.2:/volumes/vol-other",
+			mountOpts: "rw",
+			superOpts: "rw,vers=3",
+			expected:  node.ExpectedVolume{ExportSuffix: ":/volumes/" + testVolumeID},
+			mounted:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			volumePath := t.TempDir()
+			mountPoint := volumePath
+			if !tt.mounted {
+				mountPoint = "/some/other/path"
+				tt.fsType, tt.source, tt.mountOpts, tt.superOpts = "ext4", "/dev/vdc", "rw", "rw"
+			}
+
+			checker := &node.VolumeHealthChecker{
+				MountInfoPath: writeMountInfo(t, mountPoint, tt.fsType, tt.source, tt.mountOpts, tt.superOpts),
+			}
+
+			condition := getCondition(t, checker, volumePath, tt.expected)
+			if !condition.GetAbnormal() {
+				t.Error("expected abnormal volume condition")
+			}
+
+			if condition.GetMessage() == "" {
+				t.Error("expected abnormal condition to have a message")
+			}
+		})
+	}
+}
+
+func TestVolumeHealth_ReadOnlySuperblock(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		stagingOpts  string
+		wantAbnormal bool
+	}{
+		{
+			// Read-only access modes are staged ro,noload, pods may still publish them without the readonly flag
+			name:        "staged read-only",
+			stagingOpts: "ro,relatime",
+		},
+		{
+			name:         "remounted read-only after errors",
+			stagingOpts:  "rw,relatime",
+			wantAbnormal: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			stagingPath := t.TempDir()
+			volumePath := t.TempDir()
+			mountInfoPath := filepath.Join(t.TempDir(), "mountinfo")
+			mountInfo := fmt.Sprintf("36 35 98:0 / %s %s master:1 - ext4 /dev/vdb ro\n", stagingPath, tt.stagingOpts) +
+				fmt.Sprintf("37 35 98:0 / %s rw,relatime master:1 - ext4 /dev/vdb ro\n", volumePath)
+			if err := os.WriteFile(mountInfoPath, []byte(mountInfo), 0o600); err != nil {
+				t.Fatalf("failed to write mountinfo: %v", err)
+			}
+
+			checker := &node.VolumeHealthChecker{MountInfoPath: mountInfoPath}
+
+			condition := getCondition(t, checker, volumePath, node.ExpectedVolume{})
+			if condition.GetAbnormal() != tt.wantAbnormal {
+				t.Errorf("expected abnormal %t, got %t: %q", tt.wantAbnormal, condition.GetAbnormal(),
+					condition.GetMessage())
+			}
+		})
+	}
+}
+
+func TestVolumeHealth_NFSResponsive(t *testing.T) {
+	t.Parallel()
+
+	volumePath := t.TempDir()
+	checker := &node.VolumeHealthChecker{
+		MountInfoPath: writeMountInfo(t, volumePath, "nfs", "100.64.0.2:/volumes/"+testVolumeID, "rw", "rw,vers=3"),
+	}
+
+	condition := getCondition(t, checker, volumePath,
+		node.ExpectedVolume{ExportSuffix: ":/volumes/" + testVolumeID})
+	if condition.GetAbnormal() {
+		t.Errorf("expected healthy volume, got %q", condition.GetMessage())
+	}
+}
+
+func TestVolumeHealth_CachesStats(t *testing.T) {
+	t.Parallel()
+
+	volumePath := t.TempDir()
+	checker := &node.VolumeHealthChecker{
+		MountInfoPath: writeMountInfo(t, volumePath, "nfs", "100.64.0.2:/volumes/"+testVolumeID, "rw", "rw,vers=3"),
+		StatsCacheTTL: time.Hour,
+	}
+
+	req := &csi.NodeGetVolumeStatsRequest{VolumeId: testVolumeID, VolumePath: volumePath}
+
+	first, err := checker.GetVolumeStatsWithCondition(req, node.ExpectedVolume{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The volume can no longer be stat'd, cached usage must be served without touching it
+	if err = os.Remove(volumePath); err != nil {
+		t.Fatalf("failed to remove volume path: %v", err)
+	}
+
+	second, err := checker.GetVolumeStatsWithCondition(req, node.ExpectedVolume{})
+	if err != nil {
+		t.Fatalf("expected cached stats, got error: %v", err)
+	}
+
+	if len(second.GetUsage()) == 0 || second.GetUsage()[0].GetTotal() != first.GetUsage()[0].GetTotal() {
+		t.Errorf("expected cached usage %v, got %v", first.GetUsage(), second.GetUsage())
+	}
+}
@@ -0,0 +1,57 @@
+package node
+
+import (
+	"errors"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var ErrOperationInFlight = errors.New("an operation is already in progress for volume")
+
+// VolumeLocks serializes node operations on the same volume and path. Overlapping requests fail fast
+// with codes.Aborted rather than blocking, so the CO retries once the in-flight operation finishes.
+// Different paths of one volume, e.g. the target paths of two pods sharing a volume, do not contend.
+// The zero value is ready to use.
+type VolumeLocks struct {
+	mu       sync.Mutex
+	inFlight map[string]struct{}
+}
+
+// TryAcquire locks key, returning false if it is already locked.
+func (l *VolumeLocks) TryAcquire(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight == nil {
+		l.inFlight = make(map[string]struct{})
+	}
+
+	if _, ok := l.inFlight[key]; ok {
+		return false
+	}
+
+	l.inFlight[key] = struct{}{}
+
+	return true
+}
+
+// Release unlocks key.
+func (l *VolumeLocks) Release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.inFlight, key)
+}
+
+// Lock locks volumeID at path for the duration of a CSI call,
+// returning a codes.Aborted status if it is already locked. The returned function releases the lock.
+func (l *VolumeLocks) Lock(volumeID, path string) (func(), error) {
+	key := volumeID + ":" + path
+	if !l.TryAcquire(key) {
+		return nil, status.Errorf(codes.Aborted, "%s %s at %s", ErrOperationInFlight, volumeID, path)
+	}
+
+	return func() { l.Release(key) }, nil
+}
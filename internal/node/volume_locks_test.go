@@ -0,0 +1,41 @@
+package node_test
+
+import (
+	"testing"
+
+	"github.com/crusoecloud/crusoe-csi-driver/internal/node"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestVolumeLocks(t *testing.T) {
+	t.Parallel()
+
+	var locks node.VolumeLocks
+
+	release, err := locks.Lock("vol-1", "/target/a")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = locks.Lock("vol-1", "/target/a")
+	if status.Code(err) != codes.Aborted {
+		t.Errorf("expected Aborted for overlapping operation, got %v", err)
+	}
+
+	releaseOther, err := locks.Lock("vol-1", "/target/b")
+	if err != nil {
+		t.Errorf("expected another path of the same volume to lock, got %v", err)
+	} else {
+		releaseOther()
+	}
+
+	release()
+
+	release, err = locks.Lock("vol-1", "/target/a")
+	if err != nil {
+		t.Fatalf("expected lock to be released, got %v", err)
+	}
+
+	release()
+}
@@ -1,7 +1,13 @@
 package node
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"golang.org/x/sys/unix"
@@ -24,9 +30,18 @@ func ValidateVolumeStatsRequest(req *csi.NodeGetVolumeStatsRequest) error {
 
 // GetFilesystemVolumeStats returns BYTES and INODES usage for a filesystem-mounted volume.
 func GetFilesystemVolumeStats(volumePath string) (*csi.NodeGetVolumeStatsResponse, error) {
+	resp, err := filesystemVolumeStats(volumePath)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "%s", err)
+	}
+
+	return resp, nil
+}
+
+func filesystemVolumeStats(volumePath string) (*csi.NodeGetVolumeStatsResponse, error) {
 	var statfs unix.Statfs_t
 	if err := unix.Statfs(volumePath, &statfs); err != nil {
-		return nil, status.Errorf(codes.Internal, "%s: %s", ErrStatfs, err)
+		return nil, fmt.Errorf("%w: %w", ErrStatfs, err)
 	}
 
 	bsize := int64(statfs.Bsize) //nolint:unconvert,nolintlint // Bsize is int64 on linux, uint32 on darwin
@@ -60,7 +75,68 @@ func GetFilesystemVolumeStats(volumePath string) (*csi.NodeGetVolumeStatsRespons
 		},
 		VolumeCondition: &csi.VolumeCondition{
 			Abnormal: false,
-			Message:  "volume is healthy",
+			Message:  volumeHealthyMessage,
+		},
+	}, nil
+}
+
+// BlockDeviceSize returns the size in bytes of the block device at devicePath.
+// devicePath may be a device node or a file the device node is bind mounted to.
+// The size is read from sysfs using the device's major:minor numbers.
+func BlockDeviceSize(devicePath string) (int64, error) {
+	var stat unix.Stat_t
+	if err := unix.Stat(devicePath, &stat); err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrVolumePathStat, err)
+	}
+
+	if stat.Mode&unix.S_IFMT != unix.S_IFBLK {
+		return 0, fmt.Errorf("%w: %s", ErrNotBlockDevice, devicePath)
+	}
+
+	rdev := uint64(stat.Rdev) //nolint:unconvert,nolintlint,gosec // Rdev is uint64 on linux, int32 on darwin
+	sizePath := filepath.Join(SysDevBlockPath, fmt.Sprintf("%d:%d", unix.Major(rdev), unix.Minor(rdev)), "size")
+
+	contents, err := os.ReadFile(sizePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", sizePath, err)
+	}
+
+	sectors, err := strconv.ParseInt(strings.TrimSpace(string(contents)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %w", sizePath, err)
+	}
+
+	return sectors * SysfsSectorSize, nil
+}
+
+// GetBlockVolumeStats returns the capacity of a raw block volume as BYTES usage.
+// A block device has no notion of free space, so the whole device is reported as used.
+func GetBlockVolumeStats(volumePath string) (*csi.NodeGetVolumeStatsResponse, error) {
+	resp, err := blockVolumeStats(volumePath)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "%s", err)
+	}
+
+	return resp, nil
+}
+
+func blockVolumeStats(volumePath string) (*csi.NodeGetVolumeStatsResponse, error) {
+	sizeBytes, err := BlockDeviceSize(volumePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block device size: %w", err)
+	}
+
+	return &csi.NodeGetVolumeStatsResponse{
+		Usage: []*csi.VolumeUsage{
+			{
+				Total: sizeBytes,
+				Used:  sizeBytes,
+				Unit:  csi.VolumeUsage_BYTES,
+			},
+		},
+		VolumeCondition: &csi.VolumeCondition{
+			Abnormal: false,
+			Message:  volumeHealthyMessage,
 		},
 	}, nil
 }
@@ -73,29 +149,47 @@ func GetVolumeStats(req *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStats
 		return nil, err
 	}
 
-	volumePath := req.GetVolumePath()
+	resp, err := volumeStats(req.GetVolumePath())
+	if err != nil {
+		return nil, volumeStatsStatus(err)
+	}
+
+	return resp, nil
+}
+
+// volumeStatsStatus converts a volumeStats error to a gRPC status.
+func volumeStatsStatus(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return status.Errorf(codes.NotFound, "%s", err)
+	}
+
+	return status.Errorf(codes.Internal, "%s", err)
+}
 
+// volumeStats returns the stats of the filesystem or block device at volumePath.
+func volumeStats(volumePath string) (*csi.NodeGetVolumeStatsResponse, error) {
 	fi, err := os.Stat(volumePath)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, status.Errorf(codes.NotFound, "%s: %s", ErrVolumePathStat, err)
-		}
-
-		return nil, status.Errorf(codes.Internal, "%s: %s", ErrVolumePathStat, err)
+		return nil, fmt.Errorf("%w: %w", ErrVolumePathStat, err)
 	}
 
 	// If the path is a directory it is a filesystem-mounted volume.
 	if fi.IsDir() {
-		return GetFilesystemVolumeStats(volumePath)
+		return filesystemVolumeStats(volumePath)
+	}
+
+	// Raw block volumes are device nodes bind mounted to a file at the volume path.
+	if fi.Mode()&os.ModeDevice != 0 && fi.Mode()&os.ModeCharDevice == 0 {
+		return blockVolumeStats(volumePath)
 	}
 
-	// Non-directory paths (regular files, device nodes) are treated as raw
-	// block volumes. The CSI spec does not require usage data for block
-	// volumes, so we return a healthy condition with no usage entries.
+	// Other non-directory paths are block volumes whose device is no longer
+	// mounted. The CSI spec does not require usage data for block volumes,
+	// so we return a healthy condition with no usage entries.
 	return &csi.NodeGetVolumeStatsResponse{
 		VolumeCondition: &csi.VolumeCondition{
 			Abnormal: false,
-			Message:  "volume is healthy",
+			Message:  volumeHealthyMessage,
 		},
 	}, nil
 }
@@ -1,6 +1,7 @@
 package node_test
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -209,3 +210,17 @@ func TestGetFilesystemVolumeStats(t *testing.T) {
 
 	assertHealthyCondition(t, resp)
 }
+
+func TestBlockDeviceSize_NotBlockDevice(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "regular-file")
+	if err := os.WriteFile(path, []byte("not a device"), 0o600); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	_, err := node.BlockDeviceSize(path)
+	if !errors.Is(err, node.ErrNotBlockDevice) {
+		t.Errorf("expected ErrNotBlockDevice, got: %v", err)
+	}
+}
@@ -5,9 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"slices"
 
 	"github.com/crusoecloud/crusoe-csi-driver/internal/common"
 	"github.com/crusoecloud/crusoe-csi-driver/internal/controller"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/crusoe"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/events"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/logging"
 	"github.com/crusoecloud/crusoe-csi-driver/internal/node/fs"
 	"github.com/crusoecloud/crusoe-csi-driver/internal/node/ssd"
 
@@ -18,13 +22,53 @@ import (
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/spf13/viper"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"k8s.io/klog/v2"
 
 	crusoeapi "github.com/crusoecloud/client-go/swagger/v1alpha5"
 )
 
-func registerIdentity(grpcServer *grpc.Server, serveController bool) {
+// readinessChecks returns the checks run by Probe for the services being served.
+// Only the controller checks the Crusoe API: node plugins keep serving mounted volumes during an API outage,
+// and failing their probe would restart every node plugin at once.
+func readinessChecks(hostInstance *crusoeapi.InstanceV1Alpha5,
+	serveController,
+	serveNode bool,
+) []identity.ReadinessCheck {
+	var checks []identity.ReadinessCheck
+
+	if serveController {
+		checks = append(checks, identity.NewCachedCheck(&identity.CrusoeAPICheck{
+			CrusoeClient: newCrusoeClientWithViperConfig(),
+			ProjectID:    hostInstance.ProjectId,
+			InstanceID:   hostInstance.Id,
+		}, apiReadinessCheckTTL))
+	}
+
+	if !serveNode {
+		return checks
+	}
+
+	switch common.PluginDiskType {
+	case common.DiskTypeSSD:
+		checks = append(checks, &identity.PathCheck{Path: identity.SysBlockPath})
+	case common.DiskTypeFS:
+		checks = append(checks, &identity.FilesystemCheck{Filesystems: []string{"nfs", "virtiofs"}})
+	default:
+		// Switch is intended to be exhaustive, reaching this case is a bug
+		panic(fmt.Sprintf(
+			"Switch is intended to be exhaustive, %s is not a valid switch case", common.PluginDiskType))
+	}
+
+	return checks
+}
+
+func registerIdentity(grpcServer *grpc.Server,
+	hostInstance *crusoeapi.InstanceV1Alpha5,
+	serveController,
+	serveNode bool,
+) {
 	capabilities := common.BaseIdentityCapabilities
 
 	if serveController {
@@ -34,41 +78,58 @@ func registerIdentity(grpcServer *grpc.Server, serveController bool) {
 	case common.DiskTypeFS:
 		capabilities = append(capabilities, &common.PluginCapabilityVolumeExpansionOnline)
 	case common.DiskTypeSSD:
-		capabilities = append(capabilities, &common.PluginCapabilityVolumeExpansionOffline)
+		if viper.GetBool(OnlineExpansionFlag) {
+			capabilities = append(capabilities, &common.PluginCapabilityVolumeExpansionOnline)
+		} else {
+			capabilities = append(capabilities, &common.PluginCapabilityVolumeExpansionOffline)
+		}
 	default:
 		// Switch is intended to be exhaustive, reaching this case is a bug
 		panic(fmt.Sprintf(
 			"Switch is intended to be exhaustive, %s is not a valid switch case", common.PluginDiskType))
 	}
 	csi.RegisterIdentityServer(grpcServer, &identity.Service{
-		Capabilities:  capabilities,
-		PluginName:    common.PluginName,
-		PluginVersion: common.PluginVersion,
+		Capabilities:    capabilities,
+		PluginName:      common.PluginName,
+		PluginVersion:   common.PluginVersion,
+		ReadinessChecks: readinessChecks(hostInstance, serveController, serveNode),
 	})
 }
 
-func registerController(grpcServer *grpc.Server, hostInstance *crusoeapi.InstanceV1Alpha5) {
+func registerController(grpcServer *grpc.Server,
+	hostInstance *crusoeapi.InstanceV1Alpha5,
+	recorder events.Recorder,
+) {
 	capabilities := common.BaseControllerCapabilities
 
 	csi.RegisterControllerServer(grpcServer, &controller.DefaultController{
-		CrusoeClient:  newCrusoeClientWithViperConfig(),
-		HostInstance:  hostInstance,
-		Capabilities:  capabilities,
-		DiskType:      common.PluginDiskType,
-		PluginName:    common.PluginName,
-		PluginVersion: common.PluginVersion,
+		CrusoeClient:    newCrusoeClientWithViperConfig(),
+		HostInstance:    hostInstance,
+		Events:          recorder,
+		Capabilities:    capabilities,
+		DiskType:        common.PluginDiskType,
+		PluginName:      common.PluginName,
+		PluginVersion:   common.PluginVersion,
+		OnlineExpansion: viper.GetBool(OnlineExpansionFlag),
 	})
 }
 
-func registerNode(grpcServer *grpc.Server, hostInstance *crusoeapi.InstanceV1Alpha5) {
-	// TODO: Add NodeExpandVolume capability once SSD online expansion is supported upstream
-	capabilities := common.BaseNodeCapabilities
+func registerNode(grpcServer *grpc.Server,
+	hostInstance *crusoeapi.InstanceV1Alpha5,
+	recorder events.Recorder,
+	flags crusoe.FlagSource,
+) error {
+	// Appending to the shared base slice could overwrite its backing array
+	capabilities := slices.Clone(common.BaseNodeCapabilities)
 	var maxVolumesPerNode int64
 	var nodeServer csi.NodeServer
 
 	switch common.PluginDiskType {
 	case common.DiskTypeSSD:
 		maxVolumesPerNode = common.MaxSSDVolumesPerNode - 1 // Subtract 1 to allow for the OS/boot disk
+		if viper.GetBool(OnlineExpansionFlag) {
+			capabilities = append(capabilities, &common.NodeCapabilityExpandVolume)
+		}
 		nodeServer = &ssd.Node{
 			CrusoeClient:      newCrusoeClientWithViperConfig(),
 			CrusoeHTTPClient:  newCrusoeHTTPClientWithViperConfig(),
@@ -79,25 +140,33 @@ func registerNode(grpcServer *grpc.Server, hostInstance *crusoeapi.InstanceV1Alp
 			PluginName:        common.PluginName,
 			PluginVersion:     common.PluginVersion,
 			HostInstance:      hostInstance,
+			Events:            recorder,
 			Capabilities:      capabilities,
 			MaxVolumesPerNode: maxVolumesPerNode,
+			OnlineExpansion:   viper.GetBool(OnlineExpansionFlag),
+			OperationTimeout:  viper.GetDuration(OperationTimeoutFlag),
+			LUKS:              ssd.LUKS{Exec: exec.New(), KeyFileDir: viper.GetString(LUKSKeyFileDirFlag)},
 		}
 	case common.DiskTypeFS:
 		maxVolumesPerNode = common.MaxFSVolumesPerNode
+		nfsTargets, err := newNFSTargetChainWithViperConfig(flags)
+		if err != nil {
+			return err
+		}
+
 		nodeServer = &fs.Node{
-			CrusoeClient:      newCrusoeClientWithViperConfig(),
-			CrusoeHTTPClient:  newCrusoeHTTPClientWithViperConfig(),
+			Flags:             flags,
+			NFSTargets:        nfsTargets,
 			Mounter:           mount.NewSafeFormatAndMount(mount.New(""), exec.New()),
 			Resizer:           mount.NewResizeFs(exec.New()),
-			CrusoeAPIEndpoint: viper.GetString(CrusoeAPIEndpointFlag),
-			NFSRemotePorts:    viper.GetString(NFSRemotePortsFlag),
-			NFSHost:           viper.GetString(NFSHostFlag),
 			DiskType:          common.PluginDiskType,
 			PluginName:        common.PluginName,
 			PluginVersion:     common.PluginVersion,
 			HostInstance:      hostInstance,
+			Events:            recorder,
 			Capabilities:      capabilities,
 			MaxVolumesPerNode: maxVolumesPerNode,
+			OperationTimeout:  viper.GetDuration(OperationTimeoutFlag),
 		}
 	default:
 		// Switch is intended to be exhaustive, reaching this case is a bug
@@ -106,9 +175,15 @@ func registerNode(grpcServer *grpc.Server, hostInstance *crusoeapi.InstanceV1Alp
 	}
 
 	csi.RegisterNodeServer(grpcServer, nodeServer)
+
+	return nil
 }
 
-func registerServices(grpcServer *grpc.Server, hostInstance *crusoeapi.InstanceV1Alpha5) {
+func registerServices(grpcServer *grpc.Server,
+	hostInstance *crusoeapi.InstanceV1Alpha5,
+	recorder events.Recorder,
+	flags crusoe.FlagSource,
+) error {
 	serveIdentity := false
 	serveController := false
 	serveNode := false
@@ -127,16 +202,18 @@ func registerServices(grpcServer *grpc.Server, hostInstance *crusoeapi.InstanceV
 	}
 
 	if serveIdentity {
-		registerIdentity(grpcServer, serveController)
+		registerIdentity(grpcServer, hostInstance, serveController, serveNode)
 	}
 
 	if serveController {
-		registerController(grpcServer, hostInstance)
+		registerController(grpcServer, hostInstance, recorder)
 	}
 
 	if serveNode {
-		registerNode(grpcServer, hostInstance)
+		return registerNode(grpcServer, hostInstance, recorder, flags)
 	}
+
+	return nil
 }
 
 func Serve(rootCtx context.Context, rootCtxCancel context.CancelFunc, interruptChan <-chan os.Signal) error {
@@ -147,8 +224,40 @@ func Serve(rootCtx context.Context, rootCtxCancel context.CancelFunc, interruptC
 
 	klog.Infof("Crusoe host instance ID: %v", hostInstance.Id)
 
-	srv := grpc.NewServer(grpc.ConnectionTimeout(gracefulTimeoutDuration))
-	registerServices(srv, hostInstance)
+	flags, err := newFlagProviderWithViperConfig()
+	if err != nil {
+		return err
+	}
+
+	recorder, err := newEventRecorder(rootCtx)
+	if err != nil {
+		return fmt.Errorf("failed to set up event recorder: %w", err)
+	}
+
+	shutdownTracing, err := setupTracing(rootCtx)
+	if err != nil {
+		return fmt.Errorf("failed to set up tracing: %w", err)
+	}
+	defer shutdownWithTimeout("traces", shutdownTracing, gracefulTimeoutDuration)
+
+	shutdownMetrics, err := setupMetrics(rootCtx)
+	if err != nil {
+		return fmt.Errorf("failed to set up metrics: %w", err)
+	}
+	defer shutdownWithTimeout("metrics", shutdownMetrics, gracefulTimeoutDuration)
+
+	srv := grpc.NewServer(
+		grpc.ConnectionTimeout(gracefulTimeoutDuration),
+		// Creates a server span for every CSI RPC; a no-op unless tracing is enabled
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		// Logs every RPC with a request ID and secrets stripped
+		grpc.ChainUnaryInterceptor(logging.UnaryServerInterceptor()),
+	)
+	err = registerServices(srv, hostInstance, recorder, flags)
+	if err != nil {
+		return err
+	}
+
 	listener, err := listen()
 	if err != nil {
 		return err
@@ -0,0 +1,97 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the instrumentation scope used for all spans created by the driver.
+const TracerName = "github.com/crusoecloud/crusoe-csi-driver"
+
+// Span attribute keys shared across packages.
+const (
+	VolumeIDKey    = attribute.Key("csi.volume_id")
+	NodeIDKey      = attribute.Key("csi.node_id")
+	TargetPathKey  = attribute.Key("csi.target_path")
+	DevicePathKey  = attribute.Key("csi.device_path")
+	FilesystemKey  = attribute.Key("csi.fs_type")
+	OperationIDKey = attribute.Key("crusoe.operation_id")
+)
+
+var errEmptyEndpoint = errors.New("tracing endpoint must be provided")
+
+// Config configures the OTLP trace exporter.
+type Config struct {
+	Endpoint       string
+	ServiceName    string
+	ServiceVersion string
+	Insecure       bool
+}
+
+// Setup installs a global tracer provider which exports spans over OTLP/gRPC.
+// The returned function flushes and shuts down the provider and should be called on exit.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	if cfg.Endpoint == "" {
+		return nil, errEmptyEndpoint
+	}
+
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.Endpoint)}
+	if cfg.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName),
+		semconv.ServiceVersion(cfg.ServiceVersion),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return provider.Shutdown, nil
+}
+
+// Start creates a child span of the span in ctx, if any.
+// When tracing is disabled the global no-op provider makes this effectively free.
+//
+//nolint:spancheck // callers are responsible for ending the span
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(TracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err on the span, if set, and ends the span.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
@@ -16,6 +16,10 @@ import (
 	crusoeapi "github.com/crusoecloud/client-go/swagger/v1alpha5"
 	"github.com/crusoecloud/crusoe-csi-driver/internal/common"
 	"github.com/crusoecloud/crusoe-csi-driver/internal/crusoe"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/events"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/metrics"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/node/fs"
+	"github.com/crusoecloud/crusoe-csi-driver/internal/tracing"
 	"github.com/google/uuid"
 	"github.com/spf13/viper"
 	"google.golang.org/grpc"
@@ -32,6 +36,9 @@ const (
 	vmIDFilePath = "/sys/class/dmi/id/product_uuid"
 
 	gracefulTimeoutDuration = 10 * time.Second
+
+	// apiReadinessCheckTTL bounds how often Probe calls the Crusoe API.
+	apiReadinessCheckTTL = 1 * time.Minute
 )
 
 var (
@@ -68,14 +75,9 @@ func getHostInstance(ctx context.Context) (*crusoeapi.InstanceV1Alpha5, error) {
 	projectID = viper.GetString(CrusoeProjectIDFlag)
 	if projectID == "" {
 		var ok bool
-		kubeClientConfig, configErr := rest.InClusterConfig()
-		if configErr != nil {
-			return nil, fmt.Errorf("could not get kube client config: %w", configErr)
-		}
-
-		kubeClient, clientErr := kubernetes.NewForConfig(kubeClientConfig)
+		kubeClient, clientErr := newKubeClient()
 		if clientErr != nil {
-			return nil, fmt.Errorf("could not get kube client: %w", clientErr)
+			return nil, clientErr
 		}
 		hostNode, nodeFetchErr := kubeClient.CoreV1().Nodes().Get(ctx, viper.GetString(NodeNameFlag), metav1.GetOptions{})
 		if nodeFetchErr != nil {
@@ -105,6 +107,39 @@ func getHostInstance(ctx context.Context) (*crusoeapi.InstanceV1Alpha5, error) {
 	return &instances.Items[0], nil
 }
 
+func newKubeClient() (*kubernetes.Clientset, error) {
+	kubeClientConfig, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("could not get kube client config: %w", err)
+	}
+
+	kubeClient, err := kubernetes.NewForConfig(kubeClientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("could not get kube client: %w", err)
+	}
+
+	return kubeClient, nil
+}
+
+// newEventRecorder returns a recorder which emits Kubernetes Events if enabled, or discards them otherwise.
+func newEventRecorder(ctx context.Context) (events.Recorder, error) {
+	if !viper.GetBool(EmitEventsFlag) {
+		return events.NoopRecorder{}, nil
+	}
+
+	kubeClient, err := newKubeClient()
+	if err != nil {
+		return nil, err
+	}
+
+	recorder, err := events.NewKubeRecorder(ctx, kubeClient, common.PluginName, viper.GetString(NodeNameFlag))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create event recorder: %w", err)
+	}
+
+	return recorder, nil
+}
+
 func listen() (net.Listener, error) {
 	ep, err := url.Parse(viper.GetString(SocketAddressFlag))
 	if err != nil {
@@ -158,3 +193,98 @@ func newCrusoeClientWithViperConfig() *crusoeapi.APIClient {
 func newCrusoeHTTPClientWithViperConfig() *http.Client {
 	return crusoe.NewCrusoeHTTPClient(viper.GetString(CrusoeAccessKeyFlag), viper.GetString(CrusoeSecretKeyFlag))
 }
+
+// newFlagProviderWithViperConfig returns a provider of project feature flags,
+// failing if the flag overrides cannot be parsed.
+func newFlagProviderWithViperConfig() (*crusoe.FlagProvider, error) {
+	overrides, err := crusoe.ParseFlagOverrides(viper.GetString(FlagOverridesFlag))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse --%s: %w", FlagOverridesFlag, err)
+	}
+
+	return &crusoe.FlagProvider{
+		HTTPClient:  newCrusoeHTTPClientWithViperConfig(),
+		APIEndpoint: viper.GetString(CrusoeAPIEndpointFlag),
+		TTL:         viper.GetDuration(FlagCacheTTLFlag),
+		Overrides:   overrides,
+	}, nil
+}
+
+// newNFSTargetChainWithViperConfig returns the NFS target resolver chain of the config file,
+// or the default chain if no file is set.
+func newNFSTargetChainWithViperConfig(flags crusoe.FlagSource) (fs.NFSTargetChain, error) {
+	locationTargets, err := fs.ParseNFSLocationTargets(viper.GetString(NFSLocationTargetsFlag))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse --%s: %w", NFSLocationTargetsFlag, err)
+	}
+
+	config, err := fs.LoadNFSTargetConfig(viper.GetString(NFSTargetConfigFlag), locationTargets)
+	if err != nil {
+		return nil, err
+	}
+
+	chain, err := config.Build(crusoe.DiskClient{Client: newCrusoeClientWithViperConfig()}, flags, fs.NFSTarget{
+		Host:        viper.GetString(NFSHostFlag),
+		RemotePorts: viper.GetString(NFSRemotePortsFlag),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build NFS target resolvers: %w", err)
+	}
+
+	return chain, nil
+}
+
+// setupTracing installs the OTLP tracer provider if tracing is enabled.
+// The returned function is always safe to call.
+func setupTracing(ctx context.Context) (func(context.Context) error, error) {
+	if !viper.GetBool(TracingEnabledFlag) {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	shutdown, err := tracing.Setup(ctx, tracing.Config{
+		Endpoint:       viper.GetString(TracingEndpointFlag),
+		Insecure:       viper.GetBool(TracingInsecureFlag),
+		ServiceName:    common.PluginName,
+		ServiceVersion: common.PluginVersion,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up tracing: %w", err)
+	}
+
+	klog.Infof("Exporting traces to %s", viper.GetString(TracingEndpointFlag))
+
+	return shutdown, nil
+}
+
+// setupMetrics installs the OTLP meter provider if metrics are enabled.
+// The returned function is always safe to call.
+func setupMetrics(ctx context.Context) (func(context.Context) error, error) {
+	if !viper.GetBool(MetricsEnabledFlag) {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	shutdown, err := metrics.Setup(ctx, metrics.Config{
+		Endpoint:       viper.GetString(MetricsEndpointFlag),
+		Insecure:       viper.GetBool(MetricsInsecureFlag),
+		Interval:       viper.GetDuration(MetricsIntervalFlag),
+		ServiceName:    common.PluginName,
+		ServiceVersion: common.PluginVersion,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up metrics: %w", err)
+	}
+
+	klog.Infof("Exporting metrics to %s", viper.GetString(MetricsEndpointFlag))
+
+	return shutdown, nil
+}
+
+// shutdownWithTimeout flushes the traces or metrics of a provider installed by setupTracing or setupMetrics.
+func shutdownWithTimeout(signal string, shutdown func(context.Context) error, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := shutdown(ctx); err != nil {
+		klog.Errorf("failed to flush %s: %s", signal, err)
+	}
+}